@@ -0,0 +1,414 @@
+// Package analytics builds match and player analytics from captured frames.
+package analytics
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/echotools/nevr-capture/v3/pkg/arena"
+	"github.com/echotools/nevr-capture/v3/pkg/events"
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// HeatmapLayer selects which samples a heatmap accumulates
+type HeatmapLayer int
+
+const (
+	// LayerPosition samples every player position in every frame
+	LayerPosition HeatmapLayer = iota
+	// LayerCatch samples the catcher's position on DiscCaught events
+	LayerCatch
+	// LayerShot samples the shooter's position on PlayerShotTaken events
+	LayerShot
+)
+
+// String returns the layer name
+func (l HeatmapLayer) String() string {
+	switch l {
+	case LayerPosition:
+		return "position"
+	case LayerCatch:
+		return "catch"
+	case LayerShot:
+		return "shot"
+	}
+	return fmt.Sprintf("layer(%d)", int(l))
+}
+
+// GridConfig describes the top-down (X/Z) area covered by a heatmap
+type GridConfig struct {
+	MinX     float64 `json:"min_x"`
+	MaxX     float64 `json:"max_x"`
+	MinZ     float64 `json:"min_z"`
+	MaxZ     float64 `json:"max_z"`
+	CellSize float64 `json:"cell_size"`
+}
+
+// DefaultGridConfig covers the whole arena with one meter cells
+func DefaultGridConfig() GridConfig {
+	return GridConfig{
+		MinX:     arena.MinX,
+		MaxX:     arena.MaxX,
+		MinZ:     arena.MinZ,
+		MaxZ:     arena.MaxZ,
+		CellSize: 1,
+	}
+}
+
+// Grid is a density grid of X/Z samples. Columns run along X and rows along Z.
+type Grid struct {
+	Config  GridConfig
+	Cols    int
+	Rows    int
+	Cells   []float64 // row-major
+	Samples int
+}
+
+// NewGrid creates an empty grid for the given configuration
+func NewGrid(cfg GridConfig) *Grid {
+	if cfg.CellSize <= 0 {
+		cfg.CellSize = 1
+	}
+	cols := int(math.Ceil((cfg.MaxX - cfg.MinX) / cfg.CellSize))
+	rows := int(math.Ceil((cfg.MaxZ - cfg.MinZ) / cfg.CellSize))
+	if cols < 1 {
+		cols = 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	return &Grid{
+		Config: cfg,
+		Cols:   cols,
+		Rows:   rows,
+		Cells:  make([]float64, cols*rows),
+	}
+}
+
+// Add bins a sample. Returns false if the sample lies outside the grid.
+func (g *Grid) Add(x, z float64) bool {
+	col, row, ok := g.cellOf(x, z)
+	if !ok {
+		return false
+	}
+	g.Cells[row*g.Cols+col]++
+	g.Samples++
+	return true
+}
+
+// At returns the value of a cell, or 0 if the cell is out of range
+func (g *Grid) At(col, row int) float64 {
+	if col < 0 || col >= g.Cols || row < 0 || row >= g.Rows {
+		return 0
+	}
+	return g.Cells[row*g.Cols+col]
+}
+
+// Max returns the value of the densest cell
+func (g *Grid) Max() float64 {
+	var m float64
+	for _, v := range g.Cells {
+		if v > m {
+			m = v
+		}
+	}
+	return m
+}
+
+// CellCenter returns the arena X/Z coordinates of a cell's center
+func (g *Grid) CellCenter(col, row int) (x, z float64) {
+	x = g.Config.MinX + (float64(col)+0.5)*g.Config.CellSize
+	z = g.Config.MinZ + (float64(row)+0.5)*g.Config.CellSize
+	return x, z
+}
+
+// Reset clears all samples
+func (g *Grid) Reset() {
+	for i := range g.Cells {
+		g.Cells[i] = 0
+	}
+	g.Samples = 0
+}
+
+func (g *Grid) cellOf(x, z float64) (col, row int, ok bool) {
+	if math.IsNaN(x) || math.IsNaN(z) {
+		return 0, 0, false
+	}
+	if x < g.Config.MinX || x > g.Config.MaxX || z < g.Config.MinZ || z > g.Config.MaxZ {
+		return 0, 0, false
+	}
+	col = int((x - g.Config.MinX) / g.Config.CellSize)
+	row = int((z - g.Config.MinZ) / g.Config.CellSize)
+	// Samples on the max edge belong to the last cell
+	if col == g.Cols {
+		col--
+	}
+	if row == g.Rows {
+		row--
+	}
+	return col, row, true
+}
+
+// gridJSON is the JSON representation of a Grid
+type gridJSON struct {
+	GridConfig
+	Cols    int         `json:"cols"`
+	Rows    int         `json:"rows"`
+	Samples int         `json:"samples"`
+	Cells   [][]float64 `json:"cells"` // indexed [row][col]
+}
+
+// MarshalJSON encodes the grid with its cells as a [row][col] matrix
+func (g *Grid) MarshalJSON() ([]byte, error) {
+	out := gridJSON{
+		GridConfig: g.Config,
+		Cols:       g.Cols,
+		Rows:       g.Rows,
+		Samples:    g.Samples,
+		Cells:      make([][]float64, g.Rows),
+	}
+	for row := range out.Cells {
+		out.Cells[row] = g.Cells[row*g.Cols : (row+1)*g.Cols]
+	}
+	return json.Marshal(out)
+}
+
+// WriteJSON writes the grid as JSON
+func (g *Grid) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(g)
+}
+
+// WriteCSV writes one row per non-empty cell with its center coordinates
+func (g *Grid) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"col", "row", "x", "z", "count"}); err != nil {
+		return err
+	}
+	for row := 0; row < g.Rows; row++ {
+		for col := 0; col < g.Cols; col++ {
+			v := g.At(col, row)
+			if v == 0 {
+				continue
+			}
+			x, z := g.CellCenter(col, row)
+			if err := cw.Write([]string{
+				strconv.Itoa(col),
+				strconv.Itoa(row),
+				strconv.FormatFloat(x, 'f', -1, 64),
+				strconv.FormatFloat(z, 'f', -1, 64),
+				strconv.FormatFloat(v, 'f', -1, 64),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Image renders the grid with each cell drawn as a scale x scale pixel block.
+// Positive Z is drawn at the top of the image.
+func (g *Grid) Image(scale int) *image.RGBA {
+	if scale < 1 {
+		scale = 1
+	}
+	img := image.NewRGBA(image.Rect(0, 0, g.Cols*scale, g.Rows*scale))
+	maxValue := g.Max()
+
+	for row := 0; row < g.Rows; row++ {
+		for col := 0; col < g.Cols; col++ {
+			var t float64
+			if maxValue > 0 {
+				t = g.At(col, row) / maxValue
+			}
+			c := heatColor(t)
+			y0 := (g.Rows - 1 - row) * scale
+			x0 := col * scale
+			for y := y0; y < y0+scale; y++ {
+				for x := x0; x < x0+scale; x++ {
+					img.SetRGBA(x, y, c)
+				}
+			}
+		}
+	}
+	return img
+}
+
+// WritePNG renders the grid and encodes it as a PNG
+func (g *Grid) WritePNG(w io.Writer, scale int) error {
+	return png.Encode(w, g.Image(scale))
+}
+
+// heatColorStops is the color ramp used for rendering, from empty to densest
+var heatColorStops = [...]color.RGBA{
+	{R: 0, G: 0, B: 0, A: 255},
+	{R: 0, G: 0, B: 255, A: 255},
+	{R: 0, G: 255, B: 255, A: 255},
+	{R: 0, G: 255, B: 0, A: 255},
+	{R: 255, G: 255, B: 0, A: 255},
+	{R: 255, G: 0, B: 0, A: 255},
+}
+
+// heatColor maps a normalized density in [0, 1] onto the color ramp
+func heatColor(t float64) color.RGBA {
+	if t <= 0 || math.IsNaN(t) {
+		return heatColorStops[0]
+	}
+	if t >= 1 {
+		return heatColorStops[len(heatColorStops)-1]
+	}
+
+	pos := t * float64(len(heatColorStops)-1)
+	i := int(pos)
+	frac := pos - float64(i)
+	a, b := heatColorStops[i], heatColorStops[i+1]
+	lerp := func(x, y uint8) uint8 {
+		return uint8(float64(x) + (float64(y)-float64(x))*frac)
+	}
+	return color.RGBA{R: lerp(a.R, b.R), G: lerp(a.G, b.G), B: lerp(a.B, b.B), A: 255}
+}
+
+type playerLayerKey struct {
+	player events.PlayerKey
+	layer  HeatmapLayer
+}
+
+type teamLayerKey struct {
+	team  telemetry.Role
+	layer HeatmapLayer
+}
+
+// HeatmapBuilder accumulates per-player and per-team heatmaps from frames.
+// Players are identified by events.PlayerKey, so a slot reused by another
+// player mid-match starts a new heatmap. Scope it to a match or a round by
+// calling Reset at the boundary.
+type HeatmapBuilder struct {
+	config  GridConfig
+	players map[playerLayerKey]*Grid
+	teams   map[teamLayerKey]*Grid
+	names   map[events.PlayerKey]string
+	order   []events.PlayerKey // first sampled first
+}
+
+// NewHeatmapBuilder creates a new HeatmapBuilder
+func NewHeatmapBuilder(cfg GridConfig) *HeatmapBuilder {
+	return &HeatmapBuilder{
+		config:  cfg,
+		players: make(map[playerLayerKey]*Grid),
+		teams:   make(map[teamLayerKey]*Grid),
+		names:   make(map[events.PlayerKey]string),
+	}
+}
+
+// AddFrame samples player positions from the frame, plus catch and shot
+// positions for any events attached to it.
+func (b *HeatmapBuilder) AddFrame(frame *telemetry.LobbySessionStateFrame) {
+	if frame == nil || frame.GetSession() == nil {
+		return
+	}
+
+	players := make(map[int32]*apigame.TeamMember)
+	teams := make(map[int32]telemetry.Role)
+	for i, team := range frame.GetSession().GetTeams() {
+		role := events.TeamRole(i, team)
+		if role != telemetry.Role_ROLE_BLUE_TEAM && role != telemetry.Role_ROLE_ORANGE_TEAM {
+			continue
+		}
+		for _, player := range team.GetPlayers() {
+			slot := player.GetSlotNumber()
+			players[slot] = player
+			teams[slot] = role
+			key := events.KeyOf(player)
+			if _, ok := b.names[key]; !ok {
+				b.order = append(b.order, key)
+			}
+			b.names[key] = player.GetDisplayName()
+			b.sample(key, role, LayerPosition, player)
+		}
+	}
+
+	// Events name players by slot, which identifies them within the frame
+	for _, event := range frame.GetEvents() {
+		switch e := event.Event.(type) {
+		case *telemetry.LobbySessionEvent_DiscCaught:
+			slot := e.DiscCaught.GetPlayerSlot()
+			if player, ok := players[slot]; ok {
+				b.sample(events.KeyOf(player), teams[slot], LayerCatch, player)
+			}
+		case *telemetry.LobbySessionEvent_PlayerShotTaken:
+			slot := e.PlayerShotTaken.GetPlayerSlot()
+			if player, ok := players[slot]; ok {
+				b.sample(events.KeyOf(player), teams[slot], LayerShot, player)
+			}
+		}
+	}
+}
+
+func (b *HeatmapBuilder) sample(key events.PlayerKey, team telemetry.Role, layer HeatmapLayer, player *apigame.TeamMember) {
+	pos, ok := arena.PlayerPosition(player)
+	if !ok {
+		return
+	}
+
+	pk := playerLayerKey{player: key, layer: layer}
+	pg, ok := b.players[pk]
+	if !ok {
+		pg = NewGrid(b.config)
+		b.players[pk] = pg
+	}
+	pg.Add(pos.X, pos.Z)
+
+	tk := teamLayerKey{team: team, layer: layer}
+	tg, ok := b.teams[tk]
+	if !ok {
+		tg = NewGrid(b.config)
+		b.teams[tk] = tg
+	}
+	tg.Add(pos.X, pos.Z)
+}
+
+// Player returns the heatmap for a player, or an empty grid if the player
+// has no samples in that layer.
+func (b *HeatmapBuilder) Player(key events.PlayerKey, layer HeatmapLayer) *Grid {
+	if g, ok := b.players[playerLayerKey{player: key, layer: layer}]; ok {
+		return g
+	}
+	return NewGrid(b.config)
+}
+
+// Team returns the heatmap for a team, or an empty grid if the team has no
+// samples in that layer.
+func (b *HeatmapBuilder) Team(team telemetry.Role, layer HeatmapLayer) *Grid {
+	if g, ok := b.teams[teamLayerKey{team: team, layer: layer}]; ok {
+		return g
+	}
+	return NewGrid(b.config)
+}
+
+// PlayerName returns the last display name seen for a player
+func (b *HeatmapBuilder) PlayerName(key events.PlayerKey) string {
+	return b.names[key]
+}
+
+// Players returns every player that has been sampled, in the order they
+// were first seen
+func (b *HeatmapBuilder) Players() []events.PlayerKey {
+	return slices.Clone(b.order)
+}
+
+// Reset discards all accumulated samples
+func (b *HeatmapBuilder) Reset() {
+	b.players = make(map[playerLayerKey]*Grid)
+	b.teams = make(map[teamLayerKey]*Grid)
+	b.names = make(map[events.PlayerKey]string)
+	b.order = nil
+}
@@ -0,0 +1,292 @@
+package analytics
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"image/png"
+	"testing"
+
+	"github.com/echotools/nevr-capture/v3/pkg/events"
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// Test helper functions
+
+func createPositionedPlayer(slot int32, name string, x, z float64) *apigame.TeamMember {
+	return &apigame.TeamMember{
+		SlotNumber:  slot,
+		DisplayName: name,
+		Body:        &apigame.BodyPart{Position: []float64{x, 0, z}},
+	}
+}
+
+func createTeamsFrame(blue, orange []*apigame.TeamMember, events ...*telemetry.LobbySessionEvent) *telemetry.LobbySessionStateFrame {
+	return &telemetry.LobbySessionStateFrame{
+		Events: events,
+		Session: &apigame.SessionResponse{
+			Teams: []*apigame.Team{
+				{Players: blue},
+				{Players: orange},
+				{Players: nil},
+			},
+		},
+	}
+}
+
+// nameKey is the key of a test player without an account number
+func nameKey(name string) events.PlayerKey {
+	return events.PlayerKey{DisplayName: name}
+}
+
+func TestGrid_AddBinsSamples(t *testing.T) {
+	g := NewGrid(GridConfig{MinX: -2, MaxX: 2, MinZ: -2, MaxZ: 2, CellSize: 1})
+
+	if g.Cols != 4 || g.Rows != 4 {
+		t.Fatalf("expected 4x4 grid, got %dx%d", g.Cols, g.Rows)
+	}
+
+	g.Add(-1.5, -1.5)
+	g.Add(-1.2, -1.9)
+	g.Add(2, 2) // max edge belongs to the last cell
+
+	if got := g.At(0, 0); got != 2 {
+		t.Errorf("expected 2 samples in cell (0,0), got %f", got)
+	}
+	if got := g.At(3, 3); got != 1 {
+		t.Errorf("expected 1 sample in cell (3,3), got %f", got)
+	}
+	if g.Samples != 3 {
+		t.Errorf("expected 3 samples, got %d", g.Samples)
+	}
+	if g.Max() != 2 {
+		t.Errorf("expected max 2, got %f", g.Max())
+	}
+}
+
+func TestGrid_AddRejectsOutOfBounds(t *testing.T) {
+	g := NewGrid(GridConfig{MinX: 0, MaxX: 1, MinZ: 0, MaxZ: 1, CellSize: 1})
+
+	if g.Add(5, 0) {
+		t.Error("expected sample outside X bounds to be rejected")
+	}
+	if g.Add(0, -5) {
+		t.Error("expected sample outside Z bounds to be rejected")
+	}
+	if g.Samples != 0 {
+		t.Errorf("expected no samples, got %d", g.Samples)
+	}
+}
+
+func TestGrid_WriteJSON(t *testing.T) {
+	g := NewGrid(GridConfig{MinX: 0, MaxX: 2, MinZ: 0, MaxZ: 1, CellSize: 1})
+	g.Add(1.5, 0.5)
+
+	var buf bytes.Buffer
+	if err := g.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+
+	var decoded struct {
+		Cols    int         `json:"cols"`
+		Rows    int         `json:"rows"`
+		Samples int         `json:"samples"`
+		Cells   [][]float64 `json:"cells"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("failed to decode JSON: %v", err)
+	}
+	if decoded.Cols != 2 || decoded.Rows != 1 || decoded.Samples != 1 {
+		t.Errorf("unexpected header %+v", decoded)
+	}
+	if len(decoded.Cells) != 1 || decoded.Cells[0][1] != 1 {
+		t.Errorf("unexpected cells %v", decoded.Cells)
+	}
+}
+
+func TestGrid_WriteCSV(t *testing.T) {
+	g := NewGrid(GridConfig{MinX: 0, MaxX: 2, MinZ: 0, MaxZ: 2, CellSize: 1})
+	g.Add(0.5, 1.5)
+	g.Add(0.5, 1.5)
+
+	var buf bytes.Buffer
+	if err := g.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse CSV: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected header and 1 row, got %d records", len(records))
+	}
+	want := []string{"0", "1", "0.5", "1.5", "2"}
+	for i, v := range want {
+		if records[1][i] != v {
+			t.Errorf("column %d: expected %s, got %s", i, v, records[1][i])
+		}
+	}
+}
+
+func TestGrid_WritePNG(t *testing.T) {
+	g := NewGrid(GridConfig{MinX: 0, MaxX: 3, MinZ: 0, MaxZ: 2, CellSize: 1})
+	g.Add(0.5, 0.5)
+
+	var buf bytes.Buffer
+	if err := g.WritePNG(&buf, 4); err != nil {
+		t.Fatalf("WritePNG failed: %v", err)
+	}
+
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("failed to decode PNG: %v", err)
+	}
+	bounds := img.Bounds()
+	if bounds.Dx() != 12 || bounds.Dy() != 8 {
+		t.Fatalf("expected 12x8 image, got %dx%d", bounds.Dx(), bounds.Dy())
+	}
+
+	// Row 0 is drawn at the bottom, so the hot cell is in the bottom-left corner
+	hot := heatColor(1)
+	r, gr, b, _ := img.At(0, 7).RGBA()
+	if uint8(r>>8) != hot.R || uint8(gr>>8) != hot.G || uint8(b>>8) != hot.B {
+		t.Errorf("expected hot color in bottom-left corner, got %v", img.At(0, 7))
+	}
+	cold := heatColor(0)
+	r, gr, b, _ = img.At(11, 0).RGBA()
+	if uint8(r>>8) != cold.R || uint8(gr>>8) != cold.G || uint8(b>>8) != cold.B {
+		t.Errorf("expected empty color in top-right corner, got %v", img.At(11, 0))
+	}
+}
+
+func TestHeatColor_Endpoints(t *testing.T) {
+	if heatColor(0) != heatColorStops[0] {
+		t.Error("expected first color stop for 0")
+	}
+	if heatColor(1) != heatColorStops[len(heatColorStops)-1] {
+		t.Error("expected last color stop for 1")
+	}
+	if heatColor(2) != heatColorStops[len(heatColorStops)-1] {
+		t.Error("expected values above 1 to clamp")
+	}
+}
+
+func TestHeatmapBuilder_PlayerAndTeamPositions(t *testing.T) {
+	b := NewHeatmapBuilder(DefaultGridConfig())
+
+	b.AddFrame(createTeamsFrame(
+		[]*apigame.TeamMember{createPositionedPlayer(0, "Blue1", 0, -10)},
+		[]*apigame.TeamMember{createPositionedPlayer(5, "Orange1", 0, 10)},
+	))
+	b.AddFrame(createTeamsFrame(
+		[]*apigame.TeamMember{createPositionedPlayer(0, "Blue1", 0, -10)},
+		[]*apigame.TeamMember{createPositionedPlayer(5, "Orange1", 0, 10)},
+	))
+
+	if got := b.Player(nameKey("Blue1"), LayerPosition).Samples; got != 2 {
+		t.Errorf("expected 2 position samples for Blue1, got %d", got)
+	}
+	if got := b.Team(telemetry.Role_ROLE_ORANGE_TEAM, LayerPosition).Samples; got != 2 {
+		t.Errorf("expected 2 orange samples, got %d", got)
+	}
+	if got := b.Team(telemetry.Role_ROLE_BLUE_TEAM, LayerPosition).Samples; got != 2 {
+		t.Errorf("expected 2 blue samples, got %d", got)
+	}
+	if b.PlayerName(nameKey("Orange1")) != "Orange1" {
+		t.Errorf("expected Orange1, got %s", b.PlayerName(nameKey("Orange1")))
+	}
+	if players := b.Players(); len(players) != 2 || players[0] != nameKey("Blue1") || players[1] != nameKey("Orange1") {
+		t.Errorf("unexpected players %v", players)
+	}
+}
+
+func TestHeatmapBuilder_SlotReuse(t *testing.T) {
+	b := NewHeatmapBuilder(DefaultGridConfig())
+
+	first := createPositionedPlayer(0, "First", 0, -10)
+	first.AccountNumber = 1
+	second := createPositionedPlayer(0, "Second", 0, 10)
+	second.AccountNumber = 2
+	b.AddFrame(createTeamsFrame([]*apigame.TeamMember{first}, nil))
+	b.AddFrame(createTeamsFrame([]*apigame.TeamMember{second}, nil))
+
+	for _, player := range []*apigame.TeamMember{first, second} {
+		key := events.KeyOf(player)
+		if got := b.Player(key, LayerPosition).Samples; got != 1 {
+			t.Errorf("expected 1 sample for %s, got %d", player.DisplayName, got)
+		}
+		if got := b.PlayerName(key); got != player.DisplayName {
+			t.Errorf("expected name %s, got %s", player.DisplayName, got)
+		}
+	}
+	if players := b.Players(); len(players) != 2 {
+		t.Errorf("expected a heatmap per player of the slot, got %v", players)
+	}
+}
+
+func TestHeatmapBuilder_SkipsSpectators(t *testing.T) {
+	b := NewHeatmapBuilder(DefaultGridConfig())
+
+	frame := createTeamsFrame(nil, nil)
+	frame.Session.Teams[2].Players = []*apigame.TeamMember{createPositionedPlayer(9, "Spectator", 0, 0)}
+	b.AddFrame(frame)
+
+	if len(b.Players()) != 0 {
+		t.Errorf("expected spectators to be skipped, got players %v", b.Players())
+	}
+}
+
+func TestHeatmapBuilder_CatchAndShotLayers(t *testing.T) {
+	b := NewHeatmapBuilder(DefaultGridConfig())
+
+	b.AddFrame(createTeamsFrame(
+		[]*apigame.TeamMember{createPositionedPlayer(1, "Blue", 3, 20)},
+		nil,
+		&telemetry.LobbySessionEvent{
+			Event: &telemetry.LobbySessionEvent_DiscCaught{
+				DiscCaught: &telemetry.DiscCaught{PlayerSlot: 1},
+			},
+		},
+		&telemetry.LobbySessionEvent{
+			Event: &telemetry.LobbySessionEvent_PlayerShotTaken{
+				PlayerShotTaken: &telemetry.PlayerShotTaken{PlayerSlot: 1},
+			},
+		},
+	))
+
+	if got := b.Player(nameKey("Blue"), LayerCatch).Samples; got != 1 {
+		t.Errorf("expected 1 catch sample, got %d", got)
+	}
+	if got := b.Player(nameKey("Blue"), LayerShot).Samples; got != 1 {
+		t.Errorf("expected 1 shot sample, got %d", got)
+	}
+	if got := b.Team(telemetry.Role_ROLE_BLUE_TEAM, LayerShot).Samples; got != 1 {
+		t.Errorf("expected 1 blue shot sample, got %d", got)
+	}
+}
+
+func TestHeatmapBuilder_Reset(t *testing.T) {
+	b := NewHeatmapBuilder(DefaultGridConfig())
+	b.AddFrame(createTeamsFrame([]*apigame.TeamMember{createPositionedPlayer(0, "Blue1", 0, 0)}, nil))
+
+	b.Reset()
+
+	if got := b.Player(nameKey("Blue1"), LayerPosition).Samples; got != 0 {
+		t.Errorf("expected no samples after reset, got %d", got)
+	}
+	if len(b.Players()) != 0 {
+		t.Errorf("expected no players after reset, got %v", b.Players())
+	}
+}
+
+func TestHeatmapBuilder_NilFrame(t *testing.T) {
+	b := NewHeatmapBuilder(DefaultGridConfig())
+	b.AddFrame(nil)
+	b.AddFrame(&telemetry.LobbySessionStateFrame{})
+
+	if len(b.Players()) != 0 {
+		t.Errorf("expected no players, got %v", b.Players())
+	}
+}
@@ -0,0 +1,422 @@
+package analytics
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/echotools/nevr-capture/v3/pkg/arena"
+	"github.com/echotools/nevr-capture/v3/pkg/events"
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+const (
+	// DefaultShotResolveWindow is how long a shot may stay unresolved before
+	// it is counted as a miss
+	DefaultShotResolveWindow = 5 * time.Second
+
+	// throwPairWindow is how far apart a DiscThrown and a PlayerShotTaken
+	// may be and still describe the same release
+	throwPairWindow = time.Second
+)
+
+// Shot is a single shot attempt and its outcome
+type Shot struct {
+	Player     events.PlayerKey `json:"player"`
+	PlayerSlot int32            `json:"player_slot"`
+	PlayerName string           `json:"player_name"`
+	Team       string           `json:"team"`
+	FrameIndex uint32           `json:"frame_index"`
+	Timestamp  time.Time        `json:"timestamp,omitzero"`
+
+	// Shooter position at the time of the shot
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+
+	// Speed is the release speed of the throw, or the disc speed reported
+	// with the goal when no throw details were captured
+	Speed float64 `json:"speed"`
+	// Distance is the distance thrown as reported with the goal, or the
+	// distance from the shooter to the goal they attack
+	Distance float64 `json:"distance"`
+
+	Scored   bool  `json:"scored"`
+	Points   int32 `json:"points"`
+	Resolved bool  `json:"resolved"`
+
+	Throw *apigame.LastThrowInfo `json:"-"`
+
+	at       events.FrameTime
+	hasScore bool
+}
+
+// ShotStats summarizes the shots taken by one player
+type ShotStats struct {
+	Player             events.PlayerKey `json:"player"`
+	PlayerSlot         int32            `json:"player_slot"`
+	PlayerName         string           `json:"player_name"`
+	Shots              int              `json:"shots"`
+	Goals              int              `json:"goals"`
+	Points             int32            `json:"points"`
+	ShootingPercentage float64          `json:"shooting_percentage"`
+	AverageSpeed       float64          `json:"average_speed"`
+	AverageDistance    float64          `json:"average_distance"`
+}
+
+type throwRecord struct {
+	details *apigame.LastThrowInfo
+	at      events.FrameTime
+}
+
+// elapsed returns the time from start to now. Frames without a timestamp
+// fall back to the game clock, so shots still age out of the resolve window.
+func elapsed(now, start events.FrameTime) time.Duration {
+	return time.Duration(now.Since(start) * float64(time.Second))
+}
+
+type shotPlayer struct {
+	key    events.PlayerKey
+	member *apigame.TeamMember
+	team   telemetry.Role
+}
+
+// ShotTracker pairs PlayerShotTaken events with the throw that produced
+// them and the goal (if any) that followed. Feed it frames that carry
+// detected events, such as frames read back from a .nevrcap file.
+type ShotTracker struct {
+	resolveWindow time.Duration
+
+	shots      []*Shot
+	pending    []*Shot
+	lastThrows map[events.PlayerKey]throwRecord
+	players    map[int32]shotPlayer // players of the current frame by slot
+}
+
+// NewShotTracker creates a new ShotTracker. A zero resolve window uses
+// DefaultShotResolveWindow.
+func NewShotTracker(resolveWindow time.Duration) *ShotTracker {
+	if resolveWindow <= 0 {
+		resolveWindow = DefaultShotResolveWindow
+	}
+	return &ShotTracker{
+		resolveWindow: resolveWindow,
+		lastThrows:    make(map[events.PlayerKey]throwRecord),
+		players:       make(map[int32]shotPlayer),
+	}
+}
+
+// AddFrame processes the events attached to a frame
+func (t *ShotTracker) AddFrame(frame *telemetry.LobbySessionStateFrame) {
+	if frame == nil || frame.GetSession() == nil {
+		return
+	}
+
+	now := events.FrameTimeOf(frame)
+
+	clear(t.players)
+	for i, team := range frame.GetSession().GetTeams() {
+		for _, player := range team.GetPlayers() {
+			t.players[player.GetSlotNumber()] = shotPlayer{key: events.KeyOf(player), member: player, team: events.TeamRole(i, team)}
+		}
+	}
+
+	// Shots that have been in the air too long were missed
+	t.expire(now)
+
+	for _, event := range frame.GetEvents() {
+		switch e := event.Event.(type) {
+		case *telemetry.LobbySessionEvent_DiscThrown:
+			t.handleThrow(e.DiscThrown, now)
+		case *telemetry.LobbySessionEvent_PlayerShotTaken:
+			t.handleShot(e.PlayerShotTaken, frame.GetFrameIndex(), now)
+		case *telemetry.LobbySessionEvent_DiscCaught:
+			// A catch after the shot means it was saved or recovered
+			t.resolveBefore(frame.GetFrameIndex())
+		case *telemetry.LobbySessionEvent_GoalScored:
+			t.handleGoalScored(e.GoalScored.GetScoreDetails(), now)
+		case *telemetry.LobbySessionEvent_PlayerGoal:
+			t.handlePlayerGoal(e.PlayerGoal)
+		case *telemetry.LobbySessionEvent_RoundEnded, *telemetry.LobbySessionEvent_MatchEnded:
+			t.Flush()
+		}
+	}
+}
+
+// keyOf returns the identity of the player in a slot of the current frame.
+// Events name players by slot, which is only stable within a frame.
+func (t *ShotTracker) keyOf(slot int32) events.PlayerKey {
+	if p, ok := t.players[slot]; ok {
+		return p.key
+	}
+	return events.PlayerKey{SlotNumber: slot}
+}
+
+func (t *ShotTracker) handleThrow(thrown *telemetry.DiscThrown, now events.FrameTime) {
+	key := t.keyOf(thrown.GetPlayerSlot())
+	t.lastThrows[key] = throwRecord{details: thrown.GetThrowDetails(), at: now}
+
+	// The throw may be reported after the shot counter incremented
+	for i := len(t.pending) - 1; i >= 0; i-- {
+		shot := t.pending[i]
+		if shot.Player == key && shot.Throw == nil && elapsed(now, shot.at) <= throwPairWindow {
+			t.applyThrow(shot, thrown.GetThrowDetails())
+			break
+		}
+	}
+}
+
+func (t *ShotTracker) handleShot(taken *telemetry.PlayerShotTaken, frameIndex uint32, now events.FrameTime) {
+	slot := taken.GetPlayerSlot()
+	shot := &Shot{
+		Player:     t.keyOf(slot),
+		PlayerSlot: slot,
+		FrameIndex: frameIndex,
+		Timestamp:  now.Wall(),
+		at:         now,
+	}
+
+	if p, ok := t.players[slot]; ok {
+		shot.PlayerName = p.member.GetDisplayName()
+		shot.Team = p.team.String()
+		if pos, ok := arena.PlayerPosition(p.member); ok {
+			shot.X, shot.Y, shot.Z = pos.X, pos.Y, pos.Z
+			shot.Distance = pos.Dist(targetGoal(p.team))
+		}
+	}
+
+	if rec, ok := t.lastThrows[shot.Player]; ok && elapsed(now, rec.at) <= throwPairWindow {
+		t.applyThrow(shot, rec.details)
+	}
+
+	t.shots = append(t.shots, shot)
+	t.pending = append(t.pending, shot)
+}
+
+func (t *ShotTracker) applyThrow(shot *Shot, details *apigame.LastThrowInfo) {
+	shot.Throw = details
+	if !shot.hasScore {
+		shot.Speed = details.GetTotalSpeed()
+	}
+}
+
+func (t *ShotTracker) handleGoalScored(score *apigame.LastScore, now events.FrameTime) {
+	if score == nil {
+		return
+	}
+
+	byScorer := t.scorer(score.GetPersonScored())
+	shot := t.takePending(byScorer)
+	if shot == nil {
+		// The PlayerGoal may have resolved the shot already; enrich it
+		for i := len(t.shots) - 1; i >= 0; i-- {
+			s := t.shots[i]
+			if s.Scored && !s.hasScore && byScorer(s) && elapsed(now, s.at) <= t.resolveWindow {
+				shot = s
+				break
+			}
+		}
+	}
+	if shot == nil {
+		return
+	}
+
+	shot.Scored = true
+	shot.Resolved = true
+	shot.hasScore = true
+	shot.Points = score.GetPointAmount()
+	if shot.Throw == nil && score.GetDiscSpeed() > 0 {
+		shot.Speed = score.GetDiscSpeed()
+	}
+	if score.GetDistanceThrown() > 0 {
+		shot.Distance = score.GetDistanceThrown()
+	}
+
+	// Play restarts after a goal
+	t.Flush()
+}
+
+// scorer matches the shots of the player a goal was credited to. Goals only
+// name the scorer, so the name is resolved to a player of the current frame,
+// falling back to the name recorded with the shot.
+func (t *ShotTracker) scorer(name string) func(*Shot) bool {
+	for _, p := range t.players {
+		if p.member.GetDisplayName() == name {
+			return func(s *Shot) bool { return s.Player == p.key }
+		}
+	}
+	return func(s *Shot) bool { return s.PlayerName == name }
+}
+
+func (t *ShotTracker) handlePlayerGoal(goal *telemetry.PlayerGoal) {
+	key := t.keyOf(goal.GetPlayerSlot())
+	shot := t.takePending(func(s *Shot) bool { return s.Player == key })
+	if shot == nil {
+		return
+	}
+	shot.Scored = true
+	shot.Resolved = true
+	shot.Points = goal.GetPoints()
+	t.Flush()
+}
+
+// takePending removes and returns the most recent pending shot matching fn
+func (t *ShotTracker) takePending(fn func(*Shot) bool) *Shot {
+	for i := len(t.pending) - 1; i >= 0; i-- {
+		if fn(t.pending[i]) {
+			shot := t.pending[i]
+			t.pending = append(t.pending[:i], t.pending[i+1:]...)
+			return shot
+		}
+	}
+	return nil
+}
+
+// expire resolves shots older than the resolve window as misses
+func (t *ShotTracker) expire(now events.FrameTime) {
+	kept := t.pending[:0]
+	for _, shot := range t.pending {
+		if elapsed(now, shot.at) > t.resolveWindow {
+			shot.Resolved = true
+			continue
+		}
+		kept = append(kept, shot)
+	}
+	t.pending = kept
+}
+
+// resolveBefore resolves shots taken before the given frame as misses
+func (t *ShotTracker) resolveBefore(frameIndex uint32) {
+	kept := t.pending[:0]
+	for _, shot := range t.pending {
+		if shot.FrameIndex < frameIndex {
+			shot.Resolved = true
+			continue
+		}
+		kept = append(kept, shot)
+	}
+	t.pending = kept
+}
+
+// Flush resolves all pending shots as misses. Call it at the end of a capture.
+func (t *ShotTracker) Flush() {
+	for _, shot := range t.pending {
+		shot.Resolved = true
+	}
+	t.pending = t.pending[:0]
+}
+
+// Shots returns every shot seen so far in the order they were taken
+func (t *ShotTracker) Shots() []*Shot {
+	return t.shots
+}
+
+// PlayerStats returns per-player shooting statistics in the order of each
+// player's first shot. PlayerSlot is the slot of the player's latest shot.
+func (t *ShotTracker) PlayerStats() []ShotStats {
+	var stats []*ShotStats
+	byPlayer := make(map[events.PlayerKey]*ShotStats)
+	speedSamples := make(map[events.PlayerKey]int)
+	distanceSamples := make(map[events.PlayerKey]int)
+
+	for _, shot := range t.shots {
+		st, ok := byPlayer[shot.Player]
+		if !ok {
+			st = &ShotStats{Player: shot.Player}
+			byPlayer[shot.Player] = st
+			stats = append(stats, st)
+		}
+		st.PlayerSlot = shot.PlayerSlot
+		if shot.PlayerName != "" {
+			st.PlayerName = shot.PlayerName
+		}
+		st.Shots++
+		if shot.Scored {
+			st.Goals++
+			st.Points += shot.Points
+		}
+		if shot.Speed > 0 {
+			st.AverageSpeed += shot.Speed
+			speedSamples[shot.Player]++
+		}
+		if shot.Distance > 0 {
+			st.AverageDistance += shot.Distance
+			distanceSamples[shot.Player]++
+		}
+	}
+
+	out := make([]ShotStats, 0, len(stats))
+	for _, st := range stats {
+		st.ShootingPercentage = float64(st.Goals) / float64(st.Shots) * 100
+		if n := speedSamples[st.Player]; n > 0 {
+			st.AverageSpeed /= float64(n)
+		}
+		if n := distanceSamples[st.Player]; n > 0 {
+			st.AverageDistance /= float64(n)
+		}
+		out = append(out, *st)
+	}
+	return out
+}
+
+// WriteShotChartJSON writes every shot as a JSON array
+func (t *ShotTracker) WriteShotChartJSON(w io.Writer) error {
+	shots := t.shots
+	if shots == nil {
+		shots = []*Shot{}
+	}
+	return json.NewEncoder(w).Encode(shots)
+}
+
+// WriteShotChartCSV writes one row per shot
+func (t *ShotTracker) WriteShotChartCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	header := []string{"frame_index", "timestamp", "player_slot", "player_name", "team", "x", "y", "z", "speed", "distance", "scored", "points"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+	for _, shot := range t.shots {
+		var timestamp string
+		if !shot.Timestamp.IsZero() {
+			timestamp = shot.Timestamp.Format(time.RFC3339Nano)
+		}
+		if err := cw.Write([]string{
+			strconv.FormatUint(uint64(shot.FrameIndex), 10),
+			timestamp,
+			strconv.Itoa(int(shot.PlayerSlot)),
+			shot.PlayerName,
+			shot.Team,
+			f(shot.X),
+			f(shot.Y),
+			f(shot.Z),
+			f(shot.Speed),
+			f(shot.Distance),
+			strconv.FormatBool(shot.Scored),
+			strconv.Itoa(int(shot.Points)),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Reset discards all shots and tracking state
+func (t *ShotTracker) Reset() {
+	t.shots = nil
+	t.pending = nil
+	clear(t.lastThrows)
+	clear(t.players)
+}
+
+// targetGoal returns the goal a team shoots at
+func targetGoal(team telemetry.Role) arena.Vec3 {
+	if team == telemetry.Role_ROLE_ORANGE_TEAM {
+		return arena.BlueGoal
+	}
+	return arena.OrangeGoal
+}
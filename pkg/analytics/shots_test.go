@@ -0,0 +1,332 @@
+package analytics
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var shotTestStart = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func createShotFrame(index uint32, offset time.Duration, events ...*telemetry.LobbySessionEvent) *telemetry.LobbySessionStateFrame {
+	frame := createTeamsFrame(
+		[]*apigame.TeamMember{createPositionedPlayer(0, "BlueShooter", 0, 26)},
+		[]*apigame.TeamMember{createPositionedPlayer(5, "OrangeShooter", 0, -16)},
+		events...,
+	)
+	frame.FrameIndex = index
+	frame.Timestamp = timestamppb.New(shotTestStart.Add(offset))
+	return frame
+}
+
+func shotTakenEvent(slot int32) *telemetry.LobbySessionEvent {
+	return &telemetry.LobbySessionEvent{
+		Event: &telemetry.LobbySessionEvent_PlayerShotTaken{
+			PlayerShotTaken: &telemetry.PlayerShotTaken{PlayerSlot: slot},
+		},
+	}
+}
+
+func discThrownEvent(slot int32, speed float64) *telemetry.LobbySessionEvent {
+	return &telemetry.LobbySessionEvent{
+		Event: &telemetry.LobbySessionEvent_DiscThrown{
+			DiscThrown: &telemetry.DiscThrown{
+				PlayerSlot:   slot,
+				ThrowDetails: &apigame.LastThrowInfo{TotalSpeed: speed},
+			},
+		},
+	}
+}
+
+func goalScoredEvent(name string, points int32, distance float64) *telemetry.LobbySessionEvent {
+	return &telemetry.LobbySessionEvent{
+		Event: &telemetry.LobbySessionEvent_GoalScored{
+			GoalScored: &telemetry.GoalScored{
+				ScoreDetails: &apigame.LastScore{
+					PersonScored:   name,
+					PointAmount:    points,
+					DistanceThrown: distance,
+					DiscSpeed:      18,
+				},
+			},
+		},
+	}
+}
+
+func discCaughtEvent(slot int32) *telemetry.LobbySessionEvent {
+	return &telemetry.LobbySessionEvent{
+		Event: &telemetry.LobbySessionEvent_DiscCaught{
+			DiscCaught: &telemetry.DiscCaught{PlayerSlot: slot},
+		},
+	}
+}
+
+func TestShotTracker_ShotFollowedByGoal(t *testing.T) {
+	tracker := NewShotTracker(0)
+
+	tracker.AddFrame(createShotFrame(1, 0, discThrownEvent(0, 15), shotTakenEvent(0)))
+	tracker.AddFrame(createShotFrame(2, time.Second, goalScoredEvent("BlueShooter", 3, 12.5)))
+
+	shots := tracker.Shots()
+	if len(shots) != 1 {
+		t.Fatalf("expected 1 shot, got %d", len(shots))
+	}
+	shot := shots[0]
+	if !shot.Scored || !shot.Resolved {
+		t.Errorf("expected scored, resolved shot, got %+v", shot)
+	}
+	if shot.Points != 3 {
+		t.Errorf("expected 3 points, got %d", shot.Points)
+	}
+	if shot.Speed != 15 {
+		t.Errorf("expected release speed 15, got %f", shot.Speed)
+	}
+	if shot.Distance != 12.5 {
+		t.Errorf("expected distance thrown 12.5, got %f", shot.Distance)
+	}
+	if shot.PlayerName != "BlueShooter" || shot.Team != telemetry.Role_ROLE_BLUE_TEAM.String() {
+		t.Errorf("unexpected shooter %s (%s)", shot.PlayerName, shot.Team)
+	}
+	if shot.Z != 26 {
+		t.Errorf("expected shooter position z=26, got %f", shot.Z)
+	}
+}
+
+func TestShotTracker_ThrowReportedAfterShot(t *testing.T) {
+	tracker := NewShotTracker(0)
+
+	tracker.AddFrame(createShotFrame(1, 0, shotTakenEvent(0)))
+	tracker.AddFrame(createShotFrame(2, 100*time.Millisecond, discThrownEvent(0, 21)))
+
+	if got := tracker.Shots()[0].Speed; got != 21 {
+		t.Errorf("expected throw to be paired after the shot, got speed %f", got)
+	}
+}
+
+func TestShotTracker_CatchResolvesMiss(t *testing.T) {
+	tracker := NewShotTracker(0)
+
+	tracker.AddFrame(createShotFrame(1, 0, shotTakenEvent(0)))
+	tracker.AddFrame(createShotFrame(2, 500*time.Millisecond, discCaughtEvent(5)))
+	// A later goal must not be credited to the saved shot
+	tracker.AddFrame(createShotFrame(3, time.Second, goalScoredEvent("BlueShooter", 2, 5)))
+
+	shot := tracker.Shots()[0]
+	if shot.Scored {
+		t.Error("expected saved shot to be a miss")
+	}
+	if !shot.Resolved {
+		t.Error("expected saved shot to be resolved")
+	}
+}
+
+func TestShotTracker_ExpiresAfterWindow(t *testing.T) {
+	tracker := NewShotTracker(2 * time.Second)
+
+	tracker.AddFrame(createShotFrame(1, 0, shotTakenEvent(0)))
+	tracker.AddFrame(createShotFrame(2, 3*time.Second))
+
+	shot := tracker.Shots()[0]
+	if !shot.Resolved || shot.Scored {
+		t.Errorf("expected shot to expire as a miss, got %+v", shot)
+	}
+}
+
+// Frames without timestamps age shots by the game clock
+func TestShotTracker_ExpiresWithoutTimestamps(t *testing.T) {
+	tracker := NewShotTracker(2 * time.Second)
+
+	clockFrame := func(index uint32, clock float64, events ...*telemetry.LobbySessionEvent) *telemetry.LobbySessionStateFrame {
+		frame := createShotFrame(index, 0, events...)
+		frame.Timestamp = nil
+		frame.Session.GameClock = clock
+		return frame
+	}
+
+	tracker.AddFrame(clockFrame(1, 120, discThrownEvent(0, 15), shotTakenEvent(0)))
+	tracker.AddFrame(clockFrame(2, 119))
+
+	shot := tracker.Shots()[0]
+	if shot.Resolved {
+		t.Fatal("expected shot to stay pending inside the window")
+	}
+	if shot.Speed != 15 {
+		t.Errorf("expected throw to be paired, got speed %f", shot.Speed)
+	}
+	if !shot.Timestamp.IsZero() {
+		t.Errorf("expected no timestamp, got %v", shot.Timestamp)
+	}
+
+	tracker.AddFrame(clockFrame(3, 117))
+	if !shot.Resolved || shot.Scored {
+		t.Errorf("expected shot to expire as a miss, got %+v", shot)
+	}
+}
+
+func TestShotTracker_PlayerGoalFallback(t *testing.T) {
+	tracker := NewShotTracker(0)
+
+	tracker.AddFrame(createShotFrame(1, 0, shotTakenEvent(5)))
+	tracker.AddFrame(createShotFrame(2, time.Second, &telemetry.LobbySessionEvent{
+		Event: &telemetry.LobbySessionEvent_PlayerGoal{
+			PlayerGoal: &telemetry.PlayerGoal{PlayerSlot: 5, Points: 2},
+		},
+	}))
+	tracker.AddFrame(createShotFrame(3, 1100*time.Millisecond, goalScoredEvent("OrangeShooter", 2, 9)))
+
+	shot := tracker.Shots()[0]
+	if !shot.Scored || shot.Points != 2 {
+		t.Errorf("expected PlayerGoal to score the shot, got %+v", shot)
+	}
+	if shot.Distance != 9 {
+		t.Errorf("expected GoalScored details to enrich the shot, got distance %f", shot.Distance)
+	}
+}
+
+func TestShotTracker_DistanceToTargetGoal(t *testing.T) {
+	tracker := NewShotTracker(0)
+
+	// Orange shoots at the blue goal at negative Z
+	tracker.AddFrame(createShotFrame(1, 0, shotTakenEvent(5)))
+	tracker.Flush()
+
+	if got := tracker.Shots()[0].Distance; math.Abs(got-20) > 1e-9 {
+		t.Errorf("expected distance 20 to the blue goal, got %f", got)
+	}
+}
+
+func TestShotTracker_PlayerStats(t *testing.T) {
+	tracker := NewShotTracker(0)
+
+	tracker.AddFrame(createShotFrame(1, 0, discThrownEvent(0, 10), shotTakenEvent(0)))
+	tracker.AddFrame(createShotFrame(2, time.Second, goalScoredEvent("BlueShooter", 2, 10)))
+	tracker.AddFrame(createShotFrame(3, 2*time.Second, discThrownEvent(0, 20), shotTakenEvent(0)))
+	tracker.AddFrame(createShotFrame(4, 3*time.Second, discCaughtEvent(5)))
+
+	stats := tracker.PlayerStats()
+	if len(stats) != 1 {
+		t.Fatalf("expected stats for 1 player, got %d", len(stats))
+	}
+	st := stats[0]
+	if st.Shots != 2 || st.Goals != 1 {
+		t.Errorf("expected 2 shots and 1 goal, got %+v", st)
+	}
+	if st.ShootingPercentage != 50 {
+		t.Errorf("expected 50%% shooting, got %f", st.ShootingPercentage)
+	}
+	if st.AverageSpeed != 15 {
+		t.Errorf("expected average speed 15, got %f", st.AverageSpeed)
+	}
+	if st.Points != 2 {
+		t.Errorf("expected 2 points, got %d", st.Points)
+	}
+}
+
+func TestShotTracker_SlotReuse(t *testing.T) {
+	tracker := NewShotTracker(0)
+
+	tracker.AddFrame(createShotFrame(1, 0, discThrownEvent(0, 10), shotTakenEvent(0)))
+
+	// BlueShooter leaves and a new player takes slot 0
+	frame := createTeamsFrame(
+		[]*apigame.TeamMember{createPositionedPlayer(0, "Newcomer", 0, 26)},
+		[]*apigame.TeamMember{createPositionedPlayer(5, "OrangeShooter", 0, -16)},
+		shotTakenEvent(0),
+	)
+	frame.FrameIndex = 2
+	frame.Timestamp = timestamppb.New(shotTestStart.Add(100 * time.Millisecond))
+	tracker.AddFrame(frame)
+
+	frame = createTeamsFrame(
+		[]*apigame.TeamMember{createPositionedPlayer(0, "Newcomer", 0, 26)},
+		[]*apigame.TeamMember{createPositionedPlayer(5, "OrangeShooter", 0, -16)},
+		goalScoredEvent("Newcomer", 2, 10),
+	)
+	frame.FrameIndex = 3
+	frame.Timestamp = timestamppb.New(shotTestStart.Add(time.Second))
+	tracker.AddFrame(frame)
+
+	shots := tracker.Shots()
+	if len(shots) != 2 {
+		t.Fatalf("expected 2 shots, got %d", len(shots))
+	}
+	if newcomer := shots[1]; newcomer.Player != nameKey("Newcomer") || newcomer.Throw != nil || !newcomer.Scored {
+		t.Errorf("expected the newcomer's shot to score without the previous occupant's throw, got %+v", newcomer)
+	}
+
+	stats := tracker.PlayerStats()
+	if len(stats) != 2 {
+		t.Fatalf("expected stats for 2 players sharing slot 0, got %d", len(stats))
+	}
+	if stats[0].Player != nameKey("BlueShooter") || stats[0].Goals != 0 {
+		t.Errorf("expected BlueShooter first without goals, got %+v", stats[0])
+	}
+	if stats[1].Player != nameKey("Newcomer") || stats[1].Goals != 1 {
+		t.Errorf("expected Newcomer second with 1 goal, got %+v", stats[1])
+	}
+}
+
+func TestShotTracker_RoundEndFlushesPending(t *testing.T) {
+	tracker := NewShotTracker(0)
+
+	tracker.AddFrame(createShotFrame(1, 0, shotTakenEvent(0)))
+	tracker.AddFrame(createShotFrame(2, 0, &telemetry.LobbySessionEvent{
+		Event: &telemetry.LobbySessionEvent_RoundEnded{RoundEnded: &telemetry.RoundEnded{}},
+	}))
+
+	if !tracker.Shots()[0].Resolved {
+		t.Error("expected round end to resolve pending shots")
+	}
+}
+
+func TestShotTracker_WriteShotChart(t *testing.T) {
+	tracker := NewShotTracker(0)
+	tracker.AddFrame(createShotFrame(1, 0, discThrownEvent(0, 10), shotTakenEvent(0)))
+	tracker.AddFrame(createShotFrame(2, time.Second, goalScoredEvent("BlueShooter", 2, 10)))
+
+	var jsonBuf bytes.Buffer
+	if err := tracker.WriteShotChartJSON(&jsonBuf); err != nil {
+		t.Fatalf("WriteShotChartJSON failed: %v", err)
+	}
+	var decoded []map[string]any
+	if err := json.Unmarshal(jsonBuf.Bytes(), &decoded); err != nil {
+		t.Fatalf("failed to decode JSON: %v", err)
+	}
+	if len(decoded) != 1 || decoded[0]["scored"] != true {
+		t.Errorf("unexpected JSON shot chart %v", decoded)
+	}
+
+	var csvBuf bytes.Buffer
+	if err := tracker.WriteShotChartCSV(&csvBuf); err != nil {
+		t.Fatalf("WriteShotChartCSV failed: %v", err)
+	}
+	records, err := csv.NewReader(&csvBuf).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse CSV: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected header and 1 row, got %d", len(records))
+	}
+	if records[1][3] != "BlueShooter" || records[1][10] != "true" {
+		t.Errorf("unexpected CSV row %v", records[1])
+	}
+}
+
+func TestShotTracker_EmptyChart(t *testing.T) {
+	tracker := NewShotTracker(0)
+
+	var buf bytes.Buffer
+	if err := tracker.WriteShotChartJSON(&buf); err != nil {
+		t.Fatalf("WriteShotChartJSON failed: %v", err)
+	}
+	if got := bytes.TrimSpace(buf.Bytes()); string(got) != "[]" {
+		t.Errorf("expected empty array, got %s", got)
+	}
+}
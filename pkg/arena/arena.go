@@ -0,0 +1,110 @@
+// Package arena describes the Echo Arena playing field and provides the small
+// amount of vector math needed to reason about positions in session frames.
+//
+// Coordinates follow the game API: X runs across the arena, Y is height and
+// Z runs goal to goal. The blue team defends the goal at negative Z and the
+// orange team defends the goal at positive Z.
+package arena
+
+import (
+	"math"
+
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+)
+
+// Approximate playable bounds of the arena in meters
+const (
+	MinX = -16.0
+	MaxX = 16.0
+	MinY = -10.0
+	MaxY = 10.0
+	MinZ = -40.0
+	MaxZ = 40.0
+
+	// GoalZ is the distance of each goal's center from the middle of the arena
+	GoalZ = 36.0
+)
+
+var (
+	// BlueGoal is the center of the goal defended by the blue team
+	BlueGoal = Vec3{Z: -GoalZ}
+	// OrangeGoal is the center of the goal defended by the orange team
+	OrangeGoal = Vec3{Z: GoalZ}
+)
+
+// Vec3 is a point or direction in arena space
+type Vec3 struct {
+	X, Y, Z float64
+}
+
+// VecFromSlice converts an API position/velocity slice to a Vec3.
+// Returns false if the slice has fewer than three components.
+func VecFromSlice(v []float64) (Vec3, bool) {
+	if len(v) < 3 {
+		return Vec3{}, false
+	}
+	return Vec3{X: v[0], Y: v[1], Z: v[2]}, true
+}
+
+// Add returns v + o
+func (v Vec3) Add(o Vec3) Vec3 {
+	return Vec3{X: v.X + o.X, Y: v.Y + o.Y, Z: v.Z + o.Z}
+}
+
+// Sub returns v - o
+func (v Vec3) Sub(o Vec3) Vec3 {
+	return Vec3{X: v.X - o.X, Y: v.Y - o.Y, Z: v.Z - o.Z}
+}
+
+// Scale returns v multiplied by f
+func (v Vec3) Scale(f float64) Vec3 {
+	return Vec3{X: v.X * f, Y: v.Y * f, Z: v.Z * f}
+}
+
+// Dot returns the dot product of v and o
+func (v Vec3) Dot(o Vec3) float64 {
+	return v.X*o.X + v.Y*o.Y + v.Z*o.Z
+}
+
+// Len returns the length of v
+func (v Vec3) Len() float64 {
+	return math.Sqrt(v.Dot(v))
+}
+
+// Dist returns the distance between v and o
+func (v Vec3) Dist(o Vec3) float64 {
+	return v.Sub(o).Len()
+}
+
+// Normalize returns v scaled to unit length, or the zero vector if v is zero
+func (v Vec3) Normalize() Vec3 {
+	l := v.Len()
+	if l == 0 {
+		return Vec3{}
+	}
+	return v.Scale(1 / l)
+}
+
+// PlayerPosition returns the position of a player's body, falling back to
+// the head when no body data is present.
+func PlayerPosition(player *apigame.TeamMember) (Vec3, bool) {
+	if pos, ok := VecFromSlice(player.GetBody().GetPosition()); ok {
+		return pos, true
+	}
+	return VecFromSlice(player.GetHead().GetPosition())
+}
+
+// PlayerVelocity returns the velocity of a player
+func PlayerVelocity(player *apigame.TeamMember) (Vec3, bool) {
+	return VecFromSlice(player.GetVelocity())
+}
+
+// DiscPosition returns the position of the disc in a session
+func DiscPosition(session *apigame.SessionResponse) (Vec3, bool) {
+	return VecFromSlice(session.GetDisc().GetPosition())
+}
+
+// DiscVelocity returns the velocity of the disc in a session
+func DiscVelocity(session *apigame.SessionResponse) (Vec3, bool) {
+	return VecFromSlice(session.GetDisc().GetVelocity())
+}
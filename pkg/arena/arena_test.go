@@ -0,0 +1,72 @@
+package arena
+
+import (
+	"math"
+	"testing"
+
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+)
+
+func TestVecFromSlice(t *testing.T) {
+	v, ok := VecFromSlice([]float64{1, 2, 3})
+	if !ok {
+		t.Fatal("expected ok for 3 components")
+	}
+	if v != (Vec3{X: 1, Y: 2, Z: 3}) {
+		t.Errorf("unexpected vector %+v", v)
+	}
+
+	if _, ok := VecFromSlice([]float64{1, 2}); ok {
+		t.Error("expected not ok for 2 components")
+	}
+	if _, ok := VecFromSlice(nil); ok {
+		t.Error("expected not ok for nil slice")
+	}
+}
+
+func TestVec3_Math(t *testing.T) {
+	a := Vec3{X: 3, Y: 0, Z: 4}
+	b := Vec3{X: 1, Y: 1, Z: 1}
+
+	if a.Len() != 5 {
+		t.Errorf("expected length 5, got %f", a.Len())
+	}
+	if got := a.Add(b); got != (Vec3{X: 4, Y: 1, Z: 5}) {
+		t.Errorf("unexpected Add result %+v", got)
+	}
+	if got := a.Sub(b); got != (Vec3{X: 2, Y: -1, Z: 3}) {
+		t.Errorf("unexpected Sub result %+v", got)
+	}
+	if got := a.Dot(b); got != 7 {
+		t.Errorf("expected dot 7, got %f", got)
+	}
+	if got := a.Dist(Vec3{}); got != 5 {
+		t.Errorf("expected distance 5, got %f", got)
+	}
+	if got := a.Normalize().Len(); math.Abs(got-1) > 1e-9 {
+		t.Errorf("expected unit length, got %f", got)
+	}
+	if got := (Vec3{}).Normalize(); got != (Vec3{}) {
+		t.Errorf("expected zero vector, got %+v", got)
+	}
+}
+
+func TestPlayerPosition_FallsBackToHead(t *testing.T) {
+	player := &apigame.TeamMember{
+		Head: &apigame.BodyPart{Position: []float64{1, 2, 3}},
+	}
+	pos, ok := PlayerPosition(player)
+	if !ok || pos != (Vec3{X: 1, Y: 2, Z: 3}) {
+		t.Errorf("expected head position, got %+v (ok=%v)", pos, ok)
+	}
+
+	player.Body = &apigame.BodyPart{Position: []float64{4, 5, 6}}
+	pos, ok = PlayerPosition(player)
+	if !ok || pos != (Vec3{X: 4, Y: 5, Z: 6}) {
+		t.Errorf("expected body position, got %+v (ok=%v)", pos, ok)
+	}
+
+	if _, ok := PlayerPosition(nil); ok {
+		t.Error("expected not ok for nil player")
+	}
+}
@@ -0,0 +1,162 @@
+package arena
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+)
+
+// Joint identifies a bone of a player skeleton and its index in the flat
+// UserBones arrays of the game's /user_bones endpoint (see UserBones in
+// nevr-common's apigame/http_v1.proto). Neither the game nor nevr-common
+// documents the joint order: the numbering below is assumed from a
+// humanoid rig ordered root first, then spine, arms and legs, and has not
+// been checked against a recorded capture. TestJointOrderMatchesCapture
+// checks it against test_with_bones.echoreplay when the capture is present.
+type Joint int
+
+const (
+	JointPelvis Joint = iota
+	JointSpine
+	JointChest
+	JointNeck
+	JointHead
+	JointLeftShoulder
+	JointLeftUpperArm
+	JointLeftForearm
+	JointLeftHand
+	JointLeftFingers
+	JointRightShoulder
+	JointRightUpperArm
+	JointRightForearm
+	JointRightHand
+	JointRightFingers
+	JointLeftThigh
+	JointLeftShin
+	JointLeftFoot
+	JointLeftToes
+	JointRightThigh
+	JointRightShin
+	JointRightFoot
+	JointRightToes
+
+	// NumJoints is the number of joints in a skeleton
+	NumJoints = iota
+)
+
+var jointNames = [NumJoints]string{
+	"pelvis", "spine", "chest", "neck", "head",
+	"left_shoulder", "left_upper_arm", "left_forearm", "left_hand", "left_fingers",
+	"right_shoulder", "right_upper_arm", "right_forearm", "right_hand", "right_fingers",
+	"left_thigh", "left_shin", "left_foot", "left_toes",
+	"right_thigh", "right_shin", "right_foot", "right_toes",
+}
+
+// String returns the joint name
+func (j Joint) String() string {
+	if j >= 0 && j < NumJoints {
+		return jointNames[j]
+	}
+	return fmt.Sprintf("joint(%d)", int(j))
+}
+
+// Quat is a rotation quaternion
+type Quat struct {
+	X, Y, Z, W float64
+}
+
+// IdentityQuat is the rotation that leaves vectors unchanged
+var IdentityQuat = Quat{W: 1}
+
+// Rotate returns v rotated by q. q must be a unit quaternion.
+func (q Quat) Rotate(v Vec3) Vec3 {
+	// v' = v + 2w(u × v) + 2u × (u × v), with u the vector part of q
+	u := Vec3{X: q.X, Y: q.Y, Z: q.Z}
+	t := cross(u, v).Scale(2)
+	return v.Add(t.Scale(q.W)).Add(cross(u, t))
+}
+
+// Forward returns the direction q rotates the +Z axis to
+func (q Quat) Forward() Vec3 {
+	return q.Rotate(Vec3{Z: 1})
+}
+
+// JointPose is the position and orientation of a joint in arena space
+type JointPose struct {
+	Position    Vec3
+	Orientation Quat
+}
+
+// Skeleton is the decoded pose of one player
+type Skeleton struct {
+	// PlayerSlot is the slot of the player the skeleton belongs to
+	PlayerSlot int32
+	// Joints holds the pose of every joint, indexed by Joint
+	Joints [NumJoints]JointPose
+}
+
+// Joint returns the pose of a joint
+func (s *Skeleton) Joint(j Joint) JointPose {
+	return s.Joints[j]
+}
+
+// DecodeSkeleton decodes the flat bone arrays of a player. BoneT holds three
+// position components and BoneO four orientation components (x, y, z, w) per
+// joint. Returns false if either array is too short for a full skeleton.
+func DecodeSkeleton(bones *apigame.UserBones) (Skeleton, bool) {
+	t, o := bones.GetBoneT(), bones.GetBoneO()
+	if len(t) < NumJoints*3 || len(o) < NumJoints*4 {
+		return Skeleton{}, false
+	}
+
+	s := Skeleton{PlayerSlot: bones.GetPlayerIndex()}
+	for j := range s.Joints {
+		p, r := t[j*3:], o[j*4:]
+		s.Joints[j] = JointPose{
+			Position:    Vec3{X: float64(p[0]), Y: float64(p[1]), Z: float64(p[2])},
+			Orientation: Quat{X: float64(r[0]), Y: float64(r[1]), Z: float64(r[2]), W: float64(r[3])}.normalize(),
+		}
+	}
+	return s, true
+}
+
+// DecodeSkeletons decodes every complete skeleton of a bones response,
+// keyed by player slot
+func DecodeSkeletons(bones *apigame.PlayerBonesResponse) map[int32]Skeleton {
+	skeletons := make(map[int32]Skeleton, len(bones.GetUserBones()))
+	for _, ub := range bones.GetUserBones() {
+		if s, ok := DecodeSkeleton(ub); ok {
+			skeletons[s.PlayerSlot] = s
+		}
+	}
+	return skeletons
+}
+
+// AngleBetween returns the angle between two directions in degrees, or 0 if
+// either is the zero vector
+func AngleBetween(a, b Vec3) float64 {
+	la, lb := a.Len(), b.Len()
+	if la == 0 || lb == 0 {
+		return 0
+	}
+	cos := math.Max(-1, math.Min(1, a.Dot(b)/(la*lb)))
+	return math.Acos(cos) * 180 / math.Pi
+}
+
+// normalize returns q scaled to unit length, or the identity if q is zero
+func (q Quat) normalize() Quat {
+	l := math.Sqrt(q.X*q.X + q.Y*q.Y + q.Z*q.Z + q.W*q.W)
+	if l == 0 {
+		return IdentityQuat
+	}
+	return Quat{X: q.X / l, Y: q.Y / l, Z: q.Z / l, W: q.W / l}
+}
+
+func cross(a, b Vec3) Vec3 {
+	return Vec3{
+		X: a.Y*b.Z - a.Z*b.Y,
+		Y: a.Z*b.X - a.X*b.Z,
+		Z: a.X*b.Y - a.Y*b.X,
+	}
+}
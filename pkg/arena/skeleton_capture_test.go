@@ -0,0 +1,92 @@
+package arena
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	"github.com/echotools/nevr-capture/v3/pkg/codecs"
+)
+
+// skeletonBones lists the parent and child joint of every bone of the
+// assumed joint order
+var skeletonBones = [][2]Joint{
+	{JointPelvis, JointSpine}, {JointSpine, JointChest}, {JointChest, JointNeck}, {JointNeck, JointHead},
+	{JointChest, JointLeftShoulder}, {JointLeftShoulder, JointLeftUpperArm}, {JointLeftUpperArm, JointLeftForearm},
+	{JointLeftForearm, JointLeftHand}, {JointLeftHand, JointLeftFingers},
+	{JointChest, JointRightShoulder}, {JointRightShoulder, JointRightUpperArm}, {JointRightUpperArm, JointRightForearm},
+	{JointRightForearm, JointRightHand}, {JointRightHand, JointRightFingers},
+	{JointPelvis, JointLeftThigh}, {JointLeftThigh, JointLeftShin}, {JointLeftShin, JointLeftFoot}, {JointLeftFoot, JointLeftToes},
+	{JointPelvis, JointRightThigh}, {JointRightThigh, JointRightShin}, {JointRightShin, JointRightFoot}, {JointRightFoot, JointRightToes},
+}
+
+// boneLengthRange tracks the shortest and longest length of one bone
+type boneLengthRange struct {
+	min, max float64
+}
+
+func (r *boneLengthRange) add(length float64) {
+	if r.max == 0 {
+		r.min = length
+	}
+	r.min = math.Min(r.min, length)
+	r.max = math.Max(r.max, length)
+}
+
+func (r boneLengthRange) mid() float64 {
+	return (r.min + r.max) / 2
+}
+
+// TestJointOrderMatchesCapture checks the assumed joint order against a
+// recorded capture: joints the order makes neighbours must stay a constant
+// distance apart, and left and right limbs must have the same lengths.
+func TestJointOrderMatchesCapture(t *testing.T) {
+	const captureFile = "../../test_with_bones.echoreplay"
+	const tolerance = 0.05 // meters
+
+	if _, err := os.Stat(captureFile); os.IsNotExist(err) {
+		t.Skip("Test file test_with_bones.echoreplay not found, skipping test")
+	}
+	reader, err := codecs.NewEchoReplayReader(captureFile)
+	if err != nil {
+		t.Fatalf("Failed to open capture: %v", err)
+	}
+	defer reader.Close()
+	frames, err := reader.ReadFrames()
+	if err != nil {
+		t.Fatalf("Failed to read capture: %v", err)
+	}
+
+	lengths := make(map[int32][]boneLengthRange)
+	for _, frame := range frames {
+		for slot, s := range DecodeSkeletons(frame.GetPlayerBones()) {
+			if lengths[slot] == nil {
+				lengths[slot] = make([]boneLengthRange, len(skeletonBones))
+			}
+			for i, bone := range skeletonBones {
+				lengths[slot][i].add(s.Joint(bone[0]).Position.Dist(s.Joint(bone[1]).Position))
+			}
+		}
+	}
+	if len(lengths) == 0 {
+		t.Skip("Capture has no bone data, skipping test")
+	}
+
+	for slot, ranges := range lengths {
+		for i, bone := range skeletonBones {
+			if r := ranges[i]; r.max-r.min > tolerance {
+				t.Errorf("slot %d: %s-%s is not a rigid bone, length varies from %.3f to %.3f", slot, bone[0], bone[1], r.min, r.max)
+			}
+		}
+
+		// Indexes of matching left and right bones in skeletonBones
+		for _, pair := range [][2]int{{4, 9}, {5, 10}, {6, 11}, {7, 12}, {8, 13}, {14, 18}, {15, 19}, {16, 20}, {17, 21}} {
+			left, right := ranges[pair[0]], ranges[pair[1]]
+			if math.Abs(left.mid()-right.mid()) > tolerance {
+				l, r := skeletonBones[pair[0]], skeletonBones[pair[1]]
+				t.Errorf("slot %d: %s-%s (%.3f) and %s-%s (%.3f) differ in length",
+					slot, l[0], l[1], left.mid(), r[0], r[1], right.mid())
+			}
+		}
+	}
+}
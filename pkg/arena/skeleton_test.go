@@ -0,0 +1,92 @@
+package arena
+
+import (
+	"math"
+	"testing"
+
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+)
+
+func testBones(slot int32) *apigame.UserBones {
+	bones := &apigame.UserBones{
+		PlayerIndex: slot,
+		BoneT:       make([]float32, NumJoints*3),
+		BoneO:       make([]float32, NumJoints*4),
+	}
+	for j := range NumJoints {
+		bones.BoneT[j*3] = float32(j)
+		bones.BoneT[j*3+1] = 1
+		bones.BoneO[j*4+3] = 2 // unnormalized identity
+	}
+	return bones
+}
+
+func TestDecodeSkeleton(t *testing.T) {
+	s, ok := DecodeSkeleton(testBones(4))
+	if !ok {
+		t.Fatal("expected skeleton to decode")
+	}
+	if s.PlayerSlot != 4 {
+		t.Errorf("expected slot 4, got %d", s.PlayerSlot)
+	}
+	if got := s.Joint(JointRightHand).Position; got != (Vec3{X: float64(JointRightHand), Y: 1}) {
+		t.Errorf("unexpected right hand position %+v", got)
+	}
+	if got := s.Joint(JointHead).Orientation; got != IdentityQuat {
+		t.Errorf("expected normalized identity orientation, got %+v", got)
+	}
+}
+
+func TestDecodeSkeleton_ShortArrays(t *testing.T) {
+	bones := testBones(0)
+	bones.BoneO = bones.BoneO[:len(bones.BoneO)-1]
+	if _, ok := DecodeSkeleton(bones); ok {
+		t.Error("expected short orientation array to fail")
+	}
+	if _, ok := DecodeSkeleton(nil); ok {
+		t.Error("expected nil bones to fail")
+	}
+}
+
+func TestDecodeSkeletons(t *testing.T) {
+	skeletons := DecodeSkeletons(&apigame.PlayerBonesResponse{
+		UserBones: []*apigame.UserBones{testBones(1), {PlayerIndex: 2}, testBones(3)},
+	})
+	if len(skeletons) != 2 {
+		t.Fatalf("expected 2 complete skeletons, got %d", len(skeletons))
+	}
+	if _, ok := skeletons[3]; !ok {
+		t.Error("expected skeleton for slot 3")
+	}
+}
+
+func TestQuat_Rotate(t *testing.T) {
+	// 90 degrees about +Y turns +Z into +X
+	half := math.Pi / 4
+	q := Quat{Y: math.Sin(half), W: math.Cos(half)}
+	got := q.Forward()
+	if got.Dist(Vec3{X: 1}) > 1e-9 {
+		t.Errorf("expected +X, got %+v", got)
+	}
+	if got := IdentityQuat.Rotate(Vec3{X: 1, Y: 2, Z: 3}); got != (Vec3{X: 1, Y: 2, Z: 3}) {
+		t.Errorf("expected identity rotation, got %+v", got)
+	}
+}
+
+func TestAngleBetween(t *testing.T) {
+	if got := AngleBetween(Vec3{X: 1}, Vec3{Z: 2}); math.Abs(got-90) > 1e-9 {
+		t.Errorf("expected 90 degrees, got %f", got)
+	}
+	if got := AngleBetween(Vec3{X: 1}, Vec3{}); got != 0 {
+		t.Errorf("expected 0 for zero vector, got %f", got)
+	}
+}
+
+func TestJoint_String(t *testing.T) {
+	if got := JointLeftHand.String(); got != "left_hand" {
+		t.Errorf("expected left_hand, got %q", got)
+	}
+	if got := Joint(NumJoints).String(); got != "joint(23)" {
+		t.Errorf("unexpected out of range name %q", got)
+	}
+}
@@ -14,7 +14,9 @@ import (
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-// ConvertEchoReplayToNevrcap converts a .echoreplay file to a .nevrcap file
+// ConvertEchoReplayToNevrcap converts a .echoreplay file to a .nevrcap file.
+// Events are detected for frames that have none. Custom events are not
+// written, because a .nevrcap frame can only hold telemetry events.
 func ConvertEchoReplayToNevrcap(echoReplayPath, nevrcapPath string) error {
 	// Read the .echoreplay file
 	echoReader, err := codecs.NewEchoReplayReader(echoReplayPath)
@@ -51,8 +53,9 @@ func ConvertEchoReplayToNevrcap(echoReplayPath, nevrcapPath string) error {
 	}
 
 	// Process frames with event detection
-	// Use synchronous processing to ensure events are captured immediately
+	// Detect runs inline so no event is lost waiting on a channel
 	frameProcessor := processing.NewWithDetector(events.New(events.WithSynchronousProcessing()))
+	defer frameProcessor.Stop()
 	for i, frame := range frames {
 		// Re-process the frame to generate events if not already present
 		if len(frame.Events) == 0 && frame.Session != nil {
@@ -76,18 +79,14 @@ func ConvertEchoReplayToNevrcap(echoReplayPath, nevrcapPath string) error {
 				}
 			}
 
-			processedFrame, err := frameProcessor.ProcessAndDetectEvents(sessionData, userBonesData, frame.Timestamp.AsTime())
+			processedFrame, err := frameProcessor.DecodeFrame(sessionData, userBonesData, frame.Timestamp.AsTime())
 			if err != nil {
 				return fmt.Errorf("failed to process frame %d: %w", i, err)
 			}
-
-			// Check for events immediately (synchronous processing ensures they are ready)
-			select {
-			case events := <-frameProcessor.EventsChan():
-				processedFrame.Events = append(processedFrame.Events, events...)
-			default:
-				// No events
-			}
+			// Custom events are dropped, as a frame has no field to store
+			// them in
+			detected, _ := frameProcessor.Detect(processedFrame)
+			processedFrame.Events = append(processedFrame.Events, detected...)
 
 			// Use the processed frame with events
 			frame = processedFrame
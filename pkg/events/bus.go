@@ -0,0 +1,249 @@
+package events
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// DefaultSubscriptionBufferSize is the buffer size of a subscription
+// created without WithSubscriptionBuffer
+const DefaultSubscriptionBufferSize = 64
+
+// EventBus fans detected events out to independent subscribers, each with
+// its own buffer and overflow policy, so a slow subscriber does not starve
+// the others
+type EventBus struct {
+	mu     sync.RWMutex
+	subs   []*Subscription
+	closed bool
+}
+
+// NewEventBus creates an empty EventBus
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+// SubscriptionOption configures a Subscription
+type SubscriptionOption func(*Subscription)
+
+// WithEventTypes limits a subscription to events whose payload has the same
+// message type as one of the given messages, e.g. &telemetry.GoalScored{}
+func WithEventTypes(types ...proto.Message) SubscriptionOption {
+	return func(s *Subscription) {
+		if s.types == nil {
+			s.types = make(map[protoreflect.FullName]struct{}, len(types))
+		}
+		for _, t := range types {
+			s.types[t.ProtoReflect().Descriptor().FullName()] = struct{}{}
+		}
+	}
+}
+
+// WithCustomEvents limits a subscription to custom events with one of the
+// given names. Combined with WithEventTypes the subscription receives both.
+func WithCustomEvents(names ...string) SubscriptionOption {
+	return func(s *Subscription) {
+		if s.names == nil {
+			s.names = make(map[string]struct{}, len(names))
+		}
+		for _, name := range names {
+			s.names[name] = struct{}{}
+		}
+	}
+}
+
+// WithSubscriptionBuffer sets the number of envelopes buffered for the
+// subscriber
+func WithSubscriptionBuffer(size int) SubscriptionOption {
+	return func(s *Subscription) {
+		s.ch = make(chan *EventEnvelope, size)
+	}
+}
+
+// WithSubscriptionOverflow sets how the bus handles a full subscriber buffer
+func WithSubscriptionOverflow(policy OverflowPolicy) SubscriptionOption {
+	return func(s *Subscription) {
+		s.policy = policy
+	}
+}
+
+// WithSubscriptionTimeout selects OverflowBlockWithTimeout with the given
+// timeout
+func WithSubscriptionTimeout(timeout time.Duration) SubscriptionOption {
+	return func(s *Subscription) {
+		s.policy = OverflowBlockWithTimeout
+		s.timeout = timeout
+	}
+}
+
+// WithSubscriptionDropHandler sets a callback invoked with every envelope
+// the subscription discards. It runs on the publishing goroutine and must
+// not block.
+func WithSubscriptionDropHandler(fn func(*EventEnvelope)) SubscriptionOption {
+	return func(s *Subscription) {
+		s.onDrop = fn
+	}
+}
+
+// Subscription receives the events of an EventBus that match its filter
+type Subscription struct {
+	bus     *EventBus
+	ch      chan *EventEnvelope
+	types   map[protoreflect.FullName]struct{}
+	names   map[string]struct{}
+	policy  OverflowPolicy
+	timeout time.Duration
+	onDrop  func(*EventEnvelope)
+	dropped atomic.Uint64
+
+	done       chan struct{}
+	stopOnce   sync.Once
+	finishOnce sync.Once
+}
+
+// Subscribe registers a new subscriber. Without WithEventTypes or
+// WithCustomEvents it receives every event.
+func (b *EventBus) Subscribe(opts ...SubscriptionOption) *Subscription {
+	s := &Subscription{
+		bus:  b,
+		ch:   make(chan *EventEnvelope, DefaultSubscriptionBufferSize),
+		done: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		s.finish()
+		return s
+	}
+	b.subs = append(slices.Clip(b.subs), s)
+	return s
+}
+
+// Publish delivers events to every matching subscriber
+func (b *EventBus) Publish(envelopes ...*EventEnvelope) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, s := range b.subs {
+		for _, env := range envelopes {
+			if s.matches(env) {
+				enqueue(s.ch, env, s.policy, s.timeout, s.done, s.drop)
+			}
+		}
+	}
+}
+
+// Forward publishes every batch received from envelopes, e.g. a detector's
+// EnvelopesChan, and closes the bus once the channel is closed
+func (b *EventBus) Forward(envelopes <-chan []*EventEnvelope) {
+	for batch := range envelopes {
+		b.Publish(batch...)
+	}
+	b.Close()
+}
+
+// Close unsubscribes every subscriber, closing their channels
+func (b *EventBus) Close() {
+	// Unblock publishers waiting on subscribers before taking the lock
+	b.mu.RLock()
+	for _, s := range b.subs {
+		s.stop()
+	}
+	b.mu.RUnlock()
+
+	b.mu.Lock()
+	b.closed = true
+	subs := b.subs
+	b.subs = nil
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		s.finish()
+	}
+}
+
+// EventsChan returns the channel receiving the subscription's events. It is
+// closed when the subscription ends.
+func (s *Subscription) EventsChan() <-chan *EventEnvelope {
+	return s.ch
+}
+
+// Dropped returns the number of envelopes discarded by the overflow policy
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Unsubscribe removes the subscription from the bus and closes its channel
+func (s *Subscription) Unsubscribe() {
+	// Unblock a publisher waiting on this subscriber before taking the lock
+	s.stop()
+
+	b := s.bus
+	b.mu.Lock()
+	if i := slices.Index(b.subs, s); i >= 0 {
+		b.subs = slices.Delete(slices.Clone(b.subs), i, i+1)
+	}
+	b.mu.Unlock()
+
+	s.finish()
+}
+
+// stop makes pending and future deliveries to the subscription give up
+func (s *Subscription) stop() {
+	s.stopOnce.Do(func() { close(s.done) })
+}
+
+// finish closes the subscription channel. The subscription must no longer
+// be reachable by publishers.
+func (s *Subscription) finish() {
+	s.stop()
+	s.finishOnce.Do(func() { close(s.ch) })
+}
+
+func (s *Subscription) matches(env *EventEnvelope) bool {
+	if s.types == nil && s.names == nil {
+		return true
+	}
+	if env.Custom != nil {
+		_, ok := s.names[env.Custom.Name]
+		return ok
+	}
+	payload := EventPayload(env.Event)
+	if payload == nil {
+		return false
+	}
+	_, ok := s.types[payload.ProtoReflect().Descriptor().FullName()]
+	return ok
+}
+
+func (s *Subscription) drop(env *EventEnvelope) {
+	s.dropped.Add(1)
+	if s.onDrop != nil {
+		s.onDrop(env)
+	}
+}
+
+// EventPayload returns the message set in the event's oneof, e.g. the
+// *telemetry.GoalScored of a goal event, or nil if none is set
+func EventPayload(event *telemetry.LobbySessionEvent) proto.Message {
+	if event == nil {
+		return nil
+	}
+	m := event.ProtoReflect()
+	oneofs := m.Descriptor().Oneofs()
+	for i := range oneofs.Len() {
+		if fd := m.WhichOneof(oneofs.Get(i)); fd != nil && fd.Message() != nil {
+			return m.Get(fd).Message().Interface()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+func goalEnvelope() *EventEnvelope {
+	return &EventEnvelope{Event: &telemetry.LobbySessionEvent{
+		Event: &telemetry.LobbySessionEvent_GoalScored{GoalScored: &telemetry.GoalScored{}},
+	}}
+}
+
+func saveEnvelope(slot int32) *EventEnvelope {
+	return &EventEnvelope{Event: &telemetry.LobbySessionEvent{
+		Event: &telemetry.LobbySessionEvent_PlayerSave{PlayerSave: &telemetry.PlayerSave{PlayerSlot: slot}},
+	}}
+}
+
+func roundEndedEnvelope() *EventEnvelope {
+	return &EventEnvelope{Event: &telemetry.LobbySessionEvent{
+		Event: &telemetry.LobbySessionEvent_RoundEnded{RoundEnded: &telemetry.RoundEnded{}},
+	}}
+}
+
+func drainSubscription(s *Subscription) []*EventEnvelope {
+	var envelopes []*EventEnvelope
+	for {
+		select {
+		case env, ok := <-s.EventsChan():
+			if !ok {
+				return envelopes
+			}
+			envelopes = append(envelopes, env)
+		default:
+			return envelopes
+		}
+	}
+}
+
+func TestEventBus_FiltersByEventType(t *testing.T) {
+	bus := NewEventBus()
+	defer bus.Close()
+
+	filtered := bus.Subscribe(WithEventTypes(&telemetry.GoalScored{}, &telemetry.PlayerSave{}))
+	all := bus.Subscribe()
+
+	bus.Publish(goalEnvelope(), roundEndedEnvelope(), saveEnvelope(1))
+
+	got := drainSubscription(filtered)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 filtered events, got %d", len(got))
+	}
+	if got[0].Event.GetGoalScored() == nil || got[1].Event.GetPlayerSave() == nil {
+		t.Errorf("unexpected filtered events %v", got)
+	}
+	if n := len(drainSubscription(all)); n != 3 {
+		t.Errorf("expected unfiltered subscriber to receive 3 events, got %d", n)
+	}
+}
+
+func TestEventBus_SlowSubscriberDoesNotStarveOthers(t *testing.T) {
+	bus := NewEventBus()
+	defer bus.Close()
+
+	var dropped int
+	slow := bus.Subscribe(
+		WithSubscriptionBuffer(1),
+		WithSubscriptionDropHandler(func(*EventEnvelope) { dropped++ }),
+	)
+	fast := bus.Subscribe(WithSubscriptionBuffer(10))
+
+	for i := range int32(5) {
+		bus.Publish(saveEnvelope(i))
+	}
+
+	if n := len(drainSubscription(fast)); n != 5 {
+		t.Errorf("expected fast subscriber to receive 5 events, got %d", n)
+	}
+	got := drainSubscription(slow)
+	if len(got) != 1 || got[0].Event.GetPlayerSave().GetPlayerSlot() != 0 {
+		t.Errorf("expected slow subscriber to keep the first event, got %v", got)
+	}
+	if slow.Dropped() != 4 || dropped != 4 {
+		t.Errorf("expected 4 drops, got %d (handler saw %d)", slow.Dropped(), dropped)
+	}
+}
+
+func TestEventBus_DropOldestKeepsLatest(t *testing.T) {
+	bus := NewEventBus()
+	defer bus.Close()
+
+	sub := bus.Subscribe(WithSubscriptionBuffer(1), WithSubscriptionOverflow(OverflowDropOldest))
+	for i := range int32(3) {
+		bus.Publish(saveEnvelope(i))
+	}
+
+	got := drainSubscription(sub)
+	if len(got) != 1 || got[0].Event.GetPlayerSave().GetPlayerSlot() != 2 {
+		t.Errorf("expected only the latest event, got %v", got)
+	}
+}
+
+func TestEventBus_UnsubscribeReleasesBlockedPublisher(t *testing.T) {
+	bus := NewEventBus()
+	defer bus.Close()
+
+	sub := bus.Subscribe(WithSubscriptionBuffer(1), WithSubscriptionOverflow(OverflowBlock))
+	bus.Publish(saveEnvelope(0))
+
+	published := make(chan struct{})
+	go func() {
+		bus.Publish(saveEnvelope(1))
+		close(published)
+	}()
+
+	select {
+	case <-published:
+		t.Fatal("expected Publish to block on a full blocking subscriber")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	sub.Unsubscribe()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("expected Unsubscribe to release the blocked publisher")
+	}
+
+	if got := drainSubscription(sub); len(got) != 1 {
+		t.Errorf("expected the buffered event before close, got %d", len(got))
+	}
+	if _, ok := <-sub.EventsChan(); ok {
+		t.Error("expected subscription channel to be closed")
+	}
+}
+
+func TestEventBus_ForwardFromDetector(t *testing.T) {
+	detector := New(WithEnvelopes())
+	bus := NewEventBus()
+	sub := bus.Subscribe(WithEventTypes(&telemetry.MatchEnded{}))
+	go bus.Forward(detector.EnvelopesChan())
+
+	detector.ProcessFrame(newStatusOnlyFrame(GameStatusRoundOver))
+	detector.ProcessFrame(newStatusOnlyFrame(GameStatusPostMatch))
+	if err := detector.StopAndFlush(t.Context()); err != nil {
+		t.Fatalf("StopAndFlush failed: %v", err)
+	}
+
+	var received []*EventEnvelope
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case env, ok := <-sub.EventsChan():
+			if !ok {
+				if len(received) != 1 || received[0].Event.GetMatchEnded() == nil {
+					t.Fatalf("expected a single MatchEnded event, got %v", received)
+				}
+				return
+			}
+			received = append(received, env)
+		case <-timeout:
+			t.Fatal("timed out waiting for the bus to close")
+		}
+	}
+}
+
+func TestEventBus_SubscribeAfterClose(t *testing.T) {
+	bus := NewEventBus()
+	bus.Close()
+
+	sub := bus.Subscribe()
+	if _, ok := <-sub.EventsChan(); ok {
+		t.Error("expected subscription on a closed bus to be closed")
+	}
+	bus.Publish(goalEnvelope())
+	sub.Unsubscribe()
+}
+
+func TestEventPayload(t *testing.T) {
+	goal := &telemetry.GoalScored{}
+	event := &telemetry.LobbySessionEvent{
+		Event: &telemetry.LobbySessionEvent_GoalScored{GoalScored: goal},
+	}
+	if got := EventPayload(event); got != goal {
+		t.Errorf("expected GoalScored payload, got %v", got)
+	}
+	if got := EventPayload(&telemetry.LobbySessionEvent{}); got != nil {
+		t.Errorf("expected nil payload for empty event, got %v", got)
+	}
+}
@@ -0,0 +1,31 @@
+package events
+
+import "github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+
+// CustomEvent is an event without a telemetry message of its own, such as
+// the results of analytics sensors. Custom events are delivered in
+// EventEnvelopes on EnvelopesChan, which custom event sensors enable.
+type CustomEvent struct {
+	// Name identifies the kind of event, e.g. "hand_swing"
+	Name string `json:"name"`
+	// Fields holds the event payload. Values must encode as JSON; scalar
+	// values are strings, bools and numbers.
+	Fields map[string]any `json:"fields,omitempty"`
+}
+
+// CustomEventSensor is implemented by sensors that report custom events.
+// The detector calls DetectCustomEvents right after DetectEvents for every
+// frame.
+type CustomEventSensor interface {
+	EventSensor
+	DetectCustomEvents(frame *telemetry.LobbySessionStateFrame, dst []*CustomEvent) []*CustomEvent
+}
+
+// customOnly provides the DetectEvents method of sensors that only report
+// custom events
+type customOnly struct{}
+
+// DetectEvents reports no telemetry events
+func (customOnly) DetectEvents(_ *telemetry.LobbySessionStateFrame, dst []*telemetry.LobbySessionEvent) []*telemetry.LobbySessionEvent {
+	return dst
+}
@@ -0,0 +1,122 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// namedSensor reports one custom event per frame
+type namedSensor struct {
+	customOnly
+	name string
+}
+
+func (s namedSensor) DetectCustomEvents(_ *telemetry.LobbySessionStateFrame, dst []*CustomEvent) []*CustomEvent {
+	return append(dst, &CustomEvent{Name: s.name, Fields: map[string]any{"value": 1}})
+}
+
+func TestCustomEvents_DeliveredInEnvelopes(t *testing.T) {
+	for _, workers := range []int{1, 2} {
+		detector := New(
+			WithSynchronousProcessing(),
+			WithEnvelopes(),
+			WithParallelSensors(workers),
+			WithEventSensors(namedSensor{name: "first"}, namedSensor{name: "second"}),
+		)
+
+		detector.ProcessFrame(indexedFrame(7))
+		var envelopes []*EventEnvelope
+		select {
+		case envelopes = <-detector.EnvelopesChan():
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for envelopes")
+		}
+		detector.Stop()
+
+		if len(envelopes) != 2 {
+			t.Fatalf("workers=%d: expected 2 envelopes, got %d", workers, len(envelopes))
+		}
+		for i, name := range []string{"first", "second"} {
+			env := envelopes[i]
+			if env.Event != nil || env.Custom == nil || env.Custom.Name != name || env.FrameIndex != 7 {
+				t.Errorf("workers=%d: envelope %d: unexpected %+v", workers, i, env)
+			}
+		}
+	}
+}
+
+func TestCustomEvents_EnableEnvelopes(t *testing.T) {
+	engine, err := NewRuleEngine(Rule{Name: "always", When: "true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]Option{
+		"custom sensor":  WithEventSensors(namedSensor{name: "custom"}),
+		"rules":          WithRules(engine),
+		"derived sensor": WithDerivedSensors(historyCounter{}),
+	}
+	for name, opt := range tests {
+		detector := New(WithSynchronousProcessing(), opt)
+
+		detector.ProcessFrame(indexedFrame(0))
+		if n := len(detector.EnvelopesChan()); n != 1 {
+			t.Errorf("%s: expected custom events in envelopes, got %d batches", name, n)
+		}
+		detector.Stop()
+	}
+}
+
+func TestCustomEvents_EventsChanKeepsEvents(t *testing.T) {
+	detector := New(
+		WithSynchronousProcessing(),
+		WithSensors(NewPlayerJoinSensor()),
+		WithEventSensors(NewJoustSensor(DefaultJoustConfig())),
+	)
+	defer detector.Stop()
+
+	detector.ProcessFrame(createFrameWithPlayers(createPlayer(1, "Alice", 10)))
+	if n := len(detector.EventsChan()); n != 1 {
+		t.Errorf("expected the join on EventsChan, got %d batches", n)
+	}
+	if n := len(detector.EnvelopesChan()); n != 1 {
+		t.Errorf("expected the join in envelopes, got %d batches", n)
+	}
+}
+
+func TestCustomEvents_RegisterSensorsEnablesEnvelopes(t *testing.T) {
+	detector := New(WithSynchronousProcessing())
+	defer detector.Stop()
+
+	detector.RegisterSensors(namedSensor{name: "late"})
+	detector.ProcessFrame(indexedFrame(0))
+	select {
+	case envelopes := <-detector.EnvelopesChan():
+		if len(envelopes) != 1 || envelopes[0].Custom == nil || envelopes[0].Custom.Name != "late" {
+			t.Errorf("expected the late sensor's custom event, got %v", envelopes)
+		}
+	default:
+		t.Fatal("expected custom events of a late sensor in envelopes")
+	}
+}
+
+func TestSubscription_WithCustomEvents(t *testing.T) {
+	bus := NewEventBus()
+	defer bus.Close()
+	sub := bus.Subscribe(WithCustomEvents("wanted"))
+
+	bus.Publish(
+		&EventEnvelope{Custom: &CustomEvent{Name: "other"}},
+		&EventEnvelope{Event: &telemetry.LobbySessionEvent{Event: &telemetry.LobbySessionEvent_GoalScored{GoalScored: &telemetry.GoalScored{}}}},
+		&EventEnvelope{Custom: &CustomEvent{Name: "wanted"}},
+	)
+
+	env := <-sub.EventsChan()
+	if env.Custom == nil || env.Custom.Name != "wanted" {
+		t.Errorf("expected wanted custom event, got %+v", env)
+	}
+	if n := len(sub.EventsChan()); n != 0 {
+		t.Errorf("expected only one matching envelope, got %d more", n)
+	}
+}
@@ -0,0 +1,194 @@
+package events
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// DefaultEventHistoryWindow is how far back the event history given to
+// derived sensors reaches without WithEventHistory
+const DefaultEventHistoryWindow = 10 * time.Second
+
+// DetectedEvents are the events detected for one frame
+type DetectedEvents struct {
+	Frame  *telemetry.LobbySessionStateFrame
+	Events []*telemetry.LobbySessionEvent
+	Custom []*CustomEvent
+
+	at FrameTime
+}
+
+// Since returns the seconds from an earlier frame's events to d
+func (d DetectedEvents) Since(earlier DetectedEvents) float64 {
+	return d.at.Since(earlier.at)
+}
+
+// DerivedSensor is a second-stage sensor. Instead of reading frames it
+// combines the events the sensors detected for a frame with those of recent
+// frames, e.g. to credit the assist of a goal.
+type DerivedSensor interface {
+	// DetectDerivedEvents appends the custom events derived from the
+	// frame's events. history does not include the current frame.
+	DetectDerivedEvents(current DetectedEvents, history *EventHistory, dst []*CustomEvent) []*CustomEvent
+}
+
+// WithDerivedSensors runs derived sensors after the sensors for every frame.
+// Their events are delivered like other custom events, and are seen by the
+// rules of WithRules. Enables envelopes.
+func WithDerivedSensors(sensors ...DerivedSensor) Option {
+	return func(ed *AsyncDetector) {
+		ed.derived = append(ed.derived, sensors...)
+	}
+}
+
+// WithEventHistory sets how far back the event history given to derived
+// sensors reaches
+func WithEventHistory(window time.Duration) Option {
+	return func(ed *AsyncDetector) {
+		ed.history.window = window.Seconds()
+	}
+}
+
+// EventHistory holds the events detected for recent frames, oldest first.
+// Frames without events are not kept.
+type EventHistory struct {
+	window float64
+	frames []DetectedEvents
+}
+
+// NewEventHistory creates an empty history reaching back window
+func NewEventHistory(window time.Duration) *EventHistory {
+	return &EventHistory{window: window.Seconds()}
+}
+
+// Frames returns the events of the frames in the history, oldest first
+func (h *EventHistory) Frames() []DetectedEvents {
+	return h.frames
+}
+
+// Within returns the events of the frames at most seconds before current,
+// oldest first
+func (h *EventHistory) Within(current DetectedEvents, seconds float64) []DetectedEvents {
+	i, _ := slices.BinarySearchFunc(h.frames, seconds, func(d DetectedEvents, seconds float64) int {
+		if current.Since(d) > seconds {
+			return -1
+		}
+		return 1
+	})
+	return h.frames[i:]
+}
+
+// Add records the events of a frame and forgets frames older than the
+// window. The events are copied, so the slices may be reused.
+func (h *EventHistory) Add(d DetectedEvents) {
+	if n := len(h.frames); n > 0 && d.Since(h.frames[n-1]) < 0 {
+		// Time went backwards, e.g. a new capture
+		h.Reset()
+	}
+	expired := 0
+	for expired < len(h.frames) && d.Since(h.frames[expired]) > h.window {
+		expired++
+	}
+	h.frames = slices.Delete(h.frames, 0, expired)
+	if len(d.Events) == 0 && len(d.Custom) == 0 {
+		return
+	}
+	d.Events = slices.Clone(d.Events)
+	d.Custom = slices.Clone(d.Custom)
+	h.frames = append(h.frames, d)
+}
+
+// Reset forgets every frame
+func (h *EventHistory) Reset() {
+	clear(h.frames)
+	h.frames = h.frames[:0]
+}
+
+// eventHistoryState is the snapshot of an EventHistory
+type eventHistoryState struct {
+	Frames []detectedEventsState `json:"frames"`
+}
+
+type detectedEventsState struct {
+	Frame  json.RawMessage   `json:"frame,omitempty"`
+	Events []json.RawMessage `json:"events,omitempty"`
+	Custom []*CustomEvent    `json:"custom,omitempty"`
+	At     FrameTime         `json:"at"`
+}
+
+// Snapshot serializes the frames in the history. Restored custom events
+// hold their fields as decoded from JSON.
+func (h *EventHistory) Snapshot() ([]byte, error) {
+	state := eventHistoryState{Frames: make([]detectedEventsState, len(h.frames))}
+	for i, d := range h.frames {
+		frame, err := marshalProto(d.Frame)
+		if err != nil {
+			return nil, err
+		}
+		fs := detectedEventsState{Frame: frame, Custom: d.Custom, At: d.at}
+		for _, event := range d.Events {
+			raw, err := marshalProto(event)
+			if err != nil {
+				return nil, err
+			}
+			fs.Events = append(fs.Events, raw)
+		}
+		state.Frames[i] = fs
+	}
+	return json.Marshal(state)
+}
+
+// Restore replaces the frames in the history with a previous snapshot
+func (h *EventHistory) Restore(data []byte) error {
+	var state eventHistoryState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	frames := make([]DetectedEvents, len(state.Frames))
+	for i, fs := range state.Frames {
+		d := DetectedEvents{Custom: fs.Custom, at: fs.At}
+		frame := &telemetry.LobbySessionStateFrame{}
+		ok, err := unmarshalProto(fs.Frame, frame)
+		if err != nil {
+			return err
+		}
+		if ok {
+			d.Frame = frame
+		}
+		for _, raw := range fs.Events {
+			event := &telemetry.LobbySessionEvent{}
+			if _, err := unmarshalProto(raw, event); err != nil {
+				return err
+			}
+			d.Events = append(d.Events, event)
+		}
+		frames[i] = d
+	}
+	h.Reset()
+	h.frames = append(h.frames, frames...)
+	return nil
+}
+
+// newDetectedEvents captures the events detected for a frame
+func newDetectedEvents(frame *telemetry.LobbySessionStateFrame, events []*telemetry.LobbySessionEvent, custom []*CustomEvent) DetectedEvents {
+	return DetectedEvents{
+		Frame:  frame,
+		Events: slices.Clip(events),
+		Custom: slices.Clip(custom),
+		at:     FrameTimeOf(frame),
+	}
+}
+
+// detectDerived runs the derived sensors on the events detected for frame
+// and records them in the history
+func (ed *AsyncDetector) detectDerived(frame *telemetry.LobbySessionStateFrame, events []*telemetry.LobbySessionEvent) {
+	current := newDetectedEvents(frame, events, ed.customBuffer)
+	for _, d := range ed.derived {
+		ed.customBuffer = d.DetectDerivedEvents(current, ed.history, ed.customBuffer)
+	}
+	current.Custom = ed.customBuffer
+	ed.history.Add(current)
+}
@@ -0,0 +1,117 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// historyCounter reports how many custom events it saw for the frame and
+// how many frames the history holds
+type historyCounter struct{}
+
+func (historyCounter) DetectDerivedEvents(current DetectedEvents, history *EventHistory, dst []*CustomEvent) []*CustomEvent {
+	return append(dst, &CustomEvent{Name: "derived", Fields: map[string]any{
+		"seen":    len(current.Custom),
+		"history": len(history.Frames()),
+	}})
+}
+
+func timedFrame(at time.Duration) *telemetry.LobbySessionStateFrame {
+	frame := newStatusOnlyFrame(GameStatusPlaying)
+	frame.Timestamp = timestamppb.New(bonesEpoch.Add(at))
+	return frame
+}
+
+func detectedAt(at time.Duration, names ...string) DetectedEvents {
+	var custom []*CustomEvent
+	for _, name := range names {
+		custom = append(custom, &CustomEvent{Name: name})
+	}
+	return newDetectedEvents(timedFrame(at), nil, custom)
+}
+
+func TestEventHistory_Window(t *testing.T) {
+	history := NewEventHistory(2 * time.Second)
+
+	history.Add(detectedAt(0, "a"))
+	history.Add(detectedAt(500 * time.Millisecond))
+	history.Add(detectedAt(time.Second, "b"))
+	history.Add(detectedAt(2*time.Second, "c"))
+	if n := len(history.Frames()); n != 3 {
+		t.Fatalf("expected the frames with events kept, got %d", n)
+	}
+
+	current := detectedAt(2500 * time.Millisecond)
+	if within := history.Within(current, 1.5); len(within) != 2 || within[0].Custom[0].Name != "b" {
+		t.Errorf("expected frames b and c within 1.5s, got %d", len(within))
+	}
+	if within := history.Within(current, 0.1); len(within) != 0 {
+		t.Errorf("expected no frames within 0.1s, got %d", len(within))
+	}
+
+	history.Add(current)
+	if frames := history.Frames(); len(frames) != 2 || frames[0].Custom[0].Name != "b" {
+		t.Errorf("expected frame a to expire, got %d frames", len(frames))
+	}
+
+	// A new capture starts over
+	history.Add(detectedAt(0, "d"))
+	if frames := history.Frames(); len(frames) != 1 || frames[0].Custom[0].Name != "d" {
+		t.Errorf("expected the history to restart, got %d frames", len(frames))
+	}
+}
+
+func TestEventHistory_CopiesEvents(t *testing.T) {
+	history := NewEventHistory(DefaultEventHistoryWindow)
+	custom := []*CustomEvent{{Name: "a"}}
+
+	history.Add(newDetectedEvents(timedFrame(0), nil, custom))
+	custom[0] = &CustomEvent{Name: "reused"}
+
+	if name := history.Frames()[0].Custom[0].Name; name != "a" {
+		t.Errorf("expected the history unaffected by buffer reuse, got %q", name)
+	}
+}
+
+func TestWithDerivedSensors_SecondStage(t *testing.T) {
+	engine, err := NewRuleEngine(Rule{Name: "ruled", On: "derived", When: "event.history >= 1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	detector := New(
+		WithSynchronousProcessing(),
+		WithEnvelopes(),
+		WithEventSensors(namedSensor{name: "first"}),
+		WithDerivedSensors(historyCounter{}),
+		WithRules(engine),
+	)
+	defer detector.Stop()
+
+	var names [][]string
+	for i := range 2 {
+		detector.ProcessFrame(timedFrame(time.Duration(i) * 100 * time.Millisecond))
+		select {
+		case envelopes := <-detector.EnvelopesChan():
+			var frame []string
+			for _, env := range envelopes {
+				frame = append(frame, env.Custom.Name)
+				if env.Custom.Name == "derived" && env.Custom.Fields["seen"] != 1 {
+					t.Errorf("expected the derived sensor to see the first stage, got %v", env.Custom.Fields)
+				}
+			}
+			names = append(names, frame)
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for envelopes")
+		}
+	}
+
+	if len(names[0]) != 2 || names[0][1] != "derived" {
+		t.Errorf("expected first and derived on the first frame, got %v", names[0])
+	}
+	if len(names[1]) != 3 || names[1][2] != "ruled" {
+		t.Errorf("expected rules to see derived events with history, got %v", names[1])
+	}
+}
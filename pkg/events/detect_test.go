@@ -0,0 +1,75 @@
+package events
+
+import "testing"
+
+func TestAsyncDetector_DetectReturnsEvents(t *testing.T) {
+	detector := New(WithSynchronousProcessing(), WithEventsChannelSize(1))
+	defer detector.Stop()
+
+	detector.Detect(newStatusOnlyFrame(GameStatusRoundOver))
+	events, _ := detector.Detect(newStatusOnlyFrame(GameStatusPostMatch))
+	if len(events) != 1 || events[0].GetMatchEnded() == nil {
+		t.Fatalf("expected MatchEnded event, got %v", events)
+	}
+
+	select {
+	case batch := <-detector.EventsChan():
+		t.Fatalf("expected Detect not to use EventsChan, got %v", batch)
+	default:
+	}
+}
+
+func TestAsyncDetector_DetectLosesNoEvents(t *testing.T) {
+	// The events channel would overflow after the first batch
+	detector := New(WithEventsChannelSize(1), WithSensors(&mockSensor{id: "s1"}, &mockSensor{id: "s2"}))
+	defer detector.Stop()
+
+	frame := newStatusOnlyFrame(GameStatusPlaying)
+	total := 0
+	for range 10 {
+		events, _ := detector.Detect(frame)
+		total += len(events)
+	}
+
+	if total != 20 {
+		t.Errorf("expected 20 events, got %d", total)
+	}
+	stats := detector.Stats()
+	if stats.FramesReceived != 10 || stats.EventBatchesDropped != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestAsyncDetector_DetectSharesFrameHistory(t *testing.T) {
+	detector := New(WithSynchronousProcessing())
+	defer detector.Stop()
+
+	// ProcessFrame and Detect feed the same frame buffer in synchronous mode
+	detector.ProcessFrame(newStatusOnlyFrame(GameStatusRoundOver))
+	events, _ := detector.Detect(newStatusOnlyFrame(GameStatusPostMatch))
+	if len(events) != 1 {
+		t.Fatalf("expected transition to be detected across ProcessFrame and Detect, got %v", events)
+	}
+	if events[0].GetMatchEnded() == nil {
+		t.Errorf("expected MatchEnded event, got %T", events[0].Event)
+	}
+}
+
+func TestAsyncDetector_DetectReturnsCustomEvents(t *testing.T) {
+	detector := New(WithSynchronousProcessing(), WithEventSensors(namedSensor{name: "first"}, namedSensor{name: "second"}))
+	defer detector.Stop()
+
+	events, custom := detector.Detect(newStatusOnlyFrame(GameStatusPlaying))
+	if len(events) != 0 {
+		t.Errorf("expected no telemetry events, got %v", events)
+	}
+	if len(custom) != 2 || custom[0].Name != "first" || custom[1].Name != "second" {
+		t.Fatalf("expected both custom events, got %v", custom)
+	}
+
+	// The returned slice must not be reused by the next frame
+	detector.Detect(newStatusOnlyFrame(GameStatusPlaying))
+	if custom[0].Name != "first" {
+		t.Errorf("expected returned custom events to be kept, got %v", custom)
+	}
+}
@@ -0,0 +1,408 @@
+package events
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+
+	"github.com/echotools/nevr-capture/v3/pkg/arena"
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// How a disc flight ended
+const (
+	FlightEndCaught      = "caught"
+	FlightEndRest        = "rest"
+	FlightEndGoal        = "goal"
+	FlightEndInterrupted = "interrupted"
+)
+
+// How a flight segment ended
+const (
+	SegmentEndBounce     = "bounce"
+	SegmentEndDeflection = "deflection"
+)
+
+// DiscTrackerConfig configures a DiscTracker
+type DiscTrackerConfig struct {
+	// DeflectionAngle is the smallest change of direction, in degrees,
+	// between two frames that counts as a deflection
+	DeflectionAngle float64 `json:"deflection_angle"`
+	// MinSpeed is the disc speed, in m/s, below which direction changes are
+	// ignored
+	MinSpeed float64 `json:"min_speed"`
+	// WallMargin is how close, in meters, the disc must be to the arena
+	// bounds for a reversal to count as a wall bounce
+	WallMargin float64 `json:"wall_margin"`
+	// RestSpeed is the disc speed, in m/s, below which the disc is
+	// considered still
+	RestSpeed float64 `json:"rest_speed"`
+	// RestDuration is how many seconds the disc must stay still to come to
+	// rest
+	RestDuration float64 `json:"rest_duration"`
+}
+
+// DefaultDiscTrackerConfig returns the default tracking thresholds
+func DefaultDiscTrackerConfig() DiscTrackerConfig {
+	return DiscTrackerConfig{
+		DeflectionAngle: 45,
+		MinSpeed:        1,
+		WallMargin:      1.5,
+		RestSpeed:       0.5,
+		RestDuration:    1,
+	}
+}
+
+// FlightSegment is a stretch of a disc flight between bounces and
+// deflections
+type FlightSegment struct {
+	StartFrame uint32     `json:"start_frame"`
+	EndFrame   uint32     `json:"end_frame"`
+	Start      arena.Vec3 `json:"start"`
+	End        arena.Vec3 `json:"end"`
+	Duration   float64    `json:"duration"`
+	// Speed is the disc speed at the start of the segment
+	Speed float64 `json:"speed"`
+	// EndedBy is SegmentEndBounce, SegmentEndDeflection, or empty for the
+	// last segment of a flight
+	EndedBy string `json:"ended_by,omitempty"`
+}
+
+// DiscFlight is the path of the disc while nobody holds it
+type DiscFlight struct {
+	// ThrowerSlot is the last player to hold the disc before the flight, or
+	// -1 if the disc started moving on its own
+	ThrowerSlot int32 `json:"thrower_slot"`
+	// CatcherSlot is the player who ended the flight by taking the disc, or
+	// -1
+	CatcherSlot int32   `json:"catcher_slot"`
+	StartFrame  uint32  `json:"start_frame"`
+	EndFrame    uint32  `json:"end_frame"`
+	Duration    float64 `json:"duration"`
+	// Distance is the length of the path travelled
+	Distance float64 `json:"distance"`
+	MaxSpeed float64 `json:"max_speed"`
+	// Thrown is set when the game registered a throw for the flight, in
+	// which case ThrowFrame is the index of the frame whose DiscThrown event
+	// reported it and ThrowSpeed is its speed
+	Thrown      bool            `json:"thrown"`
+	ThrowFrame  uint32          `json:"throw_frame,omitempty"`
+	ThrowSpeed  float64         `json:"throw_speed,omitempty"`
+	Bounces     int             `json:"bounces"`
+	Deflections int             `json:"deflections"`
+	Segments    []FlightSegment `json:"segments"`
+	// EndedBy is one of the FlightEnd constants
+	EndedBy string `json:"ended_by"`
+}
+
+// DiscTrackResult describes what happened to the disc in one frame
+type DiscTrackResult struct {
+	// Started is set when a flight started in the frame
+	Started bool
+	// ThrowerSlot is the thrower of the flight that started, or -1 if the
+	// disc started moving on its own
+	ThrowerSlot int32
+	// Bounce is set when the disc bounced off a wall
+	Bounce bool
+	// Deflection is set when the disc changed direction away from a wall,
+	// such as when it is blocked or tapped
+	Deflection bool
+	// Angle is the change of direction of a bounce or deflection in degrees
+	Angle float64
+	// Position and Speed describe the disc in the frame
+	Position arena.Vec3
+	Speed    float64
+	// Completed is the flight that ended in the frame, or nil
+	Completed *DiscFlight
+}
+
+// DiscTracker follows the disc across frames while nobody holds it and
+// splits its flights into segments at bounces and deflections. A tracker can
+// be shared by several sensors: it advances once per frame and returns the
+// same result to every caller updating it with that frame.
+type DiscTracker struct {
+	mu     sync.Mutex
+	config DiscTrackerConfig
+
+	// The last frame and its result
+	lastFrame  *telemetry.LobbySessionStateFrame
+	lastIndex  uint32
+	lastTime   FrameTime
+	lastResult DiscTrackResult
+
+	flight        *DiscFlight
+	segment       FlightSegment
+	segmentStart  FrameTime
+	flightStart   FrameTime
+	lastPossessor int32
+	prevPos       arena.Vec3
+	prevVel       arena.Vec3
+	prevBounces   int32
+	prevLastThrow *apigame.LastThrowInfo
+	stillSince    FrameTime
+	still         bool
+	resting       bool
+}
+
+// NewDiscTracker creates a new DiscTracker
+func NewDiscTracker(cfg DiscTrackerConfig) *DiscTracker {
+	return &DiscTracker{config: cfg, lastPossessor: -1}
+}
+
+// Reset forgets the current flight
+func (t *DiscTracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reset()
+}
+
+func (t *DiscTracker) reset() {
+	t.lastFrame, t.lastIndex, t.lastTime, t.lastResult = nil, 0, FrameTime{}, DiscTrackResult{}
+	t.flight = nil
+	t.segment = FlightSegment{}
+	t.segmentStart, t.flightStart = FrameTime{}, FrameTime{}
+	t.lastPossessor = -1
+	t.prevPos, t.prevVel, t.prevBounces = arena.Vec3{}, arena.Vec3{}, 0
+	t.prevLastThrow = nil
+	t.stillSince, t.still, t.resting = FrameTime{}, false, false
+}
+
+// discTrackerState is the snapshot of a DiscTracker
+type discTrackerState struct {
+	Flight        *DiscFlight     `json:"flight,omitempty"`
+	Segment       FlightSegment   `json:"segment"`
+	SegmentStart  FrameTime       `json:"segment_start"`
+	FlightStart   FrameTime       `json:"flight_start"`
+	LastPossessor int32           `json:"last_possessor"`
+	PrevPos       arena.Vec3      `json:"prev_pos"`
+	PrevVel       arena.Vec3      `json:"prev_vel"`
+	PrevBounces   int32           `json:"prev_bounces"`
+	PrevLastThrow json.RawMessage `json:"prev_last_throw,omitempty"`
+	StillSince    FrameTime       `json:"still_since"`
+	Still         bool            `json:"still"`
+	Resting       bool            `json:"resting"`
+}
+
+// Snapshot serializes the tracker state
+func (t *DiscTracker) Snapshot() ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lastThrow, err := marshalProto(t.prevLastThrow)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(discTrackerState{
+		Flight:        t.flight,
+		Segment:       t.segment,
+		SegmentStart:  t.segmentStart,
+		FlightStart:   t.flightStart,
+		LastPossessor: t.lastPossessor,
+		PrevPos:       t.prevPos,
+		PrevVel:       t.prevVel,
+		PrevBounces:   t.prevBounces,
+		PrevLastThrow: lastThrow,
+		StillSince:    t.stillSince,
+		Still:         t.still,
+		Resting:       t.resting,
+	})
+}
+
+// Restore replaces the tracker state with a previous snapshot
+func (t *DiscTracker) Restore(data []byte) error {
+	var state discTrackerState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	lastThrow := &apigame.LastThrowInfo{}
+	hasThrow, err := unmarshalProto(state.PrevLastThrow, lastThrow)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reset()
+	t.flight = state.Flight
+	t.segment = state.Segment
+	t.segmentStart, t.flightStart = state.SegmentStart, state.FlightStart
+	t.lastPossessor = state.LastPossessor
+	t.prevPos, t.prevVel, t.prevBounces = state.PrevPos, state.PrevVel, state.PrevBounces
+	if hasThrow {
+		t.prevLastThrow = lastThrow
+	}
+	t.stillSince, t.still, t.resting = state.StillSince, state.Still, state.Resting
+	return nil
+}
+
+// Flight returns a copy of the flight in progress, or nil if the disc is
+// held or still
+func (t *DiscTracker) Flight() *DiscFlight {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.flight == nil {
+		return nil
+	}
+	flight := *t.flight
+	flight.Segments = slices.Clone(flight.Segments)
+	return &flight
+}
+
+// Update advances the tracker by one frame. Updating it again with the same
+// frame returns the same result without advancing it.
+func (t *DiscTracker) Update(frame *telemetry.LobbySessionStateFrame) DiscTrackResult {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := FrameTimeOf(frame)
+	if t.lastFrame != nil && frame == t.lastFrame && frame.GetFrameIndex() == t.lastIndex && now.Equal(t.lastTime) {
+		return t.lastResult
+	}
+	t.lastFrame, t.lastIndex, t.lastTime = frame, frame.GetFrameIndex(), now
+	t.lastResult = t.update(frame, now)
+	return t.lastResult
+}
+
+func (t *DiscTracker) update(frame *telemetry.LobbySessionStateFrame, now FrameTime) DiscTrackResult {
+	var result DiscTrackResult
+	session := frame.GetSession()
+	if session == nil {
+		return result
+	}
+	pos, okPos := arena.DiscPosition(session)
+	if !okPos {
+		pos = t.prevPos
+	}
+	vel, _ := arena.DiscVelocity(session)
+	result.Position, result.Speed = pos, vel.Len()
+	index := frame.GetFrameIndex()
+
+	// Throws are registered the way DiscThrownSensor reports them, on the
+	// release or shortly after it
+	lastThrow := session.GetLastThrow()
+	newThrow := lastThrow != nil && !lastThrowEqual(t.prevLastThrow, lastThrow)
+	t.prevLastThrow = lastThrow
+	if newThrow && t.flight != nil && !t.flight.Thrown {
+		t.flight.Thrown, t.flight.ThrowFrame, t.flight.ThrowSpeed = true, index, lastThrow.GetTotalSpeed()
+	}
+
+	switch status := session.GetGameStatus(); {
+	case status == GameStatusScore:
+		result.Completed = t.finish(index, now, pos, FlightEndGoal, -1)
+		return result
+	case status != GameStatusPlaying:
+		result.Completed = t.finish(index, now, pos, FlightEndInterrupted, -1)
+		return result
+	}
+
+	if holder := findPossessorSlot(session); holder != -1 {
+		result.Completed = t.finish(index, now, pos, FlightEndCaught, holder)
+		t.lastPossessor = holder
+		t.resting = false
+		return result
+	}
+	if !okPos {
+		return result
+	}
+
+	if t.flight == nil {
+		if result.Speed <= t.config.RestSpeed {
+			return result
+		}
+		thrower := t.lastPossessor
+		if t.resting {
+			thrower = -1
+		}
+		t.start(index, now, pos, vel, session.GetDisc().GetBounceCount(), thrower)
+		if newThrow {
+			t.flight.Thrown, t.flight.ThrowFrame, t.flight.ThrowSpeed = true, index, lastThrow.GetTotalSpeed()
+		}
+		result.Started, result.ThrowerSlot = true, thrower
+		return result
+	}
+
+	t.flight.Distance += pos.Dist(t.prevPos)
+	t.flight.MaxSpeed = max(t.flight.MaxSpeed, result.Speed)
+
+	bounces := session.GetDisc().GetBounceCount()
+	angle := arena.AngleBetween(t.prevVel, vel)
+	turning := t.prevVel.Len() >= t.config.MinSpeed && result.Speed >= t.config.MinSpeed && angle >= t.config.DeflectionAngle
+	switch {
+	case bounces > t.prevBounces || turning && t.nearWall(pos):
+		result.Bounce, result.Angle = true, angle
+		t.flight.Bounces++
+		t.split(index, now, pos, result.Speed, SegmentEndBounce)
+	case turning:
+		result.Deflection, result.Angle = true, angle
+		t.flight.Deflections++
+		t.split(index, now, pos, result.Speed, SegmentEndDeflection)
+	}
+	t.prevBounces = bounces
+	t.prevPos, t.prevVel = pos, vel
+
+	if result.Speed > t.config.RestSpeed {
+		t.still = false
+		return result
+	}
+	if !t.still {
+		t.still, t.stillSince = true, now
+	}
+	if now.Since(t.stillSince) >= t.config.RestDuration {
+		result.Completed = t.finish(index, now, pos, FlightEndRest, -1)
+		t.resting = true
+	}
+	return result
+}
+
+func (t *DiscTracker) start(index uint32, now FrameTime, pos, vel arena.Vec3, bounces int32, thrower int32) {
+	t.flight = &DiscFlight{
+		ThrowerSlot: thrower,
+		CatcherSlot: -1,
+		StartFrame:  index,
+		MaxSpeed:    vel.Len(),
+	}
+	t.flightStart = now
+	t.segment = FlightSegment{StartFrame: index, Start: pos, Speed: vel.Len()}
+	t.segmentStart = now
+	t.prevPos, t.prevVel, t.prevBounces = pos, vel, bounces
+	t.still = false
+}
+
+// split ends the current segment and starts the next one
+func (t *DiscTracker) split(index uint32, now FrameTime, pos arena.Vec3, speed float64, endedBy string) {
+	t.closeSegment(index, now, pos, endedBy)
+	t.segment = FlightSegment{StartFrame: index, Start: pos, Speed: speed}
+	t.segmentStart = now
+}
+
+func (t *DiscTracker) closeSegment(index uint32, now FrameTime, pos arena.Vec3, endedBy string) {
+	t.segment.EndFrame = index
+	t.segment.End = pos
+	t.segment.Duration = now.Since(t.segmentStart)
+	t.segment.EndedBy = endedBy
+	t.flight.Segments = append(t.flight.Segments, t.segment)
+}
+
+// finish ends the flight in progress, if any, and returns it
+func (t *DiscTracker) finish(index uint32, now FrameTime, pos arena.Vec3, endedBy string, catcher int32) *DiscFlight {
+	flight := t.flight
+	if flight == nil {
+		return nil
+	}
+	flight.Distance += pos.Dist(t.prevPos)
+	t.closeSegment(index, now, pos, "")
+	flight.EndFrame = index
+	flight.Duration = now.Since(t.flightStart)
+	flight.EndedBy = endedBy
+	flight.CatcherSlot = catcher
+	t.flight = nil
+	return flight
+}
+
+// nearWall reports whether pos is within the wall margin of the arena
+// bounds
+func (t *DiscTracker) nearWall(pos arena.Vec3) bool {
+	m := t.config.WallMargin
+	return pos.X <= arena.MinX+m || pos.X >= arena.MaxX-m ||
+		pos.Y <= arena.MinY+m || pos.Y >= arena.MaxY-m ||
+		pos.Z <= arena.MinZ+m || pos.Z >= arena.MaxZ-m
+}
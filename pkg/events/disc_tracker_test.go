@@ -0,0 +1,209 @@
+package events
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-capture/v3/pkg/arena"
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// discState describes the disc and its holder in a test frame
+type discState struct {
+	pos, vel arena.Vec3
+	bounces  int32
+	holder   int32 // -1 for nobody
+	status   string
+}
+
+// discFrame builds frame index i, captured i*100ms after the epoch, with a
+// blue player in slot 0 and an orange player in slot 1 standing at the disc
+// when holding it and far away otherwise
+func discFrame(i uint32, d discState) *telemetry.LobbySessionStateFrame {
+	if d.status == "" {
+		d.status = GameStatusPlaying
+	}
+	player := func(slot int32) *apigame.TeamMember {
+		pos := arena.Vec3{X: float64(slot)*4 - 2, Z: -30}
+		if slot == d.holder {
+			pos = d.pos
+		}
+		return &apigame.TeamMember{
+			SlotNumber:    slot,
+			DisplayName:   "P" + string(rune('0'+slot)),
+			Body:          &apigame.BodyPart{Position: []float64{pos.X, pos.Y, pos.Z}},
+			HasPossession: slot == d.holder,
+		}
+	}
+	frame := createFrameWithTeams([]*apigame.TeamMember{player(0)}, []*apigame.TeamMember{player(1)}, nil)
+	frame.FrameIndex = i
+	frame.Timestamp = timestamppb.New(bonesEpoch.Add(time.Duration(i) * 100 * time.Millisecond))
+	frame.Session.GameStatus = d.status
+	frame.Session.Disc = &apigame.Disc{
+		Position:    []float64{d.pos.X, d.pos.Y, d.pos.Z},
+		Velocity:    []float64{d.vel.X, d.vel.Y, d.vel.Z},
+		BounceCount: d.bounces,
+	}
+	return frame
+}
+
+func TestDiscTracker_ThrowAndCatch(t *testing.T) {
+	tracker := NewDiscTracker(DefaultDiscTrackerConfig())
+	v := arena.Vec3{Z: 10}
+
+	tracker.Update(discFrame(0, discState{pos: arena.Vec3{Z: -10}, holder: 0}))
+	if r := tracker.Update(discFrame(1, discState{pos: arena.Vec3{Z: -9}, vel: v, holder: -1})); !r.Started {
+		t.Fatal("expected flight to start on release")
+	}
+	tracker.Update(discFrame(2, discState{pos: arena.Vec3{Z: -8}, vel: v, holder: -1}))
+	r := tracker.Update(discFrame(3, discState{pos: arena.Vec3{Z: -7}, holder: 1}))
+
+	f := r.Completed
+	if f == nil {
+		t.Fatal("expected flight to complete on catch")
+	}
+	if f.ThrowerSlot != 0 || f.CatcherSlot != 1 || f.EndedBy != FlightEndCaught {
+		t.Errorf("unexpected flight %+v", f)
+	}
+	if f.StartFrame != 1 || f.EndFrame != 3 || math.Abs(f.Duration-0.2) > 1e-9 || math.Abs(f.Distance-2) > 1e-9 {
+		t.Errorf("unexpected flight extent %+v", f)
+	}
+	if len(f.Segments) != 1 || f.Segments[0].EndedBy != "" {
+		t.Errorf("expected a single segment, got %+v", f.Segments)
+	}
+	if tracker.Flight() != nil {
+		t.Error("expected no flight in progress after the catch")
+	}
+}
+
+func TestDiscTracker_BounceAndDeflection(t *testing.T) {
+	tracker := NewDiscTracker(DefaultDiscTrackerConfig())
+
+	tracker.Update(discFrame(0, discState{pos: arena.Vec3{X: 10}, holder: 0}))
+	tracker.Update(discFrame(1, discState{pos: arena.Vec3{X: 12}, vel: arena.Vec3{X: 10}, holder: -1}))
+	// Reverses off the side wall
+	r := tracker.Update(discFrame(2, discState{pos: arena.Vec3{X: 15}, vel: arena.Vec3{X: -10}, holder: -1}))
+	if !r.Bounce || r.Deflection {
+		t.Fatalf("expected a bounce, got %+v", r)
+	}
+	// Turns sharply in the middle of the arena
+	tracker.Update(discFrame(3, discState{pos: arena.Vec3{X: 5}, vel: arena.Vec3{X: -10}, holder: -1}))
+	r = tracker.Update(discFrame(4, discState{pos: arena.Vec3{X: 4}, vel: arena.Vec3{Z: 10}, holder: -1}))
+	if !r.Deflection || math.Abs(r.Angle-90) > 1e-9 {
+		t.Fatalf("expected a 90 degree deflection, got %+v", r)
+	}
+
+	f := tracker.Update(discFrame(5, discState{pos: arena.Vec3{X: 4, Z: 1}, holder: 1})).Completed
+	if f == nil || f.Bounces != 1 || f.Deflections != 1 {
+		t.Fatalf("expected 1 bounce and 1 deflection, got %+v", f)
+	}
+	var ends []string
+	for _, seg := range f.Segments {
+		ends = append(ends, seg.EndedBy)
+	}
+	if len(ends) != 3 || ends[0] != SegmentEndBounce || ends[1] != SegmentEndDeflection || ends[2] != "" {
+		t.Errorf("unexpected segments %v", ends)
+	}
+}
+
+func TestDiscTracker_BounceCount(t *testing.T) {
+	tracker := NewDiscTracker(DefaultDiscTrackerConfig())
+
+	tracker.Update(discFrame(0, discState{vel: arena.Vec3{Z: 5}, holder: -1}))
+	if r := tracker.Update(discFrame(1, discState{vel: arena.Vec3{Z: 5}, bounces: 1, holder: -1})); !r.Bounce {
+		t.Errorf("expected the game's bounce counter to report a bounce, got %+v", r)
+	}
+}
+
+func TestDiscTracker_ComesToRest(t *testing.T) {
+	tracker := NewDiscTracker(DefaultDiscTrackerConfig())
+
+	tracker.Update(discFrame(0, discState{holder: 0}))
+	tracker.Update(discFrame(1, discState{vel: arena.Vec3{Y: 3}, holder: -1}))
+	var completed *DiscFlight
+	for i := uint32(2); i < 20 && completed == nil; i++ {
+		completed = tracker.Update(discFrame(i, discState{vel: arena.Vec3{Y: 0.1}, holder: -1})).Completed
+	}
+	if completed == nil || completed.EndedBy != FlightEndRest {
+		t.Fatalf("expected the disc to come to rest, got %+v", completed)
+	}
+	if completed.EndFrame != 12 {
+		t.Errorf("expected rest one second after slowing down, got frame %d", completed.EndFrame)
+	}
+
+	// Knocked loose again: nobody threw it
+	r := tracker.Update(discFrame(20, discState{vel: arena.Vec3{X: 4}, holder: -1}))
+	if !r.Started || tracker.Flight().ThrowerSlot != -1 {
+		t.Errorf("expected a flight without thrower, got %+v", tracker.Flight())
+	}
+}
+
+func TestDiscTracker_GoalEndsFlight(t *testing.T) {
+	tracker := NewDiscTracker(DefaultDiscTrackerConfig())
+
+	tracker.Update(discFrame(0, discState{holder: 0}))
+	tracker.Update(discFrame(1, discState{vel: arena.Vec3{Z: 20}, holder: -1}))
+	f := tracker.Update(discFrame(2, discState{status: GameStatusScore, holder: -1})).Completed
+	if f == nil || f.EndedBy != FlightEndGoal || f.CatcherSlot != -1 {
+		t.Errorf("expected the flight to end in a goal, got %+v", f)
+	}
+}
+
+func TestDiscTracker_SameFrameDoesNotAdvance(t *testing.T) {
+	tracker := NewDiscTracker(DefaultDiscTrackerConfig())
+
+	tracker.Update(discFrame(0, discState{holder: 0}))
+	release := discFrame(1, discState{vel: arena.Vec3{Z: 10}, holder: -1})
+	first := tracker.Update(release)
+	second := tracker.Update(release)
+	if !first.Started || !second.Started {
+		t.Errorf("expected every caller to see the flight start, got %+v and %+v", first, second)
+	}
+	catch := discFrame(2, discState{holder: 1})
+	if f := tracker.Update(catch).Completed; f == nil || f.Duration != 0.1 {
+		t.Fatalf("expected a 0.1s flight, got %+v", f)
+	}
+	if f := tracker.Update(catch).Completed; f == nil || f.CatcherSlot != 1 {
+		t.Errorf("expected the second caller to see the completed flight, got %+v", f)
+	}
+}
+
+func TestDiscTracker_LinksThrow(t *testing.T) {
+	tracker := NewDiscTracker(DefaultDiscTrackerConfig())
+	thrownSensor := NewDiscThrownSensor()
+	throw := &apigame.LastThrowInfo{TotalSpeed: 14}
+
+	frames := []*telemetry.LobbySessionStateFrame{
+		discFrame(0, discState{holder: 0}),
+		discFrame(1, discState{vel: arena.Vec3{Z: 14}, holder: -1}),
+		// The game registers the throw a frame after the release
+		discFrame(2, discState{vel: arena.Vec3{Z: 14}, holder: -1}),
+		discFrame(3, discState{holder: 1}),
+	}
+	frames[2].Session.LastThrow = throw
+	frames[3].Session.LastThrow = throw
+
+	var thrownFrame uint32
+	var flight *DiscFlight
+	for _, frame := range frames {
+		if event := thrownSensor.AddFrame(frame); event.GetDiscThrown() != nil {
+			thrownFrame = frame.GetFrameIndex()
+		}
+		if f := tracker.Update(frame).Completed; f != nil {
+			flight = f
+		}
+	}
+
+	if flight == nil || !flight.Thrown {
+		t.Fatalf("expected a thrown flight, got %+v", flight)
+	}
+	if flight.ThrowFrame != thrownFrame || thrownFrame != 2 {
+		t.Errorf("expected the flight to link the DiscThrown of frame %d, got frame %d", thrownFrame, flight.ThrowFrame)
+	}
+	if flight.ThrowSpeed != 14 {
+		t.Errorf("expected throw speed 14, got %f", flight.ThrowSpeed)
+	}
+}
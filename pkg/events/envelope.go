@@ -0,0 +1,57 @@
+package events
+
+import (
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// EventEnvelope wraps a detected event with the frame that triggered it, so
+// asynchronous consumers can place events on a timeline.
+type EventEnvelope struct {
+	// FrameIndex is the index of the frame that triggered the event
+	FrameIndex uint32
+	// Timestamp is the wall-clock capture time of the frame, or the zero
+	// time if the frame had none
+	Timestamp time.Time
+	// GameClock is the game clock value of the frame in seconds
+	GameClock float64
+	// GameClockDisplay is the game clock as shown in game
+	GameClockDisplay string
+	// Event is the detected event, or nil for a custom event
+	Event *telemetry.LobbySessionEvent
+	// Custom is the custom event reported by a CustomEventSensor, or nil
+	Custom *CustomEvent
+}
+
+// newEnvelopes wraps events with the metadata of the frame that produced
+// them. Custom events follow the telemetry events.
+func newEnvelopes(frame *telemetry.LobbySessionStateFrame, events []*telemetry.LobbySessionEvent, custom []*CustomEvent) []*EventEnvelope {
+	var timestamp time.Time
+	if ts := frame.GetTimestamp(); ts != nil {
+		timestamp = ts.AsTime()
+	}
+	session := frame.GetSession()
+
+	newEnvelope := func() *EventEnvelope {
+		return &EventEnvelope{
+			FrameIndex:       frame.GetFrameIndex(),
+			Timestamp:        timestamp,
+			GameClock:        session.GetGameClock(),
+			GameClockDisplay: session.GetGameClockDisplay(),
+		}
+	}
+
+	envelopes := make([]*EventEnvelope, 0, len(events)+len(custom))
+	for _, event := range events {
+		env := newEnvelope()
+		env.Event = event
+		envelopes = append(envelopes, env)
+	}
+	for _, event := range custom {
+		env := newEnvelope()
+		env.Custom = event
+		envelopes = append(envelopes, env)
+	}
+	return envelopes
+}
@@ -0,0 +1,129 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestAsyncDetector_EnvelopesStampFrameMetadata(t *testing.T) {
+	detector := New(WithEnvelopes())
+	defer detector.Stop()
+
+	ts := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	detector.ProcessFrame(&telemetry.LobbySessionStateFrame{
+		FrameIndex: 42,
+		Timestamp:  timestamppb.New(ts),
+		Session: &apigame.SessionResponse{
+			GameStatus:       GameStatusPostMatch,
+			GameClock:        12.5,
+			GameClockDisplay: "00:12.50",
+		},
+	})
+
+	select {
+	case envelopes := <-detector.EnvelopesChan():
+		if len(envelopes) != 1 {
+			t.Fatalf("expected 1 envelope, got %d", len(envelopes))
+		}
+		env := envelopes[0]
+		if env.FrameIndex != 42 {
+			t.Errorf("expected frame index 42, got %d", env.FrameIndex)
+		}
+		if !env.Timestamp.Equal(ts) {
+			t.Errorf("expected timestamp %v, got %v", ts, env.Timestamp)
+		}
+		if env.GameClock != 12.5 || env.GameClockDisplay != "00:12.50" {
+			t.Errorf("unexpected game clock %f %q", env.GameClock, env.GameClockDisplay)
+		}
+		if env.Event.GetMatchEnded() == nil {
+			t.Errorf("expected MatchEnded event, got %T", env.Event.Event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for envelopes")
+	}
+
+	// EventsChan keeps receiving the telemetry events
+	select {
+	case events := <-detector.EventsChan():
+		if len(events) != 1 || events[0].GetMatchEnded() == nil {
+			t.Errorf("expected MatchEnded on EventsChan, got %v", events)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for events")
+	}
+}
+
+func TestAsyncDetector_EnvelopesNotStalledByEventsChan(t *testing.T) {
+	detector := New(WithEnvelopes(), WithEventsChannelSize(1), WithSensors(&mockSensor{id: "s1"}))
+	defer detector.Stop()
+	envelopes := detector.EnvelopesChan()
+
+	// Nobody reads EventsChan, which only has room for one batch
+	const frames = 5
+	for range frames {
+		detector.ProcessFrame(newStatusOnlyFrame(GameStatusPlaying))
+	}
+	for i := range frames {
+		select {
+		case <-envelopes:
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for envelopes of frame %d", i)
+		}
+	}
+}
+
+func TestAsyncDetector_EnvelopesSynchronous(t *testing.T) {
+	detector := New(WithEnvelopes(), WithSynchronousProcessing())
+	defer detector.Stop()
+
+	detector.ProcessFrame(&telemetry.LobbySessionStateFrame{
+		FrameIndex: 7,
+		Session:    &apigame.SessionResponse{GameStatus: GameStatusRoundOver},
+	})
+
+	select {
+	case envelopes := <-detector.EnvelopesChan():
+		if len(envelopes) != 1 || envelopes[0].FrameIndex != 7 {
+			t.Fatalf("unexpected envelopes %v", envelopes)
+		}
+		if !envelopes[0].Timestamp.IsZero() {
+			t.Errorf("expected zero timestamp for frame without one, got %v", envelopes[0].Timestamp)
+		}
+	default:
+		t.Fatal("expected envelopes to be ready immediately in synchronous mode")
+	}
+}
+
+func TestAsyncDetector_StopClosesEnvelopesChan(t *testing.T) {
+	detector := New(WithEnvelopes())
+	detector.Stop()
+
+	if _, ok := <-detector.EnvelopesChan(); ok {
+		t.Fatal("expected envelopes channel to be closed")
+	}
+}
+
+func TestNewEnvelopes_PreservesOrder(t *testing.T) {
+	frame := &telemetry.LobbySessionStateFrame{FrameIndex: 3}
+	events := []*telemetry.LobbySessionEvent{
+		{Event: &telemetry.LobbySessionEvent_RoundEnded{RoundEnded: &telemetry.RoundEnded{}}},
+		{Event: &telemetry.LobbySessionEvent_MatchEnded{MatchEnded: &telemetry.MatchEnded{}}},
+	}
+
+	envelopes := newEnvelopes(frame, events, nil)
+	if len(envelopes) != 2 {
+		t.Fatalf("expected 2 envelopes, got %d", len(envelopes))
+	}
+	for i, env := range envelopes {
+		if env.Event != events[i] {
+			t.Errorf("envelope %d wraps the wrong event", i)
+		}
+		if env.FrameIndex != 3 {
+			t.Errorf("envelope %d: expected frame index 3, got %d", i, env.FrameIndex)
+		}
+	}
+}
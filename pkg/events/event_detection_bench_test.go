@@ -1,6 +1,8 @@
 package events
 
 import (
+	"fmt"
+	"sync/atomic"
 	"testing"
 
 	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
@@ -57,24 +59,55 @@ func BenchmarkAsyncDetector_addFrameToBuffer(b *testing.B) {
 	}
 }
 
+var benchSensorWorkers = []int{1, 2, 4, 8}
+
 func BenchmarkAsyncDetector_detectEventsWithSensors(b *testing.B) {
-	detector := &AsyncDetector{
-		sensors:     []Sensor{benchSensor{}, benchSensor{}},
-		frameBuffer: make([]*telemetry.LobbySessionStateFrame, DefaultFrameBufferCapacity),
+	for _, workers := range benchSensorWorkers {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			detect := newSensorBench(b, workers)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				detect(i)
+			}
+		})
+	}
+}
+
+// TestSensorBench_Smoke runs a few iterations of the sensor benchmark so
+// changes to the detector setup that break it fail the tests
+func TestSensorBench_Smoke(t *testing.T) {
+	for _, workers := range benchSensorWorkers {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			detect := newSensorBench(t, workers)
+			for i := range 10 {
+				detect(i)
+			}
+		})
+	}
+}
+
+// newSensorBench creates a detector with eight heavy sensors run on workers
+// goroutines and returns a function detecting one frame with it
+func newSensorBench(tb testing.TB, workers int) func(i int) {
+	sensors := make([]Sensor, 8)
+	for i := range sensors {
+		sensors[i] = benchSensor{work: 2000}
 	}
+	detector := New(WithSensors(sensors...), WithParallelSensors(workers))
+	tb.Cleanup(detector.Stop)
+
 	roundOver := newStatusOnlyFrame(GameStatusRoundOver)
 	postMatch := newStatusOnlyFrame(GameStatusPostMatch)
 	var buf []*telemetry.LobbySessionEvent
-
-	b.ReportAllocs()
-	b.ResetTimer()
-
-	for i := 0; i < b.N; i++ {
+	return func(i int) {
 		detector.previousGameStatusFrame = roundOver
 		detector.addFrameToBuffer(postMatch)
 		buf = buf[:0]
-		if events := detector.detectEvents(buf); len(events) == 0 {
-			b.Fatalf("expected events from sensors or detectors at iteration %d", i)
+		if buf = detector.detectEvents(buf); len(buf) != len(sensors)+1 {
+			tb.Fatalf("expected events from sensors and detectors at iteration %d", i)
 		}
 	}
 }
@@ -97,11 +130,22 @@ func BenchmarkAsyncDetector_detectEventsNoTransition(b *testing.B) {
 	}
 }
 
-type benchSensor struct{}
+// benchSensor reports an event for every frame after spinning for work
+// iterations to simulate a heavier sensor
+type benchSensor struct {
+	work int
+}
+
+var benchSink uint64
 
-func (benchSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
+func (s benchSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
 	if frame == nil {
 		return nil
 	}
+	x := uint64(frame.GetFrameIndex()) + 1
+	for range s.work {
+		x = x*6364136223846793005 + 1442695040888963407
+	}
+	atomic.AddUint64(&benchSink, x)
 	return &telemetry.LobbySessionEvent{}
 }
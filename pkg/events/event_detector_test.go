@@ -113,7 +113,7 @@ func TestAsyncDetector_StopClosesEventsChan(t *testing.T) {
 func TestAsyncDetector_SensorIntegrationReceivesFrames(t *testing.T) {
 	detector := newTestAsyncDetector(t)
 	sensor := &recordingSensor{}
-	detector.sensors = []Sensor{sensor}
+	detector.RegisterSensors(AdaptSensor(sensor))
 
 	detector.ProcessFrame(createPostMatchTestFrame("playing", 1, 0))
 
@@ -2,6 +2,51 @@ package events
 
 import "github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
 
+// Sensor reports at most one event per frame
 type Sensor interface {
 	AddFrame(*telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent
 }
+
+// EventSensor reports any number of events per frame by appending them to dst
+type EventSensor interface {
+	DetectEvents(frame *telemetry.LobbySessionStateFrame, dst []*telemetry.LobbySessionEvent) []*telemetry.LobbySessionEvent
+}
+
+// AdaptSensor wraps a single-event Sensor as an EventSensor. Sensors that
+// already implement EventSensor are returned unchanged.
+func AdaptSensor(s Sensor) EventSensor {
+	if es, ok := s.(EventSensor); ok {
+		return es
+	}
+	return sensorAdapter{s}
+}
+
+// sensorAdapter runs a single-event Sensor as an EventSensor
+type sensorAdapter struct {
+	Sensor
+}
+
+// DetectEvents appends the sensor's event, if any, to dst
+func (a sensorAdapter) DetectEvents(frame *telemetry.LobbySessionStateFrame, dst []*telemetry.LobbySessionEvent) []*telemetry.LobbySessionEvent {
+	if event := a.AddFrame(frame); event != nil {
+		dst = append(dst, event)
+	}
+	return dst
+}
+
+// eventQueue lets an EventSensor also satisfy the single-event Sensor
+// interface. Events beyond the first are returned by subsequent calls.
+type eventQueue struct {
+	pending []*telemetry.LobbySessionEvent
+}
+
+// next processes the frame with detect and returns the oldest queued event
+func (q *eventQueue) next(frame *telemetry.LobbySessionStateFrame, detect func(*telemetry.LobbySessionStateFrame, []*telemetry.LobbySessionEvent) []*telemetry.LobbySessionEvent) *telemetry.LobbySessionEvent {
+	q.pending = detect(frame, q.pending)
+	if len(q.pending) == 0 {
+		return nil
+	}
+	event := q.pending[0]
+	q.pending = q.pending[1:]
+	return event
+}
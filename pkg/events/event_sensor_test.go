@@ -0,0 +1,57 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+func TestAdaptSensor_WrapsSingleEventSensor(t *testing.T) {
+	adapted := AdaptSensor(&mockSensor{})
+	if _, ok := adapted.(sensorAdapter); !ok {
+		t.Fatalf("expected sensorAdapter, got %T", adapted)
+	}
+
+	events := adapted.DetectEvents(&telemetry.LobbySessionStateFrame{}, nil)
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+
+	if events := adapted.DetectEvents(nil, events); len(events) != 1 {
+		t.Errorf("expected nil event not to be appended, got %d events", len(events))
+	}
+}
+
+func TestAdaptSensor_KeepsEventSensor(t *testing.T) {
+	sensor := NewStatEventSensor()
+	if adapted := AdaptSensor(sensor); adapted != EventSensor(sensor) {
+		t.Errorf("expected EventSensor to be returned unchanged, got %T", adapted)
+	}
+}
+
+func TestAsyncDetector_DeliversAllSensorEventsWithTheirFrame(t *testing.T) {
+	detector := New(WithSynchronousProcessing(), WithSensors(NewStatEventSensor()))
+	defer detector.Stop()
+
+	detector.ProcessFrame(createFrameWithPlayerStats(1, &apigame.PlayerStats{}))
+	detector.ProcessFrame(createFrameWithPlayerStats(1, &apigame.PlayerStats{Stuns: 2, Passes: 1}))
+
+	select {
+	case events := <-detector.EventsChan():
+		if len(events) != 3 {
+			t.Fatalf("expected all 3 stat events in one batch, got %d", len(events))
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("timed out waiting for events")
+	}
+
+	// A quiet frame must not carry leftover events
+	detector.ProcessFrame(createFrameWithPlayerStats(1, &apigame.PlayerStats{Stuns: 2, Passes: 1}))
+	select {
+	case events := <-detector.EventsChan():
+		t.Fatalf("expected no events for unchanged stats, got %d", len(events))
+	default:
+	}
+}
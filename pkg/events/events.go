@@ -2,8 +2,13 @@ package events
 
 import (
 	"context"
+	"errors"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
 	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
 )
 
@@ -11,16 +16,38 @@ import (
 type Detector interface {
 	// ProcessFrame processes a frame for event detection
 	ProcessFrame(*telemetry.LobbySessionStateFrame)
+	// Detect processes a frame inline and returns the detected events and
+	// custom events
+	Detect(*telemetry.LobbySessionStateFrame) ([]*telemetry.LobbySessionEvent, []*CustomEvent)
 	// EventsChan returns a channel to receive detected events
 	EventsChan() <-chan []*telemetry.LobbySessionEvent
+	// EnvelopesChan returns a channel to receive detected events stamped with
+	// their source frame. It only receives events when envelopes are enabled,
+	// and is the only channel custom events are delivered on.
+	EnvelopesChan() <-chan []*EventEnvelope
+	// RegisterSensors adds sensors to the detector while it is running
+	RegisterSensors(sensors ...EventSensor)
+	// RemoveSensor removes a registered sensor. Returns false if the sensor
+	// was not registered.
+	RemoveSensor(sensor EventSensor) bool
+	// Sensors returns the registered sensors in detection order
+	Sensors() []EventSensor
+	// Stats returns throughput and backpressure counters
+	Stats() DetectorStats
 	// Reset clears the detector state
 	Reset()
 	// Stop gracefully shuts down the detector
 	Stop()
+	// StopAndFlush processes every queued frame and delivers its events
+	// before shutting down the detector
+	StopAndFlush(ctx context.Context) error
 }
 
 const DefaultFrameBufferCapacity = 10
 
+// ErrDetectorStopped is returned by operations on a stopped detector
+var ErrDetectorStopped = errors.New("detector stopped")
+
 // Option configures the AsyncDetector
 type Option func(*AsyncDetector)
 
@@ -45,10 +72,38 @@ func WithFrameBufferSize(size int) Option {
 	}
 }
 
-// WithSensors adds sensors to the detector
+// WithSensors adds single-event sensors to the detector
 func WithSensors(sensors ...Sensor) Option {
 	return func(ed *AsyncDetector) {
-		ed.sensors = append(ed.sensors, sensors...)
+		for _, s := range sensors {
+			ed.sensors = append(ed.sensors, &sensorEntry{sensor: AdaptSensor(s)})
+		}
+	}
+}
+
+// WithEventSensors adds sensors that may report several events per frame.
+// Adding a CustomEventSensor enables envelopes, since custom events are only
+// delivered on EnvelopesChan.
+func WithEventSensors(sensors ...EventSensor) Option {
+	return func(ed *AsyncDetector) {
+		ed.sensors = append(ed.sensors, newSensorEntries(sensors...)...)
+	}
+}
+
+// WithParallelSensors runs the sensors of each frame on up to workers
+// goroutines. Events are still reported in sensor registration order.
+// Sensors must not share unsynchronized state with each other.
+func WithParallelSensors(workers int) Option {
+	return func(ed *AsyncDetector) {
+		ed.sensorWorkers = workers
+	}
+}
+
+// WithContext ties the detector to a parent context. Cancelling it stops
+// the detector as if Stop had been called.
+func WithContext(parent context.Context) Option {
+	return func(ed *AsyncDetector) {
+		ed.parent = parent
 	}
 }
 
@@ -59,6 +114,51 @@ func WithSynchronousProcessing() Option {
 	}
 }
 
+// WithEnvelopes also delivers detected events wrapped in EventEnvelopes on
+// EnvelopesChan, together with custom events. EventsChan keeps receiving the
+// telemetry events. It is implied by WithRules, WithDerivedSensors and
+// custom event sensors.
+func WithEnvelopes() Option {
+	return func(ed *AsyncDetector) {
+		ed.envelopes.Store(true)
+	}
+}
+
+// WithOverflowPolicy sets how ProcessFrame handles a full input queue. It
+// has no effect in synchronous mode.
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(ed *AsyncDetector) {
+		ed.overflowPolicy = policy
+	}
+}
+
+// WithOverflowTimeout selects OverflowBlockWithTimeout with the given timeout
+func WithOverflowTimeout(timeout time.Duration) Option {
+	return func(ed *AsyncDetector) {
+		ed.overflowPolicy = OverflowBlockWithTimeout
+		ed.overflowTimeout = timeout
+	}
+}
+
+// WithDropHandler sets a callback invoked with every frame discarded by the
+// overflow policy. It runs on the goroutine calling ProcessFrame and must
+// not block.
+func WithDropHandler(fn func(*telemetry.LobbySessionStateFrame)) Option {
+	return func(ed *AsyncDetector) {
+		ed.onDrop = fn
+	}
+}
+
+// WithRules evaluates a RuleEngine after the sensors for every frame. Its
+// rules see the telemetry and custom events the sensors and derived sensors
+// detected for the frame, and the custom events they emit are delivered with
+// them. Enables envelopes.
+func WithRules(rules *RuleEngine) Option {
+	return func(ed *AsyncDetector) {
+		ed.rules = rules
+	}
+}
+
 // AsyncDetector detects post_match events
 type AsyncDetector struct {
 	previousGameStatusFrame *telemetry.LobbySessionStateFrame
@@ -68,41 +168,89 @@ type AsyncDetector struct {
 	writeIndex  int // Current write position
 	frameCount  int // Number of frames currently in buffer
 
-	sensors []Sensor
+	// sensors is replaced rather than modified so detection can iterate a
+	// copy of the slice header without holding sensorsMu
+	sensors   []*sensorEntry
+	sensorsMu sync.RWMutex
 
-	// Channel-based processing
-	inputChan  chan *telemetry.LobbySessionStateFrame
-	eventsChan chan []*telemetry.LobbySessionEvent
-	resetChan  chan struct{}
-	ctx        context.Context
-	cancel     context.CancelFunc
-	wg         sync.WaitGroup
-	stopOnce   sync.Once
+	// players is shared by every sensor implementing PlayerRegistryUser and
+	// updated once per frame before they run. playersStale is set when a
+	// frame was detected without updating it.
+	players      *PlayerRegistry
+	playersStale bool
+
+	// derived run after the sensors on the events they detected, with the
+	// events of recent frames in history
+	derived []DerivedSensor
+	history *EventHistory
 
-	// Reusable buffer for events to reduce allocations
-	eventBuffer []*telemetry.LobbySessionEvent
+	// rules is evaluated after the sensors, or nil
+	rules *RuleEngine
 
-	synchronous bool
+	counters detectorCounters
+
+	// stateMu guards the frame buffer and sensor state while frames are
+	// detected on callers' goroutines: by Detect, and by ProcessFrame in
+	// synchronous mode
+	stateMu sync.Mutex
+
+	// Channel-based processing
+	inputChan     chan *telemetry.LobbySessionStateFrame
+	eventsChan    chan []*telemetry.LobbySessionEvent
+	envelopesChan chan []*EventEnvelope
+	controlChan   chan func()
+	parent        context.Context
+	ctx           context.Context
+	cancel        context.CancelFunc
+	stopOnParent  func() bool
+	wg            sync.WaitGroup
+	stopOnce      sync.Once
+
+	// Reusable buffers for events to reduce allocations
+	eventBuffer  []*telemetry.LobbySessionEvent
+	customBuffer []*CustomEvent
+
+	// envelopes is set once custom events can be reported. eventsRead and
+	// envelopesRead record which channels were asked for, so a consumer of
+	// envelopes is not stalled by an unread EventsChan.
+	envelopes     atomic.Bool
+	eventsRead    atomic.Bool
+	envelopesRead atomic.Bool
+
+	synchronous   bool
+	sensorWorkers int
+
+	overflowPolicy  OverflowPolicy
+	overflowTimeout time.Duration
+	onDrop          func(*telemetry.LobbySessionStateFrame)
 }
 
 var _ Detector = (*AsyncDetector)(nil)
 
 // New creates a new event detector with goroutine-based processing
 func New(opts ...Option) *AsyncDetector {
-	ctx, cancel := context.WithCancel(context.Background())
 	ed := &AsyncDetector{
 		inputChan:   make(chan *telemetry.LobbySessionStateFrame, 100),
 		eventsChan:  make(chan []*telemetry.LobbySessionEvent, 10),
-		resetChan:   make(chan struct{}),
-		ctx:         ctx,
-		cancel:      cancel,
+		controlChan: make(chan func()),
+		parent:      context.Background(),
 		frameBuffer: make([]*telemetry.LobbySessionStateFrame, DefaultFrameBufferCapacity),
 		eventBuffer: make([]*telemetry.LobbySessionEvent, 0, 10),
+		players:     NewPlayerRegistry(),
+		history:     NewEventHistory(DefaultEventHistoryWindow),
 	}
 
 	for _, opt := range opts {
 		opt(ed)
 	}
+	ed.attachSensors(ed.sensors)
+	if ed.rules != nil || len(ed.derived) > 0 || slices.ContainsFunc(ed.sensors, (*sensorEntry).reportsCustom) {
+		// Custom events are only delivered in envelopes
+		ed.envelopes.Store(true)
+	}
+	ed.envelopesChan = make(chan []*EventEnvelope, cap(ed.eventsChan))
+	ed.ctx, ed.cancel = context.WithCancel(ed.parent)
+	ed.stopOnParent = context.AfterFunc(ed.parent, ed.Stop)
 
 	ed.Start()
 	return ed
@@ -117,109 +265,407 @@ func (ed *AsyncDetector) Start() {
 // Stop gracefully shuts down the event detector
 func (ed *AsyncDetector) Stop() {
 	ed.stopOnce.Do(func() {
+		ed.stopOnParent()
 		ed.cancel()
 		ed.wg.Wait()
 		close(ed.eventsChan)
+		close(ed.envelopesChan)
 	})
 }
 
-// Reset clears the event detector state
+// StopAndFlush processes the frames already queued, delivers their events
+// and then stops the detector. Frames submitted after the call may be
+// discarded. If ctx expires before the queue is flushed, the remaining frames
+// are discarded and ctx.Err() is returned.
+func (ed *AsyncDetector) StopAndFlush(ctx context.Context) error {
+	defer ed.Stop()
+
+	// The loop may be blocked delivering events, so give up on handing it
+	// the flush when ctx expires
+	result := make(chan error, 1)
+	select {
+	case ed.controlChan <- func() { result <- ed.flushInputChan(ctx) }:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-ed.ctx.Done():
+		return nil
+	}
+	return <-result
+}
+
+// flushInputChan processes every queued frame, blocking on delivery of its
+// events until ctx expires or the detector stops
+func (ed *AsyncDetector) flushInputChan(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(ed.ctx, cancel)()
+
+	for {
+		select {
+		case frame := <-ed.inputChan:
+			ed.eventBuffer = ed.detectFrame(frame, ed.eventBuffer[:0])
+			if !ed.sendEvents(ctx, frame, ed.eventBuffer, true) {
+				if ed.ctx.Err() != nil {
+					return ErrDetectorStopped
+				}
+				return ctx.Err()
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// Reset clears the event detector state, including the state of every
+// sensor that implements Resettable, and waits for it to be cleared
 func (ed *AsyncDetector) Reset() {
+	ed.withState(ed.resetState)
+}
+
+// RegisterSensors adds sensors to the detector. It is safe to call while
+// frames are being processed; the sensors see frames from the next detection
+// cycle onward. Sensors implementing PlayerRegistryUser are attached to the
+// detector's player registry, so players already present are not reported
+// as joining. Registering a CustomEventSensor enables envelopes.
+func (ed *AsyncDetector) RegisterSensors(sensors ...EventSensor) {
+	entries := newSensorEntries(sensors...)
+	ed.attachSensors(entries)
+	if slices.ContainsFunc(entries, (*sensorEntry).reportsCustom) {
+		ed.envelopes.Store(true)
+	}
+
+	ed.sensorsMu.Lock()
+	defer ed.sensorsMu.Unlock()
+	ed.sensors = append(slices.Clip(ed.sensors), entries...)
+}
+
+// attachSensors hands the player registry to the sensors that use it
+func (ed *AsyncDetector) attachSensors(entries []*sensorEntry) {
+	for _, e := range entries {
+		if u, ok := unwrapSensor(e.sensor).(PlayerRegistryUser); ok {
+			u.UsePlayerRegistry(ed.players)
+			e.usesPlayers = true
+		}
+	}
+}
+
+// RemoveSensor removes a registered sensor. Sensors registered with
+// WithSensors are matched by the single-event Sensor they wrap. Returns false
+// if the sensor was not registered. A removed PlayerRegistryUser keeps
+// reading the detector's registry until it is given another one.
+func (ed *AsyncDetector) RemoveSensor(sensor EventSensor) bool {
+	target := unwrapSensor(sensor)
+
+	ed.sensorsMu.Lock()
+	defer ed.sensorsMu.Unlock()
+	for i, e := range ed.sensors {
+		if unwrapSensor(e.sensor) == target {
+			ed.sensors = slices.Delete(slices.Clone(ed.sensors), i, i+1)
+			return true
+		}
+	}
+	return false
+}
+
+// Sensors returns a copy of the registered sensors in detection order
+func (ed *AsyncDetector) Sensors() []EventSensor {
+	return sensorsOf(ed.loadSensors())
+}
+
+// Stats returns throughput and backpressure counters. It is safe to call
+// from any goroutine.
+func (ed *AsyncDetector) Stats() DetectorStats {
+	entries := ed.loadSensors()
+	stats := DetectorStats{
+		FramesReceived:      ed.counters.framesReceived.Load(),
+		FramesDropped:       ed.counters.framesDropped.Load(),
+		EventBatchesDropped: ed.counters.eventBatchesDropped.Load(),
+		InputQueueDepth:     len(ed.inputChan),
+		EventQueueDepth:     len(ed.eventsChan) + len(ed.envelopesChan),
+		Sensors:             make([]SensorStats, len(entries)),
+	}
+	for i, e := range entries {
+		stats.Sensors[i] = e.stats()
+	}
+	return stats
+}
+
+// loadSensors returns the current sensor slice, which must not be modified
+func (ed *AsyncDetector) loadSensors() []*sensorEntry {
+	ed.sensorsMu.RLock()
+	defer ed.sensorsMu.RUnlock()
+	return ed.sensors
+}
+
+// Snapshot checkpoints the state of the detector, its sensors, derived
+// sensors, event history and rules. The snapshot is taken between frames.
+// Fails with ErrNotSnapshotter if a sensor keeps state but does not
+// implement Snapshotter.
+func (ed *AsyncDetector) Snapshot() (*DetectorSnapshot, error) {
+	var snap *DetectorSnapshot
+	var err error
+	if !ed.withState(func() {
+		s := &DetectorSnapshot{}
+		if s.Sensors, err = snapshotSensors(sensorsOf(ed.loadSensors())); err != nil {
+			return
+		}
+		if len(ed.derived) > 0 {
+			if s.Derived, err = snapshotSensors(ed.derived); err != nil {
+				return
+			}
+			if s.History, err = ed.history.Snapshot(); err != nil {
+				return
+			}
+		}
+		if ed.rules != nil {
+			if s.Rules, err = ed.rules.Snapshot(); err != nil {
+				return
+			}
+		}
+		if s.Players, err = ed.players.Snapshot(); err != nil {
+			return
+		}
+		snap = s
+		if ed.previousGameStatusFrame != nil {
+			snap.PreviousGameStatus = ed.previousGameStatusFrame.GetSession().GetGameStatus()
+		}
+	}) {
+		return nil, ErrDetectorStopped
+	}
+	return snap, err
+}
+
+// Restore replaces the detector and sensor state with a snapshot taken from
+// a detector configured with the same sensors, derived sensors and rules in
+// the same order
+func (ed *AsyncDetector) Restore(snap *DetectorSnapshot) error {
+	if snap == nil {
+		return errors.New("nil snapshot")
+	}
+	if (ed.rules == nil) != (snap.Rules == nil) {
+		return errors.New("snapshot and detector differ in whether rules are configured")
+	}
+	var err error
+	if !ed.withState(func() {
+		if err = restoreSensors(sensorsOf(ed.loadSensors()), snap.Sensors); err != nil {
+			return
+		}
+		if err = restoreSensors(ed.derived, snap.Derived); err != nil {
+			return
+		}
+		ed.history.Reset()
+		if snap.History != nil {
+			if err = ed.history.Restore(snap.History); err != nil {
+				return
+			}
+		}
+		if ed.rules != nil {
+			if err = ed.rules.Restore(snap.Rules); err != nil {
+				return
+			}
+		}
+		ed.players.Reset()
+		ed.playersStale = false
+		if snap.Players != nil {
+			if err = ed.players.Restore(snap.Players); err != nil {
+				return
+			}
+		}
+		ed.previousGameStatusFrame = nil
+		if snap.PreviousGameStatus != "" {
+			ed.previousGameStatusFrame = &telemetry.LobbySessionStateFrame{
+				Session: &apigame.SessionResponse{GameStatus: snap.PreviousGameStatus},
+			}
+		}
+	}) {
+		return ErrDetectorStopped
+	}
+	return err
+}
+
+// withState runs fn between frames with exclusive access to the detector
+// state and waits for it to finish. Synchronous detectors run fn inline,
+// since frames are detected on the callers' goroutines. Returns false if the
+// detector stopped first.
+func (ed *AsyncDetector) withState(fn func()) bool {
+	if !ed.synchronous {
+		return ed.runInLoop(fn)
+	}
+	ed.stateMu.Lock()
+	defer ed.stateMu.Unlock()
+	if ed.ctx.Err() != nil {
+		return false
+	}
+	fn()
+	return true
+}
+
+// runInLoop runs fn on the processing goroutine between frames and waits for
+// it to finish. Returns false if the detector stopped first.
+func (ed *AsyncDetector) runInLoop(fn func()) bool {
+	done := make(chan struct{})
 	select {
-	case ed.resetChan <- struct{}{}:
+	case ed.controlChan <- func() { ed.runLocked(fn); close(done) }:
 	case <-ed.ctx.Done():
+		return false
 	}
+	<-done
+	return true
+}
+
+// runLocked runs fn holding stateMu, which keeps Detect out while fn runs
+func (ed *AsyncDetector) runLocked(fn func()) {
+	ed.stateMu.Lock()
+	defer ed.stateMu.Unlock()
+	fn()
 }
 
-// ProcessFrame writes a frame to the processing channel (non-blocking)
+// ProcessFrame writes a frame to the processing channel. When the channel is
+// full the overflow policy decides whether to wait or which frame to drop.
 func (ed *AsyncDetector) ProcessFrame(frame *telemetry.LobbySessionStateFrame) {
+	ed.counters.framesReceived.Add(1)
 	if ed.synchronous {
 		ed.processFrameSync(frame)
 		return
 	}
 
-	select {
-	case ed.inputChan <- frame:
-		// Frame sent successfully
-	case <-ed.ctx.Done():
-		// Detector is stopping, ignore frame
-	default:
-		// Channel full, drop frame (could also block or log)
+	enqueue(ed.inputChan, frame, ed.overflowPolicy, ed.overflowTimeout, ed.ctx.Done(), ed.dropFrame)
+}
+
+// dropFrame records a frame discarded by the overflow policy
+func (ed *AsyncDetector) dropFrame(frame *telemetry.LobbySessionStateFrame) {
+	ed.counters.framesDropped.Add(1)
+	if ed.onDrop != nil {
+		ed.onDrop(frame)
 	}
 }
 
+// Detect runs the sensors on a frame in the calling goroutine and returns
+// the detected events and custom events. Neither is sent to EventsChan or
+// EnvelopesChan.
+// Detect must not be mixed with ProcessFrame on a detector that is not
+// synchronous, since both would update the frame buffer concurrently.
+func (ed *AsyncDetector) Detect(frame *telemetry.LobbySessionStateFrame) ([]*telemetry.LobbySessionEvent, []*CustomEvent) {
+	ed.counters.framesReceived.Add(1)
+	ed.stateMu.Lock()
+	defer ed.stateMu.Unlock()
+	events := ed.detectFrame(frame, nil)
+
+	var custom []*CustomEvent
+	if len(ed.customBuffer) > 0 {
+		custom = slices.Clone(ed.customBuffer)
+	}
+	return events, custom
+}
+
 func (ed *AsyncDetector) processFrameSync(frame *telemetry.LobbySessionStateFrame) {
-	// Add frame to buffer
-	ed.addFrameToBuffer(frame)
+	ed.stateMu.Lock()
+	defer ed.stateMu.Unlock()
+	ed.eventBuffer = ed.detectFrame(frame, ed.eventBuffer[:0])
+
+	// Send events if any were detected.
+	// In synchronous mode, use non-blocking send to avoid blocking ProcessFrame.
+	// This ensures ProcessFrame completes immediately in the caller's goroutine.
+	// Events are dropped if the channel is full, which is acceptable since
+	// synchronous mode prioritizes immediate processing over guaranteed delivery.
+	ed.sendEvents(ed.ctx, frame, ed.eventBuffer, false)
+}
+
+// sendEvents delivers the events detected for a frame to EventsChan and,
+// when envelopes are enabled, to EnvelopesChan along with the frame's custom
+// events. A blocking send waits for a consumer of EventsChan unless only
+// EnvelopesChan was asked for, and for a consumer of EnvelopesChan once it
+// was asked for. Returns false if ctx was done before the events could be
+// delivered.
+func (ed *AsyncDetector) sendEvents(ctx context.Context, frame *telemetry.LobbySessionStateFrame, events []*telemetry.LobbySessionEvent, block bool) bool {
+	envelopesRead := ed.envelopesRead.Load()
+
+	// Copy events to avoid race conditions with the reused buffers
+	var eventsToSend []*telemetry.LobbySessionEvent
+	if len(events) > 0 {
+		eventsToSend = slices.Clone(events)
+		if envelopesRead && !ed.eventsRead.Load() {
+			offer(ed.eventsChan, eventsToSend)
+		} else if !send(ctx, ed.eventsChan, eventsToSend, block, &ed.counters.eventBatchesDropped) {
+			return false
+		}
+	}
 
-	// Detect events using the detection algorithm
-	ed.eventBuffer = ed.eventBuffer[:0]
-	ed.eventBuffer = ed.detectEvents(ed.eventBuffer)
+	if !ed.envelopes.Load() || len(events)+len(ed.customBuffer) == 0 {
+		return true
+	}
+	envelopes := newEnvelopes(frame, eventsToSend, slices.Clone(ed.customBuffer))
+	if !envelopesRead {
+		offer(ed.envelopesChan, envelopes)
+		return true
+	}
+	return send(ctx, ed.envelopesChan, envelopes, block, &ed.counters.eventBatchesDropped)
+}
 
-	// Send events if any were detected
-	if len(ed.eventBuffer) > 0 {
-		// Copy events to avoid race conditions with the reused buffer
-		eventsToSend := make([]*telemetry.LobbySessionEvent, len(ed.eventBuffer))
-		copy(eventsToSend, ed.eventBuffer)
+// offer buffers v on a channel nobody has asked for if there is room, so a
+// consumer of the other channel is not held up by it
+func offer[T any](ch chan<- T, v T) {
+	select {
+	case ch <- v:
+	default:
+	}
+}
 
-		// In synchronous mode, use non-blocking send to avoid blocking ProcessFrame.
-		// This ensures ProcessFrame completes immediately in the caller's goroutine.
-		// Events are dropped if the channel is full, which is acceptable since
-		// synchronous mode prioritizes immediate processing over guaranteed delivery.
+// send writes v to ch, giving up when ctx is done or, for non-blocking
+// sends, when ch is full, in which case dropped is incremented
+func send[T any](ctx context.Context, ch chan<- T, v T, block bool, dropped *atomic.Uint64) bool {
+	if block {
 		select {
-		case ed.eventsChan <- eventsToSend:
-			// Events sent successfully
-		case <-ed.ctx.Done():
-			// Detector is stopping
-			return
-		default:
-			// Channel is full, drop events rather than blocking.
-			// This maintains the synchronous processing guarantee.
+		case ch <- v:
+			return true
+		case <-ctx.Done():
+			return false
 		}
 	}
+
+	select {
+	case ch <- v:
+	case <-ctx.Done():
+		return false
+	default:
+		// Channel is full, drop rather than blocking
+		dropped.Add(1)
+	}
+	return true
 }
 
 // EventsChan returns the channel for receiving detected events
 func (ed *AsyncDetector) EventsChan() <-chan []*telemetry.LobbySessionEvent {
+	ed.eventsRead.Store(true)
 	return ed.eventsChan
 }
 
+// EnvelopesChan returns the channel for receiving detected events wrapped in
+// envelopes. It only receives events when envelopes are enabled. Once it has
+// been called, detection waits for envelopes to be read, and only waits for
+// EventsChan if that was asked for too.
+func (ed *AsyncDetector) EnvelopesChan() <-chan []*EventEnvelope {
+	ed.envelopesRead.Store(true)
+	return ed.envelopesChan
+}
+
 // processLoop is the background goroutine that processes frames
 func (ed *AsyncDetector) processLoop() {
 	defer ed.wg.Done()
 
 	for {
 		select {
-		case <-ed.resetChan:
-			ed.writeIndex = 0
-			ed.frameCount = 0
-			ed.previousGameStatusFrame = nil
-			for i := range ed.frameBuffer {
-				ed.frameBuffer[i] = nil
-			}
+		case fn := <-ed.controlChan:
+			fn()
 
 		case frame := <-ed.inputChan:
-			// Add frame to buffer
-			ed.addFrameToBuffer(frame)
-
-			// Detect events using the detection algorithm
-			ed.eventBuffer = ed.eventBuffer[:0]
-			ed.eventBuffer = ed.detectEvents(ed.eventBuffer)
+			ed.eventBuffer = ed.detectFrame(frame, ed.eventBuffer[:0])
 
 			// Send events if any were detected
-			if len(ed.eventBuffer) > 0 {
-				// Copy events to avoid race conditions with the reused buffer
-				eventsToSend := make([]*telemetry.LobbySessionEvent, len(ed.eventBuffer))
-				copy(eventsToSend, ed.eventBuffer)
-
-				select {
-				case ed.eventsChan <- eventsToSend:
-					// Events sent successfully
-				case <-ed.ctx.Done():
-					// Context cancelled, drain inputChan and exit
-					ed.drainInputChan()
-					return
-				}
+			if !ed.sendEvents(ed.ctx, frame, ed.eventBuffer, true) {
+				// Context cancelled, drain inputChan and exit
+				ed.drainInputChan()
+				return
 			}
 
 		case <-ed.ctx.Done():
@@ -230,6 +676,39 @@ func (ed *AsyncDetector) processLoop() {
 	}
 }
 
+// detectFrame adds a frame to the buffer and appends the events it triggers
+// to dst. The frame's custom events are left in customBuffer.
+func (ed *AsyncDetector) detectFrame(frame *telemetry.LobbySessionStateFrame, dst []*telemetry.LobbySessionEvent) []*telemetry.LobbySessionEvent {
+	clear(ed.customBuffer)
+	ed.customBuffer = ed.customBuffer[:0]
+	ed.addFrameToBuffer(frame)
+	return ed.detectEvents(dst)
+}
+
+// resetState clears the frame buffer, transition tracking and sensor state
+func (ed *AsyncDetector) resetState() {
+	ed.writeIndex = 0
+	ed.frameCount = 0
+	ed.previousGameStatusFrame = nil
+	for i := range ed.frameBuffer {
+		ed.frameBuffer[i] = nil
+	}
+	ed.players.Reset()
+	ed.playersStale = false
+	if ed.rules != nil {
+		ed.rules.Reset()
+	}
+	ed.history.Reset()
+	for _, e := range ed.loadSensors() {
+		resetSensor(e.sensor)
+	}
+	for _, d := range ed.derived {
+		if r, ok := d.(Resettable); ok {
+			r.Reset()
+		}
+	}
+}
+
 // drainInputChan drains any remaining frames from inputChan to prevent resource leaks
 func (ed *AsyncDetector) drainInputChan() {
 	for {
@@ -287,10 +766,33 @@ func (ed *AsyncDetector) detectEvents(dst []*telemetry.LobbySessionEvent) []*tel
 		return dst
 	}
 
-	for _, s := range ed.sensors {
-		event := s.AddFrame(ed.lastFrame())
-		if event != nil {
-			dst = append(dst, event)
+	frame := ed.lastFrame()
+	entries := ed.loadSensors()
+	first := len(dst)
+	if slices.ContainsFunc(entries, (*sensorEntry).usesPlayerRegistry) {
+		if ed.playersStale {
+			// A registry user was registered since the last frame, so seed
+			// the registry with the players already present
+			ed.players.Reset()
+			ed.players.Update(ed.getFrame(1).GetSession())
+			ed.playersStale = false
+		}
+		ed.players.Update(frame.GetSession())
+	} else {
+		ed.playersStale = true
+	}
+	if ed.sensorWorkers > 1 && len(entries) > 1 {
+		dst, ed.customBuffer = detectSensorsParallel(frame, entries, ed.sensorWorkers, dst, ed.customBuffer)
+	} else {
+		start := time.Now()
+		for _, e := range entries {
+			dst = e.sensor.DetectEvents(frame, dst)
+			if e.custom != nil {
+				ed.customBuffer = e.custom.DetectCustomEvents(frame, ed.customBuffer)
+			}
+			end := time.Now()
+			e.record(end.Sub(start))
+			start = end
 		}
 	}
 
@@ -299,6 +801,12 @@ func (ed *AsyncDetector) detectEvents(dst []*telemetry.LobbySessionEvent) []*tel
 	} {
 		dst = fn(ed.lastFrameIndex(), dst)
 	}
+	if len(ed.derived) > 0 {
+		ed.detectDerived(frame, dst[first:])
+	}
+	if ed.rules != nil {
+		ed.customBuffer = ed.rules.Evaluate(frame, dst[first:], ed.customBuffer, ed.customBuffer)
+	}
 
 	return dst
 }
@@ -10,7 +10,7 @@ import (
 )
 
 func BenchmarkAsyncDetector_ProcessFrame(b *testing.B) {
-	detector := New()
+	detector := newBlockingDetector()
 	defer detector.Stop()
 
 	frame := createPostMatchTestFrame("playing", 1, 0)
@@ -26,6 +26,8 @@ func BenchmarkAsyncDetector_ProcessFrame(b *testing.B) {
 	for b.Loop() {
 		detector.ProcessFrame(frame)
 	}
+
+	assertNoDrops(b, detector)
 }
 
 func BenchmarkAsyncDetector_ProcessFrame_WithTransition(b *testing.B) {
@@ -37,7 +39,7 @@ func BenchmarkAsyncDetector_ProcessFrame_WithTransition(b *testing.B) {
 	b.ReportAllocs()
 
 	for b.Loop() {
-		detector := New()
+		detector := newBlockingDetector()
 
 		// Drain events channel in background
 		go func() {
@@ -50,11 +52,12 @@ func BenchmarkAsyncDetector_ProcessFrame_WithTransition(b *testing.B) {
 		}
 
 		detector.Stop()
+		assertNoDrops(b, detector)
 	}
 }
 
 func BenchmarkAsyncDetector_ProcessFrame_FullBuffer(b *testing.B) {
-	detector := New()
+	detector := newBlockingDetector()
 	defer detector.Stop()
 
 	// Drain events channel in background
@@ -76,6 +79,8 @@ func BenchmarkAsyncDetector_ProcessFrame_FullBuffer(b *testing.B) {
 	for b.Loop() {
 		detector.ProcessFrame(frame)
 	}
+
+	assertNoDrops(b, detector)
 }
 
 func BenchmarkAsyncDetector_ProcessFrame_Sequence(b *testing.B) {
@@ -110,7 +115,7 @@ func BenchmarkAsyncDetector_ProcessFrame_Sequence(b *testing.B) {
 	b.ReportAllocs()
 
 	for b.Loop() {
-		detector := New()
+		detector := newBlockingDetector()
 
 		// Drain events channel in background
 		go func() {
@@ -124,5 +129,22 @@ func BenchmarkAsyncDetector_ProcessFrame_Sequence(b *testing.B) {
 		}
 
 		detector.Stop()
+		assertNoDrops(b, detector)
+	}
+}
+
+// newBlockingDetector creates a detector whose ProcessFrame waits for room
+// in the input queue, so benchmarks measure processing rather than the drop
+// path
+func newBlockingDetector() *AsyncDetector {
+	return New(WithOverflowPolicy(OverflowBlock))
+}
+
+// assertNoDrops fails the benchmark if the detector dropped frames or events
+func assertNoDrops(b *testing.B, detector *AsyncDetector) {
+	b.Helper()
+	stats := detector.Stats()
+	if stats.FramesDropped != 0 || stats.EventBatchesDropped != 0 {
+		b.Fatalf("detector dropped %d frames and %d event batches", stats.FramesDropped, stats.EventBatchesDropped)
 	}
 }
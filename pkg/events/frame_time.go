@@ -0,0 +1,68 @@
+package events
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// maxFrameGap is the longest gap, in seconds, between two frames for the
+// time between them to be counted by sensors that accumulate durations
+const maxFrameGap = 1.0
+
+// FrameTime is the moment a frame was captured. The game clock is kept as a
+// fallback for frames without a timestamp.
+type FrameTime struct {
+	wall  time.Time
+	clock float64
+}
+
+// FrameTimeOf returns the moment a frame was captured
+func FrameTimeOf(frame *telemetry.LobbySessionStateFrame) FrameTime {
+	t := FrameTime{clock: frame.GetSession().GetGameClock()}
+	if ts := frame.GetTimestamp(); ts != nil {
+		t.wall = ts.AsTime()
+	}
+	return t
+}
+
+// Since returns the seconds elapsed from start to t. Timestamps are used
+// when both frames have one; otherwise the game clock, which counts down.
+func (t FrameTime) Since(start FrameTime) float64 {
+	if !t.wall.IsZero() && !start.wall.IsZero() {
+		return t.wall.Sub(start.wall).Seconds()
+	}
+	return start.clock - t.clock
+}
+
+// Equal reports whether t and o are the same moment
+func (t FrameTime) Equal(o FrameTime) bool {
+	return t.wall.Equal(o.wall) && t.clock == o.clock
+}
+
+// Wall returns the frame's timestamp, or the zero time if it has none
+func (t FrameTime) Wall() time.Time {
+	return t.wall
+}
+
+// frameTimeState is the JSON representation of a FrameTime
+type frameTimeState struct {
+	Wall  time.Time `json:"wall,omitzero"`
+	Clock float64   `json:"clock"`
+}
+
+// MarshalJSON encodes the timestamp and game clock
+func (t FrameTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(frameTimeState{Wall: t.wall, Clock: t.clock})
+}
+
+// UnmarshalJSON decodes a FrameTime encoded by MarshalJSON
+func (t *FrameTime) UnmarshalJSON(data []byte) error {
+	var state frameTimeState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	*t = FrameTime{wall: state.Wall, clock: state.Clock}
+	return nil
+}
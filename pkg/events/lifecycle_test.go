@@ -0,0 +1,158 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+func TestAsyncDetector_ParentContextCancelStops(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	detector := New(WithContext(parent))
+
+	cancel()
+
+	select {
+	case _, ok := <-detector.EventsChan():
+		if ok {
+			t.Fatal("expected events channel to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for detector to stop on parent cancellation")
+	}
+
+	// Stop remains safe after the parent tore the detector down
+	detector.Stop()
+}
+
+func TestAsyncDetector_StopAndFlushDeliversQueuedEvents(t *testing.T) {
+	detector := New(WithEventsChannelSize(1), WithSensors(&mockSensor{id: "s1"}))
+	release := holdProcessLoop(detector)
+
+	const frames = 5
+	for range frames {
+		detector.ProcessFrame(newStatusOnlyFrame(GameStatusPlaying))
+	}
+
+	received := make(chan int)
+	go func() {
+		count := 0
+		for events := range detector.EventsChan() {
+			count += len(events)
+		}
+		received <- count
+	}()
+
+	release()
+	if err := detector.StopAndFlush(context.Background()); err != nil {
+		t.Fatalf("StopAndFlush failed: %v", err)
+	}
+
+	select {
+	case count := <-received:
+		if count != frames {
+			t.Errorf("expected %d events, got %d", frames, count)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for events channel to close")
+	}
+}
+
+func TestAsyncDetector_StopAndFlushTimeout(t *testing.T) {
+	detector := New(WithEventsChannelSize(1), WithSensors(&mockSensor{id: "s1"}))
+	release := holdProcessLoop(detector)
+	for range 3 {
+		detector.ProcessFrame(newStatusOnlyFrame(GameStatusPlaying))
+	}
+	release()
+
+	// Nobody consumes events, so the flush cannot complete
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := detector.StopAndFlush(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	for range detector.EventsChan() {
+	}
+}
+
+// A loop blocked delivering events must not keep StopAndFlush from giving
+// up when ctx expires
+func TestAsyncDetector_StopAndFlushWhileDeliveryBlocked(t *testing.T) {
+	detector := New(WithEventsChannelSize(1), WithSensors(&mockSensor{id: "s1"}))
+	for range 3 {
+		detector.ProcessFrame(newStatusOnlyFrame(GameStatusPlaying))
+	}
+
+	// Nobody consumes events: wait for the channel to fill so the loop
+	// blocks on the next batch
+	deadline := time.Now().Add(time.Second)
+	for len(detector.EventsChan()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- detector.StopAndFlush(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected deadline exceeded, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("StopAndFlush hung while the loop was blocked on delivery")
+	}
+
+	for range detector.EventsChan() {
+	}
+}
+
+func TestAsyncDetector_StopAndFlushAfterStop(t *testing.T) {
+	detector := New()
+	detector.Stop()
+
+	if err := detector.StopAndFlush(context.Background()); err != nil {
+		t.Errorf("expected nil error after Stop, got %v", err)
+	}
+}
+
+// Run with -race: synchronous detectors detect on the caller's goroutine,
+// so Reset, Snapshot and Restore must not hand the state to processLoop
+func TestAsyncDetector_SynchronousStateChangesAreInline(t *testing.T) {
+	detector := NewWithDefaultSensors(WithSynchronousProcessing())
+	defer detector.Stop()
+
+	frame := createFrameWithPlayers(createPlayer(1, "Alice", 10))
+	frame.Session.GameStatus = GameStatusPlaying
+	for i := range 20 {
+		detector.Detect(frame)
+		detector.Reset()
+		// The reset has completed, so Alice joins again
+		if events, _ := detector.Detect(frame); !slices.ContainsFunc(events, isPlayerJoined) {
+			t.Fatalf("iteration %d: expected PlayerJoined after Reset, got %v", i, events)
+		}
+
+		snap, err := detector.Snapshot()
+		if err != nil {
+			t.Fatalf("Snapshot failed: %v", err)
+		}
+		detector.ProcessFrame(frame)
+		if err := detector.Restore(snap); err != nil {
+			t.Fatalf("Restore failed: %v", err)
+		}
+	}
+}
+
+func isPlayerJoined(e *telemetry.LobbySessionEvent) bool {
+	return e.GetPlayerJoined() != nil
+}
@@ -0,0 +1,73 @@
+package events
+
+import "time"
+
+// OverflowPolicy decides what happens when a bounded queue is full
+type OverflowPolicy int
+
+const (
+	// OverflowDropNewest discards the incoming item (default)
+	OverflowDropNewest OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest queued item to make room for
+	// the incoming one. With a queue size of 1 this coalesces to the
+	// latest item.
+	OverflowDropOldest
+	// OverflowBlock waits until there is room, never losing an item
+	OverflowBlock
+	// OverflowBlockWithTimeout waits up to the overflow timeout, then
+	// discards the incoming item
+	OverflowBlockWithTimeout
+)
+
+// enqueue writes v to ch, applying policy when ch is full. drop is called
+// with every item discarded to make room. Gives up without dropping once
+// done is closed.
+func enqueue[T any](ch chan T, v T, policy OverflowPolicy, timeout time.Duration, done <-chan struct{}, drop func(T)) {
+	select {
+	case ch <- v:
+		return
+	case <-done:
+		return
+	default:
+		// Queue full, apply the overflow policy
+	}
+
+	switch policy {
+	case OverflowBlock:
+		select {
+		case ch <- v:
+		case <-done:
+		}
+
+	case OverflowBlockWithTimeout:
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case ch <- v:
+		case <-done:
+		case <-timer.C:
+			drop(v)
+		}
+
+	case OverflowDropOldest:
+		for {
+			select {
+			case ch <- v:
+				return
+			case <-done:
+				return
+			default:
+			}
+			// The consumer may empty the queue first, in which case the
+			// next send succeeds without dropping anything
+			select {
+			case oldest := <-ch:
+				drop(oldest)
+			default:
+			}
+		}
+
+	default:
+		drop(v)
+	}
+}
@@ -0,0 +1,154 @@
+package events
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// holdProcessLoop parks the processing goroutine so the input queue cannot
+// drain until the returned function is called
+func holdProcessLoop(ed *AsyncDetector) func() {
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	go ed.runInLoop(func() {
+		close(entered)
+		<-release
+	})
+	<-entered
+	return sync.OnceFunc(func() { close(release) })
+}
+
+func indexedFrame(index uint32) *telemetry.LobbySessionStateFrame {
+	frame := newStatusOnlyFrame(GameStatusPlaying)
+	frame.FrameIndex = index
+	return frame
+}
+
+func TestAsyncDetector_OverflowDropNewest(t *testing.T) {
+	var dropped []uint32
+	detector := New(
+		WithInputChannelSize(1),
+		WithDropHandler(func(f *telemetry.LobbySessionStateFrame) { dropped = append(dropped, f.GetFrameIndex()) }),
+	)
+	defer detector.Stop()
+	release := holdProcessLoop(detector)
+	defer release()
+
+	for i := range uint32(3) {
+		detector.ProcessFrame(indexedFrame(i))
+	}
+
+	if len(dropped) != 2 || dropped[0] != 1 || dropped[1] != 2 {
+		t.Errorf("expected frames 1 and 2 to be dropped, got %v", dropped)
+	}
+	if queued := <-detector.inputChan; queued.GetFrameIndex() != 0 {
+		t.Errorf("expected frame 0 to remain queued, got %d", queued.GetFrameIndex())
+	}
+}
+
+func TestAsyncDetector_OverflowDropOldest(t *testing.T) {
+	var dropped []uint32
+	detector := New(
+		WithInputChannelSize(1),
+		WithOverflowPolicy(OverflowDropOldest),
+		WithDropHandler(func(f *telemetry.LobbySessionStateFrame) { dropped = append(dropped, f.GetFrameIndex()) }),
+	)
+	defer detector.Stop()
+	release := holdProcessLoop(detector)
+	defer release()
+
+	for i := range uint32(3) {
+		detector.ProcessFrame(indexedFrame(i))
+	}
+
+	if len(dropped) != 2 || dropped[0] != 0 || dropped[1] != 1 {
+		t.Errorf("expected frames 0 and 1 to be dropped, got %v", dropped)
+	}
+	if queued := <-detector.inputChan; queued.GetFrameIndex() != 2 {
+		t.Errorf("expected latest frame to remain queued, got %d", queued.GetFrameIndex())
+	}
+	if got := detector.Stats().FramesDropped; got != 2 {
+		t.Errorf("expected 2 dropped frames in stats, got %d", got)
+	}
+}
+
+func TestAsyncDetector_OverflowBlock(t *testing.T) {
+	detector := New(
+		WithInputChannelSize(1),
+		WithOverflowPolicy(OverflowBlock),
+		WithDropHandler(func(*telemetry.LobbySessionStateFrame) { t.Error("unexpected drop") }),
+	)
+	defer detector.Stop()
+	release := holdProcessLoop(detector)
+
+	detector.ProcessFrame(indexedFrame(0))
+
+	done := make(chan struct{})
+	go func() {
+		detector.ProcessFrame(indexedFrame(1))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("expected ProcessFrame to block while the queue is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	release()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected ProcessFrame to unblock once the queue drained")
+	}
+	if got := detector.Stats().FramesDropped; got != 0 {
+		t.Errorf("expected no dropped frames, got %d", got)
+	}
+}
+
+func TestAsyncDetector_OverflowBlockUnblocksOnStop(t *testing.T) {
+	detector := New(WithInputChannelSize(1), WithOverflowPolicy(OverflowBlock))
+	release := holdProcessLoop(detector)
+	detector.ProcessFrame(indexedFrame(0))
+
+	done := make(chan struct{})
+	go func() {
+		detector.ProcessFrame(indexedFrame(1))
+		close(done)
+	}()
+
+	detector.cancel()
+	release()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected blocked ProcessFrame to return when the detector stops")
+	}
+	detector.Stop()
+}
+
+func TestAsyncDetector_OverflowBlockWithTimeout(t *testing.T) {
+	var dropped []uint32
+	detector := New(
+		WithInputChannelSize(1),
+		WithOverflowTimeout(10*time.Millisecond),
+		WithDropHandler(func(f *telemetry.LobbySessionStateFrame) { dropped = append(dropped, f.GetFrameIndex()) }),
+	)
+	defer detector.Stop()
+	release := holdProcessLoop(detector)
+	defer release()
+
+	detector.ProcessFrame(indexedFrame(0))
+
+	start := time.Now()
+	detector.ProcessFrame(indexedFrame(1))
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Errorf("expected ProcessFrame to wait for the timeout, returned after %v", elapsed)
+	}
+	if len(dropped) != 1 || dropped[0] != 1 {
+		t.Errorf("expected frame 1 to be dropped after the timeout, got %v", dropped)
+	}
+}
@@ -0,0 +1,200 @@
+package events
+
+import (
+	"encoding/json"
+	"slices"
+
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// PlayerRegistry tracks player identity across frames so sensors can tell a
+// player who moved slots or reconnected from a new player taking over a
+// slot. A detector updates its registry once per frame before running the
+// sensors that use it.
+type PlayerRegistry struct {
+	roster  *Roster
+	changes PlayerChanges
+	seen    map[PlayerKey]struct{}
+}
+
+// PlayerChanges describes how the players of a session changed between two
+// frames. A slot changing hands yields a leave and a join.
+type PlayerChanges struct {
+	// Joined lists players that were not present in the previous frame, in
+	// session order
+	Joined []RosterPlayer
+	// Left lists players that are no longer present, in slot order
+	Left []RosterPlayer
+	// Switched lists players whose team changed
+	Switched []TeamSwitch
+}
+
+// TeamSwitch is a player whose team changed between two frames
+type TeamSwitch struct {
+	Player   RosterPlayer
+	PrevRole telemetry.Role
+}
+
+// PlayerRegistryUser is implemented by sensors that consume a shared
+// PlayerRegistry. The detector hands its registry to such sensors when they
+// are registered.
+type PlayerRegistryUser interface {
+	UsePlayerRegistry(*PlayerRegistry)
+}
+
+// NewPlayerRegistry creates an empty PlayerRegistry
+func NewPlayerRegistry() *PlayerRegistry {
+	return &PlayerRegistry{
+		roster: NewRoster(nil),
+		seen:   make(map[PlayerKey]struct{}),
+	}
+}
+
+// Reset forgets every player
+func (r *PlayerRegistry) Reset() {
+	*r = *NewPlayerRegistry()
+}
+
+// Update replaces the current roster with the players of session and
+// records the changes. A nil session leaves the registry unchanged.
+func (r *PlayerRegistry) Update(session *apigame.SessionResponse) PlayerChanges {
+	if session == nil {
+		r.changes = PlayerChanges{}
+		return r.changes
+	}
+
+	current := NewRoster(session)
+	var changes PlayerChanges
+	for _, player := range current.Players() {
+		prev, existed := r.roster.Get(player.Key)
+		switch {
+		case !existed:
+			changes.Joined = append(changes.Joined, player)
+		case prev.Role != player.Role:
+			changes.Switched = append(changes.Switched, TeamSwitch{Player: player, PrevRole: prev.Role})
+		}
+	}
+	for _, player := range playersBySlot(r.roster) {
+		if _, exists := current.Get(player.Key); !exists {
+			changes.Left = append(changes.Left, player)
+		}
+	}
+
+	for _, player := range r.roster.Players() {
+		r.seen[player.Key] = struct{}{}
+	}
+	r.roster = current
+	r.changes = changes
+	return changes
+}
+
+// Roster returns the players of the latest frame
+func (r *PlayerRegistry) Roster() *Roster {
+	return r.roster
+}
+
+// Changes returns the changes recorded by the latest Update
+func (r *PlayerRegistry) Changes() PlayerChanges {
+	return r.changes
+}
+
+// Returning reports whether a player was present in an earlier frame than
+// the latest one, e.g. a player reconnecting after leaving
+func (r *PlayerRegistry) Returning(key PlayerKey) bool {
+	_, ok := r.seen[key]
+	return ok
+}
+
+// playerRegistryState is the snapshot of a PlayerRegistry
+type playerRegistryState struct {
+	Roster json.RawMessage `json:"roster"`
+	Seen   []PlayerKey     `json:"seen"`
+}
+
+// Snapshot serializes the registry state. Changes are not included.
+func (r *PlayerRegistry) Snapshot() ([]byte, error) {
+	roster, err := snapshotRoster(r.roster)
+	if err != nil {
+		return nil, err
+	}
+	state := playerRegistryState{Roster: roster, Seen: make([]PlayerKey, 0, len(r.seen))}
+	for key := range r.seen {
+		state.Seen = append(state.Seen, key)
+	}
+	slices.SortFunc(state.Seen, comparePlayerKeys)
+	return json.Marshal(state)
+}
+
+// Restore replaces the registry state with a previous snapshot
+func (r *PlayerRegistry) Restore(data []byte) error {
+	var state playerRegistryState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	roster, err := restoreRoster(state.Roster)
+	if err != nil {
+		return err
+	}
+	r.Reset()
+	r.roster = roster
+	for _, key := range state.Seen {
+		r.seen[key] = struct{}{}
+	}
+	return nil
+}
+
+// playerTracker gives a sensor access to a PlayerRegistry. A sensor used on
+// its own owns a private registry and updates it; one registered with a
+// detector shares the detector's registry, which the detector updates.
+type playerTracker struct {
+	registry *PlayerRegistry
+	shared   bool
+}
+
+func newPlayerTracker() playerTracker {
+	return playerTracker{registry: NewPlayerRegistry()}
+}
+
+// UsePlayerRegistry makes the sensor read players from a shared registry.
+// Passing nil restores a private registry.
+func (t *playerTracker) UsePlayerRegistry(r *PlayerRegistry) {
+	if r == nil {
+		*t = newPlayerTracker()
+		return
+	}
+	t.registry = r
+	t.shared = true
+}
+
+// observe returns the registry after it has seen the frame
+func (t *playerTracker) observe(frame *telemetry.LobbySessionStateFrame) *PlayerRegistry {
+	if !t.shared {
+		t.registry.Update(frame.GetSession())
+	}
+	return t.registry
+}
+
+// reset clears a private registry. A shared registry is reset by its owner.
+func (t *playerTracker) reset() {
+	if !t.shared {
+		t.registry.Reset()
+	}
+}
+
+// snapshot serializes a private registry. Shared registries are
+// checkpointed by their owner, so nothing is recorded.
+func (t *playerTracker) snapshot() ([]byte, error) {
+	if t.shared {
+		return []byte("null"), nil
+	}
+	return t.registry.Snapshot()
+}
+
+// restore restores a private registry from a snapshot
+func (t *playerTracker) restore(data []byte) error {
+	if t.shared || string(data) == "null" {
+		return nil
+	}
+	return t.registry.Restore(data)
+}
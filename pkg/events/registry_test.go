@@ -0,0 +1,185 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+func TestPlayerRegistry_SlotHandover(t *testing.T) {
+	registry := NewPlayerRegistry()
+
+	alice := createPlayer(3, "Alice", 1)
+	bob := createPlayer(3, "Bob", 1)
+
+	registry.Update(createFrameWithPlayers(alice).GetSession())
+	changes := registry.Update(createFrameWithPlayers(bob).GetSession())
+
+	if len(changes.Left) != 1 || changes.Left[0].Key != (PlayerKey{DisplayName: "Alice"}) {
+		t.Errorf("expected Alice to leave, got %+v", changes.Left)
+	}
+	if len(changes.Joined) != 1 || changes.Joined[0].Key != (PlayerKey{DisplayName: "Bob"}) {
+		t.Errorf("expected Bob to join, got %+v", changes.Joined)
+	}
+	if len(changes.Switched) != 0 {
+		t.Errorf("expected no team switches, got %+v", changes.Switched)
+	}
+}
+
+func TestPlayerRegistry_SlotMoveIsNotAChange(t *testing.T) {
+	registry := NewPlayerRegistry()
+
+	player := &apigame.TeamMember{AccountNumber: 7, SlotNumber: 1, DisplayName: "Player"}
+	moved := &apigame.TeamMember{AccountNumber: 7, SlotNumber: 4, DisplayName: "Player"}
+
+	registry.Update(createFrameWithPlayers(player).GetSession())
+	changes := registry.Update(createFrameWithPlayers(moved).GetSession())
+
+	if len(changes.Joined)+len(changes.Left)+len(changes.Switched) != 0 {
+		t.Errorf("expected no changes, got %+v", changes)
+	}
+	if got, ok := registry.Roster().Get(KeyOf(moved)); !ok || got.Member.GetSlotNumber() != 4 {
+		t.Errorf("expected player in slot 4, got %+v", got)
+	}
+}
+
+func TestPlayerRegistry_TeamSwitch(t *testing.T) {
+	registry := NewPlayerRegistry()
+	player := createPlayer(0, "Player", 0)
+
+	registry.Update(createFrameWithTeams([]*apigame.TeamMember{player}, nil, nil).GetSession())
+	changes := registry.Update(createFrameWithTeams(nil, []*apigame.TeamMember{player}, nil).GetSession())
+
+	if len(changes.Switched) != 1 {
+		t.Fatalf("expected 1 team switch, got %+v", changes)
+	}
+	sw := changes.Switched[0]
+	if sw.PrevRole != telemetry.Role_ROLE_BLUE_TEAM || sw.Player.Role != telemetry.Role_ROLE_ORANGE_TEAM {
+		t.Errorf("expected blue to orange, got %v to %v", sw.PrevRole, sw.Player.Role)
+	}
+}
+
+func TestPlayerRegistry_Returning(t *testing.T) {
+	registry := NewPlayerRegistry()
+	player := createPlayer(0, "Player", 0)
+	key := KeyOf(player)
+
+	registry.Update(createFrameWithPlayers(player).GetSession())
+	if registry.Returning(key) {
+		t.Error("expected first appearance not to be returning")
+	}
+	registry.Update(createFrameWithPlayers().GetSession())
+	changes := registry.Update(createFrameWithPlayers(player).GetSession())
+	if len(changes.Joined) != 1 || !registry.Returning(key) {
+		t.Errorf("expected player to rejoin as returning, got %+v", changes)
+	}
+}
+
+func TestPlayerRegistry_NilSessionKeepsRoster(t *testing.T) {
+	registry := NewPlayerRegistry()
+	registry.Update(createFrameWithPlayers(createPlayer(0, "Player", 0)).GetSession())
+
+	changes := registry.Update(nil)
+	if len(changes.Left) != 0 || registry.Roster().Len() != 1 {
+		t.Errorf("expected nil session to leave the roster unchanged, got %+v", changes)
+	}
+}
+
+func TestPlayerRegistry_SnapshotRoundTrip(t *testing.T) {
+	registry := NewPlayerRegistry()
+	registry.Update(createFrameWithPlayers(createPlayer(0, "Gone", 0)).GetSession())
+	registry.Update(createFrameWithPlayers(createPlayer(1, "Here", 1)).GetSession())
+
+	data, err := registry.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	restored := NewPlayerRegistry()
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	if _, ok := restored.Roster().Get(PlayerKey{DisplayName: "Here"}); !ok {
+		t.Error("expected restored roster to contain Here")
+	}
+	if !restored.Returning(PlayerKey{DisplayName: "Gone"}) {
+		t.Error("expected restored registry to remember Gone")
+	}
+	changes := restored.Update(createFrameWithPlayers(createPlayer(1, "Here", 1)).GetSession())
+	if len(changes.Joined)+len(changes.Left) != 0 {
+		t.Errorf("expected no changes after restore, got %+v", changes)
+	}
+}
+
+func TestPlayerRegistry_SharedWithDetectorSensors(t *testing.T) {
+	join := NewPlayerJoinSensor()
+	leave := NewPlayerLeaveSensor()
+	detector := New(WithSynchronousProcessing(), WithEventSensors(join, leave))
+	defer detector.Stop()
+
+	if join.registry != detector.players || leave.registry != detector.players {
+		t.Fatal("expected sensors to share the detector's registry")
+	}
+
+	detector.Detect(createFrameWithPlayers(createPlayer(3, "Alice", 1)))
+	events, _ := detector.Detect(createFrameWithPlayers(createPlayer(3, "Bob", 1)))
+
+	if len(events) != 2 {
+		t.Fatalf("expected a join and a leave, got %v", events)
+	}
+	if events[0].GetPlayerJoined().GetPlayer().GetDisplayName() != "Bob" {
+		t.Errorf("expected Bob to join, got %v", events[0])
+	}
+	if events[1].GetPlayerLeft().GetDisplayName() != "Alice" {
+		t.Errorf("expected Alice to leave, got %v", events[1])
+	}
+}
+
+func TestPlayerRegistry_LateRegistrationSeesExistingPlayers(t *testing.T) {
+	detector := New(WithSynchronousProcessing(), WithEventSensors(NewPlayerLeaveSensor()))
+	defer detector.Stop()
+
+	frame := createFrameWithPlayers(createPlayer(0, "Player", 0))
+	detector.Detect(frame)
+
+	detector.RegisterSensors(NewPlayerJoinSensor())
+	if events, _ := detector.Detect(frame); len(events) != 0 {
+		t.Errorf("expected no join for a player already present, got %v", events)
+	}
+}
+
+func TestPlayerRegistry_LateRegistrationWithoutRegistryUsers(t *testing.T) {
+	detector := New(WithSynchronousProcessing())
+	defer detector.Stop()
+
+	frame := createFrameWithPlayers(createPlayer(0, "Player", 0))
+	detector.Detect(frame)
+
+	join := NewPlayerJoinSensor()
+	detector.RegisterSensors(join)
+	if events, _ := detector.Detect(frame); len(events) != 0 {
+		t.Errorf("expected no join for a player already present, got %v", events)
+	}
+
+	events, _ := detector.Detect(createFrameWithPlayers(createPlayer(0, "Player", 0), createPlayer(1, "Newcomer", 0)))
+	if len(events) != 1 || events[0].GetPlayerJoined().GetPlayer().GetDisplayName() != "Newcomer" {
+		t.Errorf("expected Newcomer to join, got %v", events)
+	}
+}
+
+func TestPlayerTracker_SharedRegistrySurvivesReset(t *testing.T) {
+	registry := NewPlayerRegistry()
+	sensor := NewPlayerJoinSensor()
+	sensor.UsePlayerRegistry(registry)
+
+	sensor.Reset()
+	if sensor.registry != registry {
+		t.Error("expected Reset to keep the shared registry")
+	}
+
+	sensor.UsePlayerRegistry(nil)
+	if sensor.registry == registry || sensor.shared {
+		t.Error("expected nil to restore a private registry")
+	}
+}
@@ -0,0 +1,187 @@
+package events
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// TeamRole returns the role of the players listed in a SessionResponse.Teams
+// entry. Recognized team names take precedence; otherwise the game's order
+// applies: blue, orange, spectators, moderators.
+func TeamRole(index int, team *apigame.Team) telemetry.Role {
+	name := strings.ToUpper(team.GetTeamName())
+	switch {
+	case strings.Contains(name, "BLUE"):
+		return telemetry.Role_ROLE_BLUE_TEAM
+	case strings.Contains(name, "ORANGE"):
+		return telemetry.Role_ROLE_ORANGE_TEAM
+	case strings.Contains(name, "SPECTATOR"):
+		return telemetry.Role_ROLE_SPECTATOR
+	case strings.Contains(name, "MODERATOR"):
+		return telemetry.Role_ROLE_MODERATOR
+	}
+
+	switch index {
+	case 0:
+		return telemetry.Role_ROLE_BLUE_TEAM
+	case 1:
+		return telemetry.Role_ROLE_ORANGE_TEAM
+	case 2:
+		return telemetry.Role_ROLE_SPECTATOR
+	case 3:
+		return telemetry.Role_ROLE_MODERATOR
+	}
+	return telemetry.Role_ROLE_UNSPECIFIED
+}
+
+// PlayerKey identifies a player across frames. Players are keyed by account
+// number (the API's userid), which survives slot reuse; players without one
+// fall back to their display name, then to their slot.
+type PlayerKey struct {
+	AccountNumber uint64 `json:"account_number,omitempty"`
+	DisplayName   string `json:"display_name,omitempty"`
+	SlotNumber    int32  `json:"slot_number,omitempty"`
+}
+
+// KeyOf returns the canonical identity of a player
+func KeyOf(player *apigame.TeamMember) PlayerKey {
+	if id := player.GetAccountNumber(); id != 0 {
+		return PlayerKey{AccountNumber: id}
+	}
+	if name := player.GetDisplayName(); name != "" {
+		return PlayerKey{DisplayName: name}
+	}
+	return PlayerKey{SlotNumber: player.GetSlotNumber()}
+}
+
+// RosterPlayer is a player of a Roster with the role of their team
+type RosterPlayer struct {
+	Key    PlayerKey
+	Member *apigame.TeamMember
+	Role   telemetry.Role
+}
+
+// Roster indexes the players of a session by identity
+type Roster struct {
+	players []RosterPlayer // session order
+	byKey   map[PlayerKey]int
+}
+
+// NewRoster builds the roster of a session. A nil session yields an empty
+// roster.
+func NewRoster(session *apigame.SessionResponse) *Roster {
+	r := &Roster{byKey: make(map[PlayerKey]int)}
+	for i, team := range session.GetTeams() {
+		role := TeamRole(i, team)
+		for _, player := range team.GetPlayers() {
+			r.add(RosterPlayer{Key: KeyOf(player), Member: player, Role: role})
+		}
+	}
+	return r
+}
+
+func (r *Roster) add(p RosterPlayer) {
+	if i, ok := r.byKey[p.Key]; ok {
+		// The game briefly lists a player twice while they switch teams;
+		// the later team wins
+		r.players[i] = p
+		return
+	}
+	r.byKey[p.Key] = len(r.players)
+	r.players = append(r.players, p)
+}
+
+// Players returns the players in session order
+func (r *Roster) Players() []RosterPlayer {
+	if r == nil {
+		return nil
+	}
+	return r.players
+}
+
+// Len returns the number of players
+func (r *Roster) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.players)
+}
+
+// Get returns the player with the given identity
+func (r *Roster) Get(key PlayerKey) (RosterPlayer, bool) {
+	if r == nil {
+		return RosterPlayer{}, false
+	}
+	i, ok := r.byKey[key]
+	if !ok {
+		return RosterPlayer{}, false
+	}
+	return r.players[i], true
+}
+
+// BySlot returns the player currently occupying a slot
+func (r *Roster) BySlot(slot int32) (RosterPlayer, bool) {
+	for _, p := range r.Players() {
+		if p.Member.GetSlotNumber() == slot {
+			return p, true
+		}
+	}
+	return RosterPlayer{}, false
+}
+
+// rosterState is the snapshot of a Roster
+type rosterState struct {
+	Players []rosterPlayerState `json:"players"`
+}
+
+type rosterPlayerState struct {
+	Player json.RawMessage `json:"player"`
+	Role   telemetry.Role  `json:"role"`
+}
+
+func snapshotRosterPlayer(p RosterPlayer) (rosterPlayerState, error) {
+	raw, err := marshalProto(p.Member)
+	if err != nil {
+		return rosterPlayerState{}, err
+	}
+	return rosterPlayerState{Player: raw, Role: p.Role}, nil
+}
+
+func restoreRosterPlayer(state rosterPlayerState) (RosterPlayer, error) {
+	player := &apigame.TeamMember{}
+	if _, err := unmarshalProto(state.Player, player); err != nil {
+		return RosterPlayer{}, err
+	}
+	return RosterPlayer{Key: KeyOf(player), Member: player, Role: state.Role}, nil
+}
+
+func snapshotRoster(r *Roster) ([]byte, error) {
+	state := rosterState{Players: make([]rosterPlayerState, 0, r.Len())}
+	for _, p := range r.Players() {
+		ps, err := snapshotRosterPlayer(p)
+		if err != nil {
+			return nil, err
+		}
+		state.Players = append(state.Players, ps)
+	}
+	return json.Marshal(state)
+}
+
+func restoreRoster(data []byte) (*Roster, error) {
+	var state rosterState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	r := NewRoster(nil)
+	for _, ps := range state.Players {
+		player, err := restoreRosterPlayer(ps)
+		if err != nil {
+			return nil, err
+		}
+		r.add(player)
+	}
+	return r, nil
+}
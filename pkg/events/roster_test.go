@@ -0,0 +1,127 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+func TestTeamRole(t *testing.T) {
+	tests := []struct {
+		index int
+		name  string
+		want  telemetry.Role
+	}{
+		{0, "", telemetry.Role_ROLE_BLUE_TEAM},
+		{1, "", telemetry.Role_ROLE_ORANGE_TEAM},
+		{2, "", telemetry.Role_ROLE_SPECTATOR},
+		{3, "", telemetry.Role_ROLE_MODERATOR},
+		{4, "", telemetry.Role_ROLE_UNSPECIFIED},
+		// Team names take precedence over position
+		{0, "ORANGE TEAM", telemetry.Role_ROLE_ORANGE_TEAM},
+		{1, "BLUE TEAM", telemetry.Role_ROLE_BLUE_TEAM},
+		{3, "SPECTATORS", telemetry.Role_ROLE_SPECTATOR},
+		{2, "Moderators", telemetry.Role_ROLE_MODERATOR},
+	}
+	for _, tt := range tests {
+		if got := TeamRole(tt.index, &apigame.Team{TeamName: tt.name}); got != tt.want {
+			t.Errorf("TeamRole(%d, %q) = %v, want %v", tt.index, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestKeyOf(t *testing.T) {
+	if got := KeyOf(&apigame.TeamMember{AccountNumber: 42, SlotNumber: 3}); got != (PlayerKey{AccountNumber: 42}) {
+		t.Errorf("expected account key, got %+v", got)
+	}
+	if got := KeyOf(&apigame.TeamMember{SlotNumber: 3, DisplayName: "Name"}); got != (PlayerKey{DisplayName: "Name"}) {
+		t.Errorf("expected display name key, got %+v", got)
+	}
+	if got := KeyOf(&apigame.TeamMember{SlotNumber: 3}); got != (PlayerKey{SlotNumber: 3}) {
+		t.Errorf("expected slot fallback key, got %+v", got)
+	}
+}
+
+func TestNewRoster(t *testing.T) {
+	roster := NewRoster(&apigame.SessionResponse{
+		Teams: []*apigame.Team{
+			{Players: []*apigame.TeamMember{{SlotNumber: 5, AccountNumber: 100, DisplayName: "Blue"}}},
+			{Players: []*apigame.TeamMember{{SlotNumber: 1, AccountNumber: 200, DisplayName: "Orange"}}},
+			{Players: []*apigame.TeamMember{{SlotNumber: 9, DisplayName: "Spectator"}}},
+		},
+	})
+
+	if roster.Len() != 3 {
+		t.Fatalf("expected 3 players, got %d", roster.Len())
+	}
+	blue, ok := roster.Get(PlayerKey{AccountNumber: 100})
+	if !ok || blue.Role != telemetry.Role_ROLE_BLUE_TEAM {
+		t.Errorf("expected blue player in slot 5, got %+v", blue)
+	}
+	orange, ok := roster.BySlot(1)
+	if !ok || orange.Role != telemetry.Role_ROLE_ORANGE_TEAM || orange.Member.GetDisplayName() != "Orange" {
+		t.Errorf("expected orange player in slot 1, got %+v", orange)
+	}
+	if _, ok := roster.Get(PlayerKey{DisplayName: "Spectator"}); !ok {
+		t.Error("expected spectator keyed by display name")
+	}
+	if got := roster.Players()[2].Role; got != telemetry.Role_ROLE_SPECTATOR {
+		t.Errorf("expected spectator role, got %v", got)
+	}
+}
+
+func TestNewRoster_DuplicateListingKeepsLaterTeam(t *testing.T) {
+	player := &apigame.TeamMember{AccountNumber: 7}
+	roster := NewRoster(&apigame.SessionResponse{
+		Teams: []*apigame.Team{
+			{Players: []*apigame.TeamMember{player}},
+			{Players: []*apigame.TeamMember{player}},
+		},
+	})
+
+	if roster.Len() != 1 {
+		t.Fatalf("expected 1 player, got %d", roster.Len())
+	}
+	if got := roster.Players()[0].Role; got != telemetry.Role_ROLE_ORANGE_TEAM {
+		t.Errorf("expected later team to win, got %v", got)
+	}
+}
+
+func TestNilRoster(t *testing.T) {
+	var roster *Roster
+	if roster.Len() != 0 || roster.Players() != nil {
+		t.Error("expected nil roster to be empty")
+	}
+	if _, ok := roster.Get(PlayerKey{}); ok {
+		t.Error("expected no player in nil roster")
+	}
+}
+
+func TestRosterSnapshotRoundTrip(t *testing.T) {
+	roster := NewRoster(createFrameWithTeams(
+		[]*apigame.TeamMember{{SlotNumber: 0, AccountNumber: 1, DisplayName: "A"}},
+		[]*apigame.TeamMember{{SlotNumber: 4, DisplayName: "B"}},
+		nil,
+	).GetSession())
+
+	data, err := snapshotRoster(roster)
+	if err != nil {
+		t.Fatalf("snapshotRoster failed: %v", err)
+	}
+	if !json.Valid(data) {
+		t.Fatalf("invalid JSON %s", data)
+	}
+	restored, err := restoreRoster(data)
+	if err != nil {
+		t.Fatalf("restoreRoster failed: %v", err)
+	}
+	if restored.Len() != 2 {
+		t.Fatalf("expected 2 players, got %d", restored.Len())
+	}
+	b, ok := restored.Get(PlayerKey{DisplayName: "B"})
+	if !ok || b.Role != telemetry.Role_ROLE_ORANGE_TEAM || b.Member.GetDisplayName() != "B" {
+		t.Errorf("unexpected restored player %+v", b)
+	}
+}
@@ -0,0 +1,419 @@
+package events
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ruleExpr is a compiled rule expression. Evaluation reports false when the
+// expression cannot be evaluated, e.g. a field is missing or the operand
+// types do not match, in which case the rule does not fire.
+type ruleExpr interface {
+	eval(env *ruleEnv) (any, bool)
+}
+
+// compileRuleExpr parses an expression such as
+// "blue_points - orange_points >= 6 && game_status == 'playing'"
+//
+// Operands are numbers, quoted strings, true, false and dotted field paths.
+// The operators are, by increasing precedence: ||, &&, == and !=, < <= > >=,
+// + and -, * and /, and the unary ! and -. abs(x), min(a, b) and max(a, b)
+// are available.
+func compileRuleExpr(src string) (ruleExpr, error) {
+	tokens, err := lexRuleExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &ruleParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at offset %d", tok.text, tok.pos)
+	}
+	return expr, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOp
+)
+
+type ruleToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// ruleOperators lists the operators, two-character ones first
+var ruleOperators = []string{"||", "&&", "==", "!=", "<=", ">=", "<", ">", "+", "-", "*", "/", "!", "(", ")", ","}
+
+func lexRuleExpr(src string) ([]ruleToken, error) {
+	var tokens []ruleToken
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c >= '0' && c <= '9' || c == '.':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, ruleToken{tokenNumber, src[i:j], i})
+			i = j
+		case c == '\'' || c == '"':
+			j := strings.IndexByte(src[i+1:], src[i])
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			tokens = append(tokens, ruleToken{tokenString, src[i+1 : i+1+j], i})
+			i += j + 2
+		case c == '_' || unicode.IsLetter(c):
+			j := i
+			for j < len(src) && (src[j] == '_' || src[j] == '.' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			tokens = append(tokens, ruleToken{tokenIdent, src[i:j], i})
+			i = j
+		default:
+			op := ""
+			for _, candidate := range ruleOperators {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q at offset %d", c, i)
+			}
+			tokens = append(tokens, ruleToken{tokenOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(tokens, ruleToken{kind: tokenEOF, pos: len(src)}), nil
+}
+
+// ruleParser is a recursive descent parser over the tokens of an expression
+type ruleParser struct {
+	tokens []ruleToken
+	pos    int
+}
+
+func (p *ruleParser) peek() ruleToken {
+	return p.tokens[p.pos]
+}
+
+func (p *ruleParser) next() ruleToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it is one of the given operators
+func (p *ruleParser) accept(ops ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind == tokenOp {
+		for _, op := range ops {
+			if tok.text == op {
+				p.pos++
+				return op, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (p *ruleParser) expect(op string) error {
+	if _, ok := p.accept(op); !ok {
+		tok := p.peek()
+		return fmt.Errorf("expected %q at offset %d", op, tok.pos)
+	}
+	return nil
+}
+
+// parseBinary parses a left-associative chain of the given operators
+func (p *ruleParser) parseBinary(operand func() (ruleExpr, error), ops ...string) (ruleExpr, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(ops...)
+		if !ok {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *ruleParser) parseOr() (ruleExpr, error) {
+	return p.parseBinary(p.parseAnd, "||")
+}
+
+func (p *ruleParser) parseAnd() (ruleExpr, error) {
+	return p.parseBinary(p.parseEquality, "&&")
+}
+
+func (p *ruleParser) parseEquality() (ruleExpr, error) {
+	return p.parseBinary(p.parseComparison, "==", "!=")
+}
+
+func (p *ruleParser) parseComparison() (ruleExpr, error) {
+	return p.parseBinary(p.parseAdditive, "<=", ">=", "<", ">")
+}
+
+func (p *ruleParser) parseAdditive() (ruleExpr, error) {
+	return p.parseBinary(p.parseMultiplicative, "+", "-")
+}
+
+func (p *ruleParser) parseMultiplicative() (ruleExpr, error) {
+	return p.parseBinary(p.parseUnary, "*", "/")
+}
+
+func (p *ruleParser) parseUnary() (ruleExpr, error) {
+	if op, ok := p.accept("!", "-"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return unaryExpr{op: op, operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *ruleParser) parsePrimary() (ruleExpr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenNumber:
+		v, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at offset %d", tok.text, tok.pos)
+		}
+		return literalExpr{v}, nil
+	case tokenString:
+		return literalExpr{tok.text}, nil
+	case tokenIdent:
+		switch tok.text {
+		case "true":
+			return literalExpr{true}, nil
+		case "false":
+			return literalExpr{false}, nil
+		}
+		if _, ok := p.accept("("); ok {
+			return p.parseCall(tok)
+		}
+		return pathExpr(strings.Split(tok.text, ".")), nil
+	case tokenOp:
+		if tok.text == "(" {
+			expr, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return expr, p.expect(")")
+		}
+	}
+	if tok.kind == tokenEOF {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", tok.text, tok.pos)
+}
+
+// ruleFunctions are the functions available to expressions by arity
+var ruleFunctions = map[string]struct {
+	arity int
+	fn    func(args ...float64) float64
+}{
+	"abs": {1, func(args ...float64) float64 { return math.Abs(args[0]) }},
+	"min": {2, func(args ...float64) float64 { return min(args[0], args[1]) }},
+	"max": {2, func(args ...float64) float64 { return max(args[0], args[1]) }},
+}
+
+func (p *ruleParser) parseCall(name ruleToken) (ruleExpr, error) {
+	fn, ok := ruleFunctions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at offset %d", name.text, name.pos)
+	}
+	var args []ruleExpr
+	if _, ok := p.accept(")"); !ok {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if _, ok := p.accept(","); !ok {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+	if len(args) != fn.arity {
+		return nil, fmt.Errorf("%s takes %d arguments, got %d", name.text, fn.arity, len(args))
+	}
+	return callExpr{fn: fn.fn, args: args}, nil
+}
+
+type literalExpr struct {
+	value any
+}
+
+func (e literalExpr) eval(*ruleEnv) (any, bool) {
+	return e.value, true
+}
+
+// pathExpr is a dotted field path resolved against the rule environment
+type pathExpr []string
+
+func (e pathExpr) eval(env *ruleEnv) (any, bool) {
+	return env.resolve(e)
+}
+
+type unaryExpr struct {
+	op      string
+	operand ruleExpr
+}
+
+func (e unaryExpr) eval(env *ruleEnv) (any, bool) {
+	v, ok := e.operand.eval(env)
+	if !ok {
+		return nil, false
+	}
+	switch v := v.(type) {
+	case bool:
+		return !v, e.op == "!"
+	case float64:
+		return -v, e.op == "-"
+	}
+	return nil, false
+}
+
+type binaryExpr struct {
+	op          string
+	left, right ruleExpr
+}
+
+func (e binaryExpr) eval(env *ruleEnv) (any, bool) {
+	left, ok := e.left.eval(env)
+	if !ok {
+		return nil, false
+	}
+	// Logical operators short-circuit
+	if e.op == "&&" || e.op == "||" {
+		l, ok := left.(bool)
+		if !ok || l == (e.op == "||") {
+			return l, ok
+		}
+		right, ok := e.right.eval(env)
+		r, isBool := right.(bool)
+		return r, ok && isBool
+	}
+	right, ok := e.right.eval(env)
+	if !ok {
+		return nil, false
+	}
+
+	switch e.op {
+	case "==", "!=":
+		if !sameRuleType(left, right) {
+			return nil, false
+		}
+		return (left == right) == (e.op == "=="), true
+	}
+	if l, ok := left.(string); ok {
+		r, ok := right.(string)
+		if !ok {
+			return nil, false
+		}
+		return compareRuleValues(e.op, strings.Compare(l, r))
+	}
+	l, lok := left.(float64)
+	r, rok := right.(float64)
+	if !lok || !rok {
+		return nil, false
+	}
+	switch e.op {
+	case "+":
+		return l + r, true
+	case "-":
+		return l - r, true
+	case "*":
+		return l * r, true
+	case "/":
+		if r == 0 {
+			return nil, false
+		}
+		return l / r, true
+	}
+	switch {
+	case l < r:
+		return compareRuleValues(e.op, -1)
+	case l > r:
+		return compareRuleValues(e.op, 1)
+	}
+	return compareRuleValues(e.op, 0)
+}
+
+func sameRuleType(a, b any) bool {
+	switch a.(type) {
+	case float64:
+		_, ok := b.(float64)
+		return ok
+	case string:
+		_, ok := b.(string)
+		return ok
+	case bool:
+		_, ok := b.(bool)
+		return ok
+	}
+	return false
+}
+
+// compareRuleValues applies an ordering operator to the result of a
+// three-way comparison
+func compareRuleValues(op string, c int) (any, bool) {
+	switch op {
+	case "<":
+		return c < 0, true
+	case "<=":
+		return c <= 0, true
+	case ">":
+		return c > 0, true
+	case ">=":
+		return c >= 0, true
+	}
+	return nil, false
+}
+
+type callExpr struct {
+	fn   func(args ...float64) float64
+	args []ruleExpr
+}
+
+func (e callExpr) eval(env *ruleEnv) (any, bool) {
+	args := make([]float64, len(e.args))
+	for i, arg := range e.args {
+		v, ok := arg.eval(env)
+		if args[i], ok = v.(float64); !ok {
+			return nil, false
+		}
+	}
+	return e.fn(args...), true
+}
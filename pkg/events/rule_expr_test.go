@@ -0,0 +1,96 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+func TestCompileRuleExpr(t *testing.T) {
+	frame := createFrameWithTeams(
+		[]*apigame.TeamMember{{SlotNumber: 0, DisplayName: "Blue", Ping: 45}},
+		nil, nil)
+	frame.Session.BluePoints = 9
+	frame.Session.OrangePoints = 2
+	frame.Session.GameStatus = GameStatusPlaying
+	env := &ruleEnv{
+		frame: frame,
+		event: &ruleEvent{name: "pass", fields: map[string]any{
+			"distance":   31.5,
+			"thrown":     true,
+			"slot":       int32(4),
+			"time_above": map[string]float64{"5": 2.5},
+		}},
+	}
+
+	tests := []struct {
+		expr string
+		want any
+		ok   bool
+	}{
+		{"1 + 2 * 3", 7.0, true},
+		{"(1 + 2) * 3", 9.0, true},
+		{"-2 - -3", 1.0, true},
+		{"blue_points - orange_points >= 6", true, true},
+		{"session.blue_points - session.orange_points", 7.0, true},
+		{"abs(orange_points - blue_points)", 7.0, true},
+		{"max(1, min(5, 3))", 3.0, true},
+		{"game_status == 'playing' && !false", true, true},
+		{`game_status != "score"`, true, true},
+		{"teams.0.players.0.ping < 50", true, true},
+		{"teams.0.players.0.display_name", "Blue", true},
+		{"event.distance > 30 && event.thrown", true, true},
+		{"event.slot == 4", true, true},
+		{"event.time_above.5", 2.5, true},
+		{"false && event.missing", false, true},
+		{"true || event.missing", true, true},
+		{"'a' < 'b'", true, true},
+
+		{"event.missing > 1", nil, false},
+		{"teams.7.players.0.ping", nil, false},
+		{"teams.0", nil, false},
+		{"blue_points == 'nine'", nil, false},
+		{"1 / 0", nil, false},
+		{"!1", nil, false},
+	}
+	for _, tt := range tests {
+		expr, err := compileRuleExpr(tt.expr)
+		if err != nil {
+			t.Errorf("compileRuleExpr(%q): %v", tt.expr, err)
+			continue
+		}
+		got, ok := expr.eval(env)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("%q = %v, %v; want %v, %v", tt.expr, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCompileRuleExpr_Errors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"1 +",
+		"(1 + 2",
+		"'unterminated",
+		"a = b",
+		"unknown(1)",
+		"abs(1, 2)",
+		"1 2",
+	} {
+		if _, err := compileRuleExpr(expr); err == nil {
+			t.Errorf("compileRuleExpr(%q): expected an error", expr)
+		}
+	}
+}
+
+// Rules also see frames without a session
+func TestRuleEnv_NoSession(t *testing.T) {
+	expr, err := compileRuleExpr("blue_points > 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := expr.eval(&ruleEnv{frame: &telemetry.LobbySessionStateFrame{}}); ok {
+		t.Error("expected no value without a session")
+	}
+}
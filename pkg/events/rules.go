@@ -0,0 +1,378 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"go.yaml.in/yaml/v3"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Rule declares a custom event in terms of frame fields and other events,
+// so simple detectors need no Go code
+type Rule struct {
+	// Name is the name of the custom event the rule emits
+	Name string `json:"name"`
+	// On is the event that triggers the rule: a telemetry event by its
+	// field name in LobbySessionEvent, e.g. "disc_thrown", or a custom event
+	// by name, e.g. "pass". Without On the rule is evaluated once per frame
+	// and fires when When becomes true.
+	On string `json:"on,omitempty"`
+	// When is the condition for the rule to fire, e.g.
+	// "event.throw_details.total_speed > 20". An empty condition always
+	// holds.
+	When string `json:"when,omitempty"`
+	// Fields maps the emitted event's fields to expressions giving their
+	// values
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// ruleDocument is the layout of a rules file
+type ruleDocument struct {
+	Rules []Rule `json:"rules"`
+}
+
+// ParseRules parses a rules document of the form {"rules": [...]}. decode
+// turns the document into generic values and defaults to json.Unmarshal.
+// Use ParseRulesYAML for YAML rules files.
+func ParseRules(data []byte, decode func(data []byte, v any) error) ([]Rule, error) {
+	if decode == nil {
+		decode = json.Unmarshal
+	}
+	var generic any
+	if err := decode(data, &generic); err != nil {
+		return nil, fmt.Errorf("decode rules: %w", err)
+	}
+	// Round-trip through JSON so every decoder maps onto the same layout
+	normalized, err := json.Marshal(generic)
+	if err != nil {
+		return nil, fmt.Errorf("decode rules: %w", err)
+	}
+	var doc ruleDocument
+	if err := json.Unmarshal(normalized, &doc); err != nil {
+		return nil, fmt.Errorf("decode rules: %w", err)
+	}
+	return doc.Rules, nil
+}
+
+// ParseRulesYAML parses a YAML rules document with the same layout as
+// ParseRules
+func ParseRulesYAML(data []byte) ([]Rule, error) {
+	return ParseRules(data, yaml.Unmarshal)
+}
+
+// RuleEngine evaluates rules against every frame and the events detected
+// for it. Use WithRules to run it in a detector.
+type RuleEngine struct {
+	rules []compiledRule
+}
+
+type compiledRule struct {
+	name   string
+	on     string
+	when   ruleExpr
+	fields []compiledField
+	// active is whether a frame rule's condition held on the last frame
+	active bool
+}
+
+type compiledField struct {
+	name string
+	expr ruleExpr
+}
+
+// NewRuleEngine compiles rules, reporting every invalid rule
+func NewRuleEngine(rules ...Rule) (*RuleEngine, error) {
+	engine := &RuleEngine{}
+	var errs []error
+	for i, rule := range rules {
+		compiled, err := compileRule(rule)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %d (%s): %w", i, rule.Name, err))
+			continue
+		}
+		engine.rules = append(engine.rules, compiled)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return engine, nil
+}
+
+func compileRule(rule Rule) (compiledRule, error) {
+	if rule.Name == "" {
+		return compiledRule{}, errors.New("missing name")
+	}
+	compiled := compiledRule{name: rule.Name, on: rule.On, when: literalExpr{true}}
+	if rule.When != "" {
+		when, err := compileRuleExpr(rule.When)
+		if err != nil {
+			return compiledRule{}, fmt.Errorf("when: %w", err)
+		}
+		compiled.when = when
+	}
+	for _, name := range slices.Sorted(maps.Keys(rule.Fields)) {
+		expr, err := compileRuleExpr(rule.Fields[name])
+		if err != nil {
+			return compiledRule{}, fmt.Errorf("field %s: %w", name, err)
+		}
+		compiled.fields = append(compiled.fields, compiledField{name: name, expr: expr})
+	}
+	return compiled, nil
+}
+
+// Reset clears the state of rules without a triggering event
+func (r *RuleEngine) Reset() {
+	for i := range r.rules {
+		r.rules[i].active = false
+	}
+}
+
+// ruleEngineState is the snapshot of a RuleEngine
+type ruleEngineState struct {
+	// Active holds, for every rule, whether its condition held on the last
+	// frame
+	Active []bool `json:"active"`
+}
+
+// Snapshot serializes the state of rules without a triggering event
+func (r *RuleEngine) Snapshot() ([]byte, error) {
+	state := ruleEngineState{Active: make([]bool, len(r.rules))}
+	for i, rule := range r.rules {
+		state.Active[i] = rule.active
+	}
+	return json.Marshal(state)
+}
+
+// Restore replaces the rule state with a snapshot taken from an engine with
+// the same rules
+func (r *RuleEngine) Restore(data []byte) error {
+	var state ruleEngineState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	if len(state.Active) != len(r.rules) {
+		return fmt.Errorf("snapshot has %d rules, engine has %d", len(state.Active), len(r.rules))
+	}
+	for i, active := range state.Active {
+		r.rules[i].active = active
+	}
+	return nil
+}
+
+// Evaluate appends the custom events of the rules that fire for a frame,
+// given the telemetry and custom events detected for it
+func (r *RuleEngine) Evaluate(frame *telemetry.LobbySessionStateFrame, events []*telemetry.LobbySessionEvent, custom []*CustomEvent, dst []*CustomEvent) []*CustomEvent {
+	if frame == nil {
+		return dst
+	}
+	env := &ruleEnv{frame: frame}
+
+	// Name the frame's events once for all the rules
+	var named []ruleEvent
+	for _, ev := range events {
+		m := ev.ProtoReflect()
+		oneof := m.Descriptor().Oneofs().ByName("event")
+		if fd := m.WhichOneof(oneof); fd != nil {
+			named = append(named, ruleEvent{name: string(fd.Name()), message: m.Get(fd).Message()})
+		}
+	}
+	for _, ev := range custom {
+		named = append(named, ruleEvent{name: ev.Name, fields: ev.Fields})
+	}
+
+	for i := range r.rules {
+		rule := &r.rules[i]
+		if rule.on == "" {
+			env.event = nil
+			holds := rule.holds(env)
+			if holds && !rule.active {
+				dst = rule.emit(env, dst)
+			}
+			rule.active = holds
+			continue
+		}
+		for j := range named {
+			if named[j].name != rule.on {
+				continue
+			}
+			env.event = &named[j]
+			if rule.holds(env) {
+				dst = rule.emit(env, dst)
+			}
+		}
+	}
+	return dst
+}
+
+func (r *compiledRule) holds(env *ruleEnv) bool {
+	v, ok := r.when.eval(env)
+	return ok && v == true
+}
+
+// emit appends the rule's event. Fields that cannot be evaluated are left
+// out.
+func (r *compiledRule) emit(env *ruleEnv, dst []*CustomEvent) []*CustomEvent {
+	fields := make(map[string]any, len(r.fields))
+	for _, f := range r.fields {
+		if v, ok := f.expr.eval(env); ok {
+			fields[f.name] = v
+		}
+	}
+	return append(dst, &CustomEvent{Name: r.name, Fields: fields})
+}
+
+// ruleEvent is an event a rule can be triggered by. Telemetry events carry
+// their payload message, custom events their fields.
+type ruleEvent struct {
+	name    string
+	message protoreflect.Message
+	fields  map[string]any
+}
+
+// ruleEnv is what rule expressions are evaluated against
+type ruleEnv struct {
+	frame *telemetry.LobbySessionStateFrame
+	event *ruleEvent
+}
+
+// resolve looks up a field path. Paths starting with "event" refer to the
+// triggering event; others to the frame, then to its session, so both
+// "session.blue_points" and "blue_points" work. Numeric path elements index
+// lists.
+func (env *ruleEnv) resolve(path []string) (any, bool) {
+	if path[0] == "event" {
+		if env.event == nil {
+			return nil, false
+		}
+		if env.event.message != nil {
+			return resolveMessage(env.event.message, path[1:])
+		}
+		return resolveValue(reflect.ValueOf(env.event.fields), path[1:])
+	}
+	frame := env.frame.ProtoReflect()
+	if frame.Descriptor().Fields().ByName(protoreflect.Name(path[0])) != nil {
+		return resolveMessage(frame, path)
+	}
+	if session := env.frame.GetSession(); session != nil {
+		return resolveMessage(session.ProtoReflect(), path)
+	}
+	return nil, false
+}
+
+// resolveMessage resolves a path of proto field names, or their JSON
+// names, to a scalar
+func resolveMessage(m protoreflect.Message, path []string) (any, bool) {
+	for len(path) > 0 {
+		fields := m.Descriptor().Fields()
+		fd := fields.ByName(protoreflect.Name(path[0]))
+		if fd == nil {
+			fd = fields.ByJSONName(path[0])
+		}
+		if fd == nil || fd.IsMap() {
+			return nil, false
+		}
+		path = path[1:]
+		v := m.Get(fd)
+		if fd.IsList() {
+			if len(path) == 0 {
+				return nil, false
+			}
+			i, err := strconv.Atoi(path[0])
+			list := v.List()
+			if err != nil || i < 0 || i >= list.Len() {
+				return nil, false
+			}
+			path = path[1:]
+			v = list.Get(i)
+		} else if fd.Message() != nil && !m.Has(fd) {
+			return nil, false
+		}
+		if fd.Message() != nil {
+			m = v.Message()
+			continue
+		}
+		if len(path) > 0 {
+			return nil, false
+		}
+		return protoScalar(fd, v)
+	}
+	return nil, false
+}
+
+// protoScalar converts a scalar field value to an expression value. Enums
+// are their value names.
+func protoScalar(fd protoreflect.FieldDescriptor, v protoreflect.Value) (any, bool) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return v.Bool(), true
+	case protoreflect.StringKind:
+		return v.String(), true
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name()), true
+		}
+		return float64(v.Enum()), true
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return float64(v.Int()), true
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return float64(v.Uint()), true
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return v.Float(), true
+	}
+	return nil, false
+}
+
+// resolveValue resolves a path through the maps and slices of custom event
+// fields to a scalar
+func resolveValue(v reflect.Value, path []string) (any, bool) {
+	for ; len(path) > 0; path = path[1:] {
+		for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return nil, false
+			}
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return nil, false
+			}
+			v = v.MapIndex(reflect.ValueOf(path[0]).Convert(v.Type().Key()))
+			if !v.IsValid() {
+				return nil, false
+			}
+		case reflect.Slice, reflect.Array:
+			i, err := strconv.Atoi(path[0])
+			if err != nil || i < 0 || i >= v.Len() {
+				return nil, false
+			}
+			v = v.Index(i)
+		default:
+			return nil, false
+		}
+	}
+	for v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool(), true
+	case reflect.String:
+		return v.String(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return nil, false
+}
@@ -0,0 +1,187 @@
+package events
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+const testRules = `{
+	"rules": [
+		{
+			"name": "LongShot",
+			"on": "disc_thrown",
+			"when": "event.throw_details.total_speed > 20",
+			"fields": {"slot": "event.player_slot", "speed": "event.throw_details.total_speed"}
+		},
+		{
+			"name": "Blowout",
+			"when": "abs(blue_points - orange_points) >= 6",
+			"fields": {"blue": "blue_points", "orange": "orange_points"}
+		},
+		{
+			"name": "LongPass",
+			"on": "pass",
+			"when": "event.distance > 30"
+		}
+	]
+}`
+
+func discThrownEvent(slot int32, speed float64) *telemetry.LobbySessionEvent {
+	return &telemetry.LobbySessionEvent{Event: &telemetry.LobbySessionEvent_DiscThrown{DiscThrown: &telemetry.DiscThrown{
+		PlayerSlot:   slot,
+		ThrowDetails: &apigame.LastThrowInfo{TotalSpeed: speed},
+	}}}
+}
+
+func scoreFrame(blue, orange int32) *telemetry.LobbySessionStateFrame {
+	frame := newStatusOnlyFrame(GameStatusPlaying)
+	frame.Session.BluePoints = blue
+	frame.Session.OrangePoints = orange
+	return frame
+}
+
+func newTestRuleEngine(t *testing.T) *RuleEngine {
+	t.Helper()
+	rules, err := ParseRules([]byte(testRules), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine, err := NewRuleEngine(rules...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
+func TestRuleEngine_EventRules(t *testing.T) {
+	engine := newTestRuleEngine(t)
+
+	events := []*telemetry.LobbySessionEvent{discThrownEvent(3, 25), discThrownEvent(4, 12)}
+	custom := []*CustomEvent{{Name: "pass", Fields: map[string]any{"distance": 35.0}}}
+	got := engine.Evaluate(scoreFrame(0, 0), events, custom, nil)
+
+	if len(got) != 2 {
+		t.Fatalf("expected LongShot and LongPass, got %v", got)
+	}
+	if got[0].Name != "LongShot" || got[0].Fields["slot"] != 3.0 || got[0].Fields["speed"] != 25.0 {
+		t.Errorf("unexpected LongShot: %+v", got[0])
+	}
+	if got[1].Name != "LongPass" || len(got[1].Fields) != 0 {
+		t.Errorf("unexpected LongPass: %+v", got[1])
+	}
+}
+
+func TestRuleEngine_FrameRulesFireOnceWhileTrue(t *testing.T) {
+	engine := newTestRuleEngine(t)
+
+	var fired []*CustomEvent
+	for _, score := range [][2]int32{{5, 0}, {6, 0}, {7, 0}, {7, 2}, {8, 2}} {
+		fired = engine.Evaluate(scoreFrame(score[0], score[1]), nil, nil, fired)
+	}
+	if len(fired) != 2 {
+		t.Fatalf("expected Blowout to fire at 6-0 and again at 8-2, got %v", fired)
+	}
+	if fired[0].Fields["blue"] != 6.0 || fired[1].Fields["blue"] != 8.0 {
+		t.Errorf("unexpected scores: %v, %v", fired[0].Fields, fired[1].Fields)
+	}
+
+	engine.Reset()
+	if got := engine.Evaluate(scoreFrame(8, 2), nil, nil, nil); len(got) != 1 {
+		t.Errorf("expected Blowout to fire again after a reset, got %v", got)
+	}
+}
+
+func TestParseRules_CustomDecoder(t *testing.T) {
+	// Decoders such as yaml.Unmarshal produce generic maps and slices
+	decode := func(_ []byte, v any) error {
+		*v.(*any) = map[string]any{"rules": []any{
+			map[string]any{"name": "Goal", "on": "goal_scored"},
+		}}
+		return nil
+	}
+	rules, err := ParseRules([]byte("rules: ..."), decode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || rules[0].Name != "Goal" || rules[0].On != "goal_scored" {
+		t.Errorf("unexpected rules: %+v", rules)
+	}
+}
+
+func TestParseRulesYAML(t *testing.T) {
+	data, err := os.ReadFile("testdata/rules.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, err := ParseRulesYAML(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ParseRules([]byte(testRules), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("expected the YAML rules to match the JSON ones\ngot:  %+v\nwant: %+v", rules, want)
+	}
+
+	if _, err := ParseRulesYAML([]byte("rules: [")); err == nil {
+		t.Error("expected an error for malformed YAML")
+	}
+}
+
+func TestNewRuleEngine_ReportsInvalidRules(t *testing.T) {
+	_, err := NewRuleEngine(
+		Rule{Name: "ok", When: "blue_points > 1"},
+		Rule{When: "true"},
+		Rule{Name: "bad", When: "blue_points >"},
+		Rule{Name: "badfield", Fields: map[string]string{"x": "("}},
+	)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"rule 1", "rule 2 (bad): when", "rule 3 (badfield): field x"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+}
+
+func TestWithRules_DeliversRuleEvents(t *testing.T) {
+	engine, err := NewRuleEngine(Rule{
+		Name:   "Echo",
+		On:     "first",
+		Fields: map[string]string{"value": "event.value"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	detector := New(
+		WithSynchronousProcessing(),
+		WithEnvelopes(),
+		WithRules(engine),
+		WithEventSensors(namedSensor{name: "first"}),
+	)
+	defer detector.Stop()
+
+	detector.ProcessFrame(indexedFrame(3))
+	var envelopes []*EventEnvelope
+	select {
+	case envelopes = <-detector.EnvelopesChan():
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for envelopes")
+	}
+
+	if len(envelopes) != 2 {
+		t.Fatalf("expected the sensor's and the rule's events, got %d", len(envelopes))
+	}
+	if c := envelopes[1].Custom; c == nil || c.Name != "Echo" || c.Fields["value"] != 1.0 {
+		t.Errorf("unexpected rule event: %+v", envelopes[1].Custom)
+	}
+}
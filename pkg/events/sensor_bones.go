@@ -0,0 +1,446 @@
+package events
+
+import (
+	"cmp"
+	"encoding/json"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/echotools/nevr-capture/v3/pkg/arena"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// Custom events reported by the bones sensors
+const (
+	// EventHandSwing is a fast hand movement such as a punch or stun swing.
+	// Fields: player_slot, hand ("left" or "right"), speed (m/s).
+	EventHandSwing = "hand_swing"
+	// EventHeadTurn is a quick turn of the head. Fields: player_slot,
+	// angle (degrees), peak_speed (degrees/s), duration (seconds).
+	EventHeadTurn = "head_turn"
+	// EventDiscGazeStarted is a player starting to look at the disc.
+	// Fields: player_slot, angle (degrees).
+	EventDiscGazeStarted = "disc_gaze_started"
+	// EventDiscGazeEnded is a player looking away from the disc. Fields:
+	// player_slot, duration (seconds).
+	EventDiscGazeEnded = "disc_gaze_ended"
+)
+
+var (
+	_ CustomEventSensor = (*HandSwingSensor)(nil)
+	_ CustomEventSensor = (*HeadTurnSensor)(nil)
+	_ CustomEventSensor = (*DiscGazeSensor)(nil)
+)
+
+// skeletonHistory keeps the skeletons of the previous frame so sensors can
+// measure joint movement
+type skeletonHistory struct {
+	prev   map[int32]arena.Skeleton
+	prevAt FrameTime
+}
+
+// advance decodes the skeletons of a frame and returns them with those of
+// the previous frame and the seconds elapsed between the two. The elapsed
+// time falls back to the game clock for frames without timestamps, and is
+// 0 when no time passed, e.g. while the clock is stopped.
+func (h *skeletonHistory) advance(frame *telemetry.LobbySessionStateFrame) (cur, prev map[int32]arena.Skeleton, dt float64) {
+	cur = arena.DecodeSkeletons(frame.GetPlayerBones())
+	prev = h.prev
+
+	now := FrameTimeOf(frame)
+	if prev != nil {
+		dt = max(now.Since(h.prevAt), 0)
+	}
+
+	h.prev = cur
+	h.prevAt = now
+	return cur, prev, dt
+}
+
+// skeletonHistoryState is the snapshot of a skeletonHistory
+type skeletonHistoryState struct {
+	Prev   map[int32]arena.Skeleton `json:"prev,omitempty"`
+	PrevAt FrameTime                `json:"prev_at"`
+}
+
+func (h *skeletonHistory) snapshot() skeletonHistoryState {
+	return skeletonHistoryState{Prev: h.prev, PrevAt: h.prevAt}
+}
+
+func (h *skeletonHistory) restore(state skeletonHistoryState) {
+	h.prev, h.prevAt = state.Prev, state.PrevAt
+}
+
+// sortedSlots returns the slots of a skeleton map in ascending order
+func sortedSlots(skeletons map[int32]arena.Skeleton) []int32 {
+	return slices.Sorted(maps.Keys(skeletons))
+}
+
+// HandSwingConfig configures a HandSwingSensor
+type HandSwingConfig struct {
+	// MinSpeed is the hand speed relative to the chest, in m/s, above which
+	// a swing is reported
+	MinSpeed float64 `json:"min_speed"`
+}
+
+// DefaultHandSwingConfig returns the default swing detection thresholds
+func DefaultHandSwingConfig() HandSwingConfig {
+	return HandSwingConfig{MinSpeed: 6}
+}
+
+// HandSwingSensor detects punch and stun swings from the speed of each hand
+// relative to the player's chest. A hand must slow down below half the
+// threshold before another swing with it is reported.
+//
+// Experimental: the sensor relies on the unverified arena.Joint order.
+type HandSwingSensor struct {
+	customOnly
+	config   HandSwingConfig
+	history  skeletonHistory
+	swinging map[handKey]bool
+}
+
+type handKey struct {
+	slot  int32
+	joint arena.Joint
+}
+
+// NewHandSwingSensor creates a new HandSwingSensor
+func NewHandSwingSensor(cfg HandSwingConfig) *HandSwingSensor {
+	return &HandSwingSensor{config: cfg, swinging: make(map[handKey]bool)}
+}
+
+// Reset clears the sensor state
+func (s *HandSwingSensor) Reset() {
+	*s = *NewHandSwingSensor(s.config)
+}
+
+// handSwingSensorState is the snapshot of a HandSwingSensor
+type handSwingSensorState struct {
+	History  skeletonHistoryState `json:"history"`
+	Swinging []handKeyState       `json:"swinging,omitempty"`
+}
+
+type handKeyState struct {
+	Slot  int32       `json:"slot"`
+	Joint arena.Joint `json:"joint"`
+}
+
+// Snapshot serializes the sensor state
+func (s *HandSwingSensor) Snapshot() ([]byte, error) {
+	state := handSwingSensorState{History: s.history.snapshot()}
+	for key, swinging := range s.swinging {
+		if swinging {
+			state.Swinging = append(state.Swinging, handKeyState{Slot: key.slot, Joint: key.joint})
+		}
+	}
+	slices.SortFunc(state.Swinging, func(a, b handKeyState) int {
+		return cmp.Or(cmp.Compare(a.Slot, b.Slot), cmp.Compare(a.Joint, b.Joint))
+	})
+	return json.Marshal(state)
+}
+
+// Restore replaces the sensor state with a previous snapshot
+func (s *HandSwingSensor) Restore(data []byte) error {
+	var state handSwingSensorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	s.Reset()
+	s.history.restore(state.History)
+	for _, key := range state.Swinging {
+		s.swinging[handKey{slot: key.Slot, joint: key.Joint}] = true
+	}
+	return nil
+}
+
+// DetectCustomEvents appends a hand_swing event for every hand that started
+// a swing
+func (s *HandSwingSensor) DetectCustomEvents(frame *telemetry.LobbySessionStateFrame, dst []*CustomEvent) []*CustomEvent {
+	if frame == nil {
+		return dst
+	}
+	cur, prev, dt := s.history.advance(frame)
+	if dt == 0 {
+		return dst
+	}
+
+	swinging := make(map[handKey]bool, len(s.swinging))
+	for _, slot := range sortedSlots(cur) {
+		before, ok := prev[slot]
+		if !ok {
+			continue
+		}
+		after := cur[slot]
+		for _, hand := range [...]arena.Joint{arena.JointLeftHand, arena.JointRightHand} {
+			key := handKey{slot: slot, joint: hand}
+			speed := relativeSpeed(before, after, hand, arena.JointChest, dt)
+			was := s.swinging[key]
+			switch {
+			case !was && speed >= s.config.MinSpeed:
+				dst = append(dst, &CustomEvent{
+					Name: EventHandSwing,
+					Fields: map[string]any{
+						"player_slot": slot,
+						"hand":        handName(hand),
+						"speed":       speed,
+					},
+				})
+				swinging[key] = true
+			case was && speed >= s.config.MinSpeed/2:
+				swinging[key] = true
+			}
+		}
+	}
+	s.swinging = swinging
+	return dst
+}
+
+// relativeSpeed returns the speed of joint relative to anchor between two
+// skeletons taken dt seconds apart
+func relativeSpeed(before, after arena.Skeleton, joint, anchor arena.Joint, dt float64) float64 {
+	was := before.Joints[joint].Position.Sub(before.Joints[anchor].Position)
+	now := after.Joints[joint].Position.Sub(after.Joints[anchor].Position)
+	return now.Dist(was) / dt
+}
+
+func handName(j arena.Joint) string {
+	if j == arena.JointLeftHand {
+		return "left"
+	}
+	return "right"
+}
+
+// HeadTurnConfig configures a HeadTurnSensor
+type HeadTurnConfig struct {
+	// MinAngularSpeed is the head rotation speed, in degrees per second,
+	// above which the head is considered turning
+	MinAngularSpeed float64 `json:"min_angular_speed"`
+	// MinAngle is the smallest completed turn, in degrees, that is reported
+	MinAngle float64 `json:"min_angle"`
+}
+
+// DefaultHeadTurnConfig returns the default head turn thresholds
+func DefaultHeadTurnConfig() HeadTurnConfig {
+	return HeadTurnConfig{MinAngularSpeed: 180, MinAngle: 45}
+}
+
+// HeadTurnSensor detects quick head turns, such as checking over the
+// shoulder. A turn is reported once the head slows down again.
+//
+// Experimental: the sensor relies on the unverified arena.Joint order.
+type HeadTurnSensor struct {
+	customOnly
+	config  HeadTurnConfig
+	history skeletonHistory
+	turns   map[int32]headTurn
+}
+
+// headTurn is a head turn in progress
+type headTurn struct {
+	angle     float64
+	peakSpeed float64
+	duration  float64
+}
+
+// NewHeadTurnSensor creates a new HeadTurnSensor
+func NewHeadTurnSensor(cfg HeadTurnConfig) *HeadTurnSensor {
+	return &HeadTurnSensor{config: cfg, turns: make(map[int32]headTurn)}
+}
+
+// Reset clears the sensor state
+func (s *HeadTurnSensor) Reset() {
+	*s = *NewHeadTurnSensor(s.config)
+}
+
+// headTurnSensorState is the snapshot of a HeadTurnSensor
+type headTurnSensorState struct {
+	History skeletonHistoryState    `json:"history"`
+	Turns   map[int32]headTurnState `json:"turns,omitempty"`
+}
+
+type headTurnState struct {
+	Angle     float64 `json:"angle"`
+	PeakSpeed float64 `json:"peak_speed"`
+	Duration  float64 `json:"duration"`
+}
+
+// Snapshot serializes the sensor state
+func (s *HeadTurnSensor) Snapshot() ([]byte, error) {
+	state := headTurnSensorState{History: s.history.snapshot()}
+	if len(s.turns) > 0 {
+		state.Turns = make(map[int32]headTurnState, len(s.turns))
+		for slot, turn := range s.turns {
+			state.Turns[slot] = headTurnState{Angle: turn.angle, PeakSpeed: turn.peakSpeed, Duration: turn.duration}
+		}
+	}
+	return json.Marshal(state)
+}
+
+// Restore replaces the sensor state with a previous snapshot
+func (s *HeadTurnSensor) Restore(data []byte) error {
+	var state headTurnSensorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	s.Reset()
+	s.history.restore(state.History)
+	for slot, turn := range state.Turns {
+		s.turns[slot] = headTurn{angle: turn.Angle, peakSpeed: turn.PeakSpeed, duration: turn.Duration}
+	}
+	return nil
+}
+
+// DetectCustomEvents appends a head_turn event for every completed turn
+func (s *HeadTurnSensor) DetectCustomEvents(frame *telemetry.LobbySessionStateFrame, dst []*CustomEvent) []*CustomEvent {
+	if frame == nil {
+		return dst
+	}
+	cur, prev, dt := s.history.advance(frame)
+	if dt == 0 {
+		return dst
+	}
+
+	turns := make(map[int32]headTurn, len(s.turns))
+	for _, slot := range sortedSlots(cur) {
+		before, ok := prev[slot]
+		if !ok {
+			continue
+		}
+		angle := arena.AngleBetween(
+			before.Joints[arena.JointHead].Orientation.Forward(),
+			cur[slot].Joints[arena.JointHead].Orientation.Forward(),
+		)
+		speed := angle / dt
+
+		turn, turning := s.turns[slot]
+		if speed >= s.config.MinAngularSpeed {
+			turn.angle += angle
+			turn.duration += dt
+			turn.peakSpeed = max(turn.peakSpeed, speed)
+			turns[slot] = turn
+			continue
+		}
+		if turning && turn.angle >= s.config.MinAngle {
+			dst = append(dst, &CustomEvent{
+				Name: EventHeadTurn,
+				Fields: map[string]any{
+					"player_slot": slot,
+					"angle":       turn.angle,
+					"peak_speed":  turn.peakSpeed,
+					"duration":    turn.duration,
+				},
+			})
+		}
+	}
+	s.turns = turns
+	return dst
+}
+
+// DiscGazeConfig configures a DiscGazeSensor
+type DiscGazeConfig struct {
+	// MaxAngle is the largest angle, in degrees, between the head's forward
+	// direction and the direction to the disc that counts as looking at it
+	MaxAngle float64 `json:"max_angle"`
+}
+
+// DefaultDiscGazeConfig returns the default gaze cone
+func DefaultDiscGazeConfig() DiscGazeConfig {
+	return DiscGazeConfig{MaxAngle: 15}
+}
+
+// DiscGazeSensor detects when players start and stop looking at the disc.
+// The head joint is assumed to face along its local +Z axis.
+//
+// Experimental: the sensor relies on the unverified arena.Joint order.
+type DiscGazeSensor struct {
+	customOnly
+	config  DiscGazeConfig
+	history skeletonHistory
+	since   map[int32]time.Time // gaze start by slot
+}
+
+// NewDiscGazeSensor creates a new DiscGazeSensor
+func NewDiscGazeSensor(cfg DiscGazeConfig) *DiscGazeSensor {
+	return &DiscGazeSensor{config: cfg, since: make(map[int32]time.Time)}
+}
+
+// Reset clears the sensor state
+func (s *DiscGazeSensor) Reset() {
+	*s = *NewDiscGazeSensor(s.config)
+}
+
+// discGazeSensorState is the snapshot of a DiscGazeSensor
+type discGazeSensorState struct {
+	History skeletonHistoryState `json:"history"`
+	Since   map[int32]time.Time  `json:"since,omitempty"`
+}
+
+// Snapshot serializes the sensor state
+func (s *DiscGazeSensor) Snapshot() ([]byte, error) {
+	return json.Marshal(discGazeSensorState{History: s.history.snapshot(), Since: s.since})
+}
+
+// Restore replaces the sensor state with a previous snapshot
+func (s *DiscGazeSensor) Restore(data []byte) error {
+	var state discGazeSensorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	s.Reset()
+	s.history.restore(state.History)
+	maps.Copy(s.since, state.Since)
+	return nil
+}
+
+// DetectCustomEvents appends disc_gaze_started and disc_gaze_ended events
+// for players whose gaze moved onto or off the disc
+func (s *DiscGazeSensor) DetectCustomEvents(frame *telemetry.LobbySessionStateFrame, dst []*CustomEvent) []*CustomEvent {
+	if frame == nil {
+		return dst
+	}
+	disc, ok := arena.DiscPosition(frame.GetSession())
+	if !ok {
+		return dst
+	}
+	cur, _, _ := s.history.advance(frame)
+	var now time.Time
+	if ts := frame.GetTimestamp(); ts != nil {
+		now = ts.AsTime()
+	}
+
+	since := make(map[int32]time.Time, len(s.since))
+	for _, slot := range sortedSlots(cur) {
+		head := cur[slot].Joints[arena.JointHead]
+		angle := arena.AngleBetween(head.Orientation.Forward(), disc.Sub(head.Position))
+		start, looking := s.since[slot]
+
+		switch {
+		case angle <= s.config.MaxAngle && !looking:
+			since[slot] = now
+			dst = append(dst, &CustomEvent{
+				Name:   EventDiscGazeStarted,
+				Fields: map[string]any{"player_slot": slot, "angle": angle},
+			})
+		case angle <= s.config.MaxAngle:
+			since[slot] = start
+		case looking:
+			dst = append(dst, &CustomEvent{
+				Name:   EventDiscGazeEnded,
+				Fields: map[string]any{"player_slot": slot, "duration": gazeDuration(start, now)},
+			})
+		}
+	}
+	s.since = since
+	return dst
+}
+
+// gazeDuration returns the seconds between start and end, or 0 if either is
+// unknown
+func gazeDuration(start, end time.Time) float64 {
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+	return end.Sub(start).Seconds()
+}
@@ -0,0 +1,179 @@
+package events
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-capture/v3/pkg/arena"
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var bonesEpoch = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// restSkeleton returns a skeleton with every joint at the origin facing +Z
+func restSkeleton(slot int32) arena.Skeleton {
+	s := arena.Skeleton{PlayerSlot: slot}
+	for j := range s.Joints {
+		s.Joints[j].Orientation = arena.IdentityQuat
+	}
+	return s
+}
+
+// yawQuat rotates about +Y by deg degrees
+func yawQuat(deg float64) arena.Quat {
+	half := deg * math.Pi / 360
+	return arena.Quat{Y: math.Sin(half), W: math.Cos(half)}
+}
+
+func encodeSkeleton(s arena.Skeleton) *apigame.UserBones {
+	bones := &apigame.UserBones{PlayerIndex: s.PlayerSlot}
+	for _, j := range s.Joints {
+		p, o := j.Position, j.Orientation
+		bones.BoneT = append(bones.BoneT, float32(p.X), float32(p.Y), float32(p.Z))
+		bones.BoneO = append(bones.BoneO, float32(o.X), float32(o.Y), float32(o.Z), float32(o.W))
+	}
+	return bones
+}
+
+// bonesFrame builds a frame captured at the given offset with the disc at
+// disc and the given skeletons
+func bonesFrame(at time.Duration, disc arena.Vec3, skeletons ...arena.Skeleton) *telemetry.LobbySessionStateFrame {
+	frame := &telemetry.LobbySessionStateFrame{
+		Timestamp: timestamppb.New(bonesEpoch.Add(at)),
+		Session: &apigame.SessionResponse{
+			Disc: &apigame.Disc{Position: []float64{disc.X, disc.Y, disc.Z}},
+		},
+		PlayerBones: &apigame.PlayerBonesResponse{},
+	}
+	for _, s := range skeletons {
+		frame.PlayerBones.UserBones = append(frame.PlayerBones.UserBones, encodeSkeleton(s))
+	}
+	return frame
+}
+
+func TestHandSwingSensor_DetectsSwing(t *testing.T) {
+	sensor := NewHandSwingSensor(DefaultHandSwingConfig())
+	step := 100 * time.Millisecond
+
+	rest := restSkeleton(2)
+	swing := restSkeleton(2)
+	swing.Joints[arena.JointRightHand].Position = arena.Vec3{Z: 1} // 10 m/s
+
+	if events := sensor.DetectCustomEvents(bonesFrame(0, arena.Vec3{}, rest), nil); len(events) != 0 {
+		t.Fatalf("expected no events on first frame, got %v", events)
+	}
+	events := sensor.DetectCustomEvents(bonesFrame(step, arena.Vec3{}, swing), nil)
+	if len(events) != 1 {
+		t.Fatalf("expected 1 swing, got %v", events)
+	}
+	fields := events[0].Fields
+	if events[0].Name != EventHandSwing || fields["player_slot"] != int32(2) || fields["hand"] != "right" {
+		t.Errorf("unexpected swing %+v", events[0])
+	}
+	if speed := fields["speed"].(float64); math.Abs(speed-10) > 1e-3 {
+		t.Errorf("expected speed 10, got %f", speed)
+	}
+
+	// Continuing the swing is not a new swing
+	follow := restSkeleton(2)
+	follow.Joints[arena.JointRightHand].Position = arena.Vec3{Z: 2}
+	if events := sensor.DetectCustomEvents(bonesFrame(2*step, arena.Vec3{}, follow), nil); len(events) != 0 {
+		t.Errorf("expected no event while swinging, got %v", events)
+	}
+}
+
+func TestHandSwingSensor_GameClockWithoutTimestamps(t *testing.T) {
+	sensor := NewHandSwingSensor(DefaultHandSwingConfig())
+	clockFrame := func(clock float64, s arena.Skeleton) *telemetry.LobbySessionStateFrame {
+		frame := bonesFrame(0, arena.Vec3{}, s)
+		frame.Timestamp = nil
+		frame.Session.GameClock = clock
+		return frame
+	}
+
+	swing := restSkeleton(2)
+	swing.Joints[arena.JointRightHand].Position = arena.Vec3{Z: 1}
+
+	sensor.DetectCustomEvents(clockFrame(100, restSkeleton(2)), nil)
+	events := sensor.DetectCustomEvents(clockFrame(99.9, swing), nil)
+	if len(events) != 1 {
+		t.Fatalf("expected a swing timed by the game clock, got %v", events)
+	}
+	if speed := events[0].Fields["speed"].(float64); math.Abs(speed-10) > 1e-3 {
+		t.Errorf("expected speed 10, got %f", speed)
+	}
+}
+
+func TestHandSwingSensor_IgnoresBodyMovement(t *testing.T) {
+	sensor := NewHandSwingSensor(DefaultHandSwingConfig())
+
+	moved := restSkeleton(0)
+	for j := range moved.Joints {
+		moved.Joints[j].Position = arena.Vec3{X: 5}
+	}
+	sensor.DetectCustomEvents(bonesFrame(0, arena.Vec3{}, restSkeleton(0)), nil)
+	if events := sensor.DetectCustomEvents(bonesFrame(100*time.Millisecond, arena.Vec3{}, moved), nil); len(events) != 0 {
+		t.Errorf("expected no swing when the whole body moves, got %v", events)
+	}
+}
+
+func TestHeadTurnSensor_ReportsCompletedTurn(t *testing.T) {
+	sensor := NewHeadTurnSensor(DefaultHeadTurnConfig())
+	step := 100 * time.Millisecond
+
+	frames := []float64{0, 30, 60, 90, 90} // degrees of yaw per frame
+	var events []*CustomEvent
+	for i, yaw := range frames {
+		s := restSkeleton(1)
+		s.Joints[arena.JointHead].Orientation = yawQuat(yaw)
+		events = sensor.DetectCustomEvents(bonesFrame(time.Duration(i)*step, arena.Vec3{}, s), events)
+	}
+
+	if len(events) != 1 || events[0].Name != EventHeadTurn {
+		t.Fatalf("expected 1 head turn, got %v", events)
+	}
+	if angle := events[0].Fields["angle"].(float64); math.Abs(angle-90) > 1e-3 {
+		t.Errorf("expected 90 degree turn, got %f", angle)
+	}
+}
+
+func TestDiscGazeSensor_StartAndEnd(t *testing.T) {
+	sensor := NewDiscGazeSensor(DefaultDiscGazeConfig())
+	ahead := arena.Vec3{Z: 10}
+	beside := arena.Vec3{X: 10}
+
+	events := sensor.DetectCustomEvents(bonesFrame(0, ahead, restSkeleton(3)), nil)
+	if len(events) != 1 || events[0].Name != EventDiscGazeStarted {
+		t.Fatalf("expected gaze start, got %v", events)
+	}
+	if events := sensor.DetectCustomEvents(bonesFrame(time.Second, ahead, restSkeleton(3)), nil); len(events) != 0 {
+		t.Errorf("expected no event while still looking, got %v", events)
+	}
+
+	events = sensor.DetectCustomEvents(bonesFrame(2*time.Second, beside, restSkeleton(3)), nil)
+	if len(events) != 1 || events[0].Name != EventDiscGazeEnded {
+		t.Fatalf("expected gaze end, got %v", events)
+	}
+	if d := events[0].Fields["duration"]; d != 2.0 {
+		t.Errorf("expected 2s gaze, got %v", d)
+	}
+}
+
+func TestBonesSensors_NoBones(t *testing.T) {
+	frame := &telemetry.LobbySessionStateFrame{Session: &apigame.SessionResponse{}}
+	for _, sensor := range []CustomEventSensor{
+		NewHandSwingSensor(DefaultHandSwingConfig()),
+		NewHeadTurnSensor(DefaultHeadTurnConfig()),
+		NewDiscGazeSensor(DefaultDiscGazeConfig()),
+	} {
+		if events := sensor.DetectCustomEvents(frame, nil); len(events) != 0 {
+			t.Errorf("%T: expected no events, got %v", sensor, events)
+		}
+		if events := sensor.DetectCustomEvents(nil, nil); len(events) != 0 {
+			t.Errorf("%T: expected no events for nil frame, got %v", sensor, events)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package events
+
+import (
+	"encoding/json"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// Custom events reported by the GameClockSensor
+const (
+	// EventOvertimeStarted is the clock running out with the score tied
+	// while the game keeps playing. Fields: blue_points, orange_points.
+	EventOvertimeStarted = "overtime_started"
+	// EventClockStopped is the game clock no longer advancing. Fields:
+	// clock (seconds), game_status.
+	EventClockStopped = "clock_stopped"
+	// EventClockResumed is the game clock advancing again after stopping.
+	// Fields: clock (seconds), game_status, stopped_for (seconds).
+	EventClockResumed = "clock_resumed"
+	// EventClockWarning is the clock running below a warning threshold.
+	// Fields: seconds (the threshold), clock (seconds).
+	EventClockWarning = "clock_warning"
+	// EventClockAnomaly is the clock misbehaving while the game is playing,
+	// usually a capture glitch or server hiccup. Fields: kind (one of the
+	// ClockAnomaly constants), clock and previous_clock (seconds), elapsed
+	// (seconds of frame time over which it happened).
+	EventClockAnomaly = "clock_anomaly"
+)
+
+// Kinds of clock anomaly
+const (
+	// ClockAnomalyBackwards is the countdown clock going up
+	ClockAnomalyBackwards = "backwards"
+	// ClockAnomalyJump is the clock running down faster than time passes
+	ClockAnomalyJump = "jump"
+	// ClockAnomalyStall is the clock stopped while the game is playing
+	ClockAnomalyStall = "stall"
+)
+
+// clockEpsilon is the smallest change of the clock, in seconds, counted as
+// movement
+const clockEpsilon = 0.001
+
+// GameClockConfig configures a GameClockSensor
+type GameClockConfig struct {
+	// WarningSeconds are the remaining times, in seconds, at which a clock
+	// warning is reported, in any order. Duplicates are ignored.
+	WarningSeconds []float64 `json:"warning_seconds"`
+	// StopDuration is how long, in seconds, the clock must stay still to be
+	// reported as stopped
+	StopDuration float64 `json:"stop_duration"`
+	// StallDuration is how long, in seconds, the clock must stay still while
+	// playing to be reported as a stall
+	StallDuration float64 `json:"stall_duration"`
+	// JumpTolerance is how far, in seconds, the clock may run ahead of or
+	// behind frame time before it is an anomaly
+	JumpTolerance float64 `json:"jump_tolerance"`
+	// FrameInterval is the time, in seconds, assumed to pass between two
+	// frames when either has no timestamp. A zero interval uses
+	// DefaultClockFrameInterval.
+	FrameInterval float64 `json:"frame_interval"`
+}
+
+// DefaultClockFrameInterval is the default time between frames without a
+// timestamp, one frame at 60 Hz
+const DefaultClockFrameInterval = 1.0 / 60
+
+// DefaultGameClockConfig returns the default clock thresholds
+func DefaultGameClockConfig() GameClockConfig {
+	return GameClockConfig{
+		WarningSeconds: []float64{60, 30, 10},
+		StopDuration:   0.25,
+		StallDuration:  1,
+		JumpTolerance:  1,
+		FrameInterval:  DefaultClockFrameInterval,
+	}
+}
+
+// ParseGameClock parses a game clock display such as "04:59.67" into
+// seconds. Minutes and hours are optional.
+func ParseGameClock(display string) (float64, bool) {
+	display = strings.TrimSpace(display)
+	if display == "" {
+		return 0, false
+	}
+	var seconds float64
+	for part := range strings.SplitSeq(display, ":") {
+		if part == "" || part[0] < '0' || part[0] > '9' {
+			return 0, false
+		}
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, false
+		}
+		seconds = seconds*60 + v
+	}
+	return seconds, true
+}
+
+// GameClockSensor interprets the game clock. It reports overtime, the clock
+// stopping and resuming, warnings as time runs out and anomalies such as
+// the clock going backwards or stalling while the game is playing. Time is
+// measured by frame timestamps, since the game clock cannot time itself;
+// frames without one count as FrameInterval apart.
+type GameClockSensor struct {
+	customOnly
+	config GameClockConfig
+
+	hasPrev     bool
+	prevClock   float64
+	prevTime    FrameTime
+	prevStatus  string
+	time        float64 // seconds of frame time since the first frame
+	changedAt   float64
+	playingAt   float64
+	stopped     bool
+	stalled     bool
+	overtime    bool
+	nextWarning int
+}
+
+var _ CustomEventSensor = (*GameClockSensor)(nil)
+
+// NewGameClockSensor creates a new GameClockSensor
+func NewGameClockSensor(cfg GameClockConfig) *GameClockSensor {
+	// Warnings are reported by walking the thresholds as the clock counts
+	// down
+	cfg.WarningSeconds = slices.Compact(slices.Sorted(slices.Values(cfg.WarningSeconds)))
+	slices.Reverse(cfg.WarningSeconds)
+	if cfg.FrameInterval <= 0 {
+		cfg.FrameInterval = DefaultClockFrameInterval
+	}
+	return &GameClockSensor{config: cfg}
+}
+
+// Reset clears the sensor state
+func (s *GameClockSensor) Reset() {
+	*s = GameClockSensor{config: s.config}
+}
+
+// gameClockSensorState is the snapshot of a GameClockSensor
+type gameClockSensorState struct {
+	HasPrev     bool      `json:"has_prev"`
+	PrevClock   float64   `json:"prev_clock"`
+	PrevTime    FrameTime `json:"prev_time"`
+	PrevStatus  string    `json:"prev_status,omitempty"`
+	Time        float64   `json:"time"`
+	ChangedAt   float64   `json:"changed_at"`
+	PlayingAt   float64   `json:"playing_at"`
+	Stopped     bool      `json:"stopped"`
+	Stalled     bool      `json:"stalled"`
+	Overtime    bool      `json:"overtime"`
+	NextWarning int       `json:"next_warning"`
+}
+
+// Snapshot serializes the sensor state
+func (s *GameClockSensor) Snapshot() ([]byte, error) {
+	return json.Marshal(gameClockSensorState{
+		HasPrev:     s.hasPrev,
+		PrevClock:   s.prevClock,
+		PrevTime:    s.prevTime,
+		PrevStatus:  s.prevStatus,
+		Time:        s.time,
+		ChangedAt:   s.changedAt,
+		PlayingAt:   s.playingAt,
+		Stopped:     s.stopped,
+		Stalled:     s.stalled,
+		Overtime:    s.overtime,
+		NextWarning: s.nextWarning,
+	})
+}
+
+// Restore replaces the sensor state with a previous snapshot
+func (s *GameClockSensor) Restore(data []byte) error {
+	var state gameClockSensorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	*s = GameClockSensor{
+		config:      s.config,
+		hasPrev:     state.HasPrev,
+		prevClock:   state.PrevClock,
+		prevTime:    state.PrevTime,
+		prevStatus:  state.PrevStatus,
+		time:        state.Time,
+		changedAt:   state.ChangedAt,
+		playingAt:   state.PlayingAt,
+		stopped:     state.Stopped,
+		stalled:     state.Stalled,
+		overtime:    state.Overtime,
+		nextWarning: min(state.NextWarning, len(s.config.WarningSeconds)),
+	}
+	return nil
+}
+
+// DetectCustomEvents appends the clock events of a frame
+func (s *GameClockSensor) DetectCustomEvents(frame *telemetry.LobbySessionStateFrame, dst []*CustomEvent) []*CustomEvent {
+	if frame == nil || frame.GetSession() == nil {
+		return dst
+	}
+	session := frame.GetSession()
+	status := session.GetGameStatus()
+	clock, ok := ParseGameClock(session.GetGameClockDisplay())
+	if !ok {
+		clock = session.GetGameClock()
+	}
+	now := FrameTimeOf(frame)
+
+	if !s.hasPrev {
+		s.hasPrev = true
+		s.prevClock, s.prevTime, s.prevStatus = clock, now, status
+		s.time, s.changedAt, s.playingAt = 0, 0, 0
+		return dst
+	}
+	prevClock, prevStatus := s.prevClock, s.prevStatus
+	elapsed := s.config.FrameInterval
+	if !now.Wall().IsZero() && !s.prevTime.Wall().IsZero() {
+		elapsed = now.Since(s.prevTime)
+	}
+	s.time += elapsed
+	s.prevClock, s.prevTime, s.prevStatus = clock, now, status
+
+	if status == GameStatusRoundStart || (clock > prevClock+s.config.JumpTolerance && status != GameStatusPlaying) {
+		// A new round resets the clock
+		s.overtime = false
+		s.nextWarning = 0
+	}
+
+	playing := status == GameStatusPlaying && prevStatus == GameStatusPlaying
+	if status == GameStatusPlaying && !playing {
+		s.playingAt = s.time
+	}
+	moved := math.Abs(clock-prevClock) > clockEpsilon
+	if playing && moved && !s.overtime {
+		switch {
+		case clock-prevClock > s.config.JumpTolerance:
+			dst = append(dst, clockAnomaly(ClockAnomalyBackwards, clock, prevClock, elapsed))
+		case elapsed > 0 && prevClock-clock > elapsed+s.config.JumpTolerance:
+			dst = append(dst, clockAnomaly(ClockAnomalyJump, clock, prevClock, elapsed))
+		}
+	}
+
+	if moved {
+		if s.stopped {
+			dst = append(dst, &CustomEvent{
+				Name: EventClockResumed,
+				Fields: map[string]any{
+					"clock":       clock,
+					"game_status": status,
+					"stopped_for": s.time - s.changedAt,
+				},
+			})
+		}
+		s.changedAt, s.stopped, s.stalled = s.time, false, false
+	} else {
+		still := s.time - s.changedAt
+		if !s.stopped && still >= s.config.StopDuration {
+			s.stopped = true
+			dst = append(dst, &CustomEvent{
+				Name: EventClockStopped,
+				Fields: map[string]any{
+					"clock":       clock,
+					"game_status": status,
+				},
+			})
+		}
+		// Only the part of a stop spent playing counts towards a stall
+		stalledFor := min(still, s.time-s.playingAt)
+		if playing && clock > 0 && !s.stalled && stalledFor >= s.config.StallDuration {
+			s.stalled = true
+			dst = append(dst, clockAnomaly(ClockAnomalyStall, clock, prevClock, stalledFor))
+		}
+	}
+
+	if status != GameStatusPlaying {
+		return dst
+	}
+	for s.nextWarning < len(s.config.WarningSeconds) && clock <= s.config.WarningSeconds[s.nextWarning] {
+		if prevClock > s.config.WarningSeconds[s.nextWarning] {
+			dst = append(dst, &CustomEvent{
+				Name: EventClockWarning,
+				Fields: map[string]any{
+					"seconds": s.config.WarningSeconds[s.nextWarning],
+					"clock":   clock,
+				},
+			})
+		}
+		s.nextWarning++
+	}
+	if !s.overtime && clock <= clockEpsilon && session.GetBluePoints() == session.GetOrangePoints() {
+		s.overtime = true
+		dst = append(dst, &CustomEvent{
+			Name: EventOvertimeStarted,
+			Fields: map[string]any{
+				"blue_points":   session.GetBluePoints(),
+				"orange_points": session.GetOrangePoints(),
+			},
+		})
+	}
+	return dst
+}
+
+func clockAnomaly(kind string, clock, prevClock, elapsed float64) *CustomEvent {
+	return &CustomEvent{
+		Name: EventClockAnomaly,
+		Fields: map[string]any{
+			"kind":           kind,
+			"clock":          clock,
+			"previous_clock": prevClock,
+			"elapsed":        elapsed,
+		},
+	}
+}
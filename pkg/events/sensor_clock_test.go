@@ -0,0 +1,153 @@
+package events
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func clockFrame(at time.Duration, status string, clock float64) *telemetry.LobbySessionStateFrame {
+	frame := newStatusOnlyFrame(status)
+	frame.Timestamp = timestamppb.New(bonesEpoch.Add(at))
+	frame.Session.GameClock = clock
+	return frame
+}
+
+// runClock feeds the sensor one frame per 100ms with the given clock
+// readings and returns the events
+func runClock(sensor *GameClockSensor, status string, clocks ...float64) []*CustomEvent {
+	var events []*CustomEvent
+	for i, clock := range clocks {
+		events = sensor.DetectCustomEvents(clockFrame(time.Duration(i)*100*time.Millisecond, status, clock), events)
+	}
+	return events
+}
+
+func clockNames(events []*CustomEvent) []string {
+	var names []string
+	for _, e := range events {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+func TestParseGameClock(t *testing.T) {
+	tests := []struct {
+		display string
+		want    float64
+		ok      bool
+	}{
+		{"04:59.67", 299.67, true},
+		{"00:10.00", 10, true},
+		{"9.5", 9.5, true},
+		{"1:00:00", 3600, true},
+		{"", 0, false},
+		{"OVERTIME", 0, false},
+		{"-0:01", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseGameClock(tt.display)
+		if ok != tt.ok || !approx(got, tt.want) {
+			t.Errorf("ParseGameClock(%q) = %v, %v; want %v, %v", tt.display, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestGameClockSensor_WarningsAndOvertime(t *testing.T) {
+	sensor := NewGameClockSensor(GameClockConfig{WarningSeconds: []float64{0.15, 0.35, 0.15}, StopDuration: 10, StallDuration: 10, JumpTolerance: 1})
+
+	events := runClock(sensor, GameStatusPlaying, 0.5, 0.4, 0.3, 0.2, 0.1, 0)
+
+	want := []string{EventClockWarning, EventClockWarning, EventOvertimeStarted}
+	if got := clockNames(events); !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if events[0].Fields["seconds"] != 0.35 || events[1].Fields["seconds"] != 0.15 {
+		t.Errorf("expected warnings in countdown order, got %v and %v", events[0].Fields, events[1].Fields)
+	}
+}
+
+func TestGameClockSensor_NoOvertimeWhenNotTied(t *testing.T) {
+	sensor := NewGameClockSensor(DefaultGameClockConfig())
+
+	sensor.DetectCustomEvents(clockFrame(0, GameStatusPlaying, 0.1), nil)
+	frame := clockFrame(100*time.Millisecond, GameStatusPlaying, 0)
+	frame.Session.BluePoints = 2
+	if events := sensor.DetectCustomEvents(frame, nil); len(events) != 0 {
+		t.Errorf("expected no overtime with a winner, got %v", clockNames(events))
+	}
+}
+
+func TestGameClockSensor_StopAndResume(t *testing.T) {
+	sensor := NewGameClockSensor(DefaultGameClockConfig())
+
+	sensor.DetectCustomEvents(clockFrame(0, GameStatusPlaying, 100), nil)
+	var events []*CustomEvent
+	for i := 1; i <= 5; i++ {
+		events = sensor.DetectCustomEvents(clockFrame(time.Duration(i)*100*time.Millisecond, GameStatusScore, 100), events)
+	}
+	events = sensor.DetectCustomEvents(clockFrame(600*time.Millisecond, GameStatusPlaying, 99.9), events)
+
+	if got := clockNames(events); !slices.Equal(got, []string{EventClockStopped, EventClockResumed}) {
+		t.Fatalf("expected a stop and a resume, got %v", got)
+	}
+	if !approx(events[1].Fields["stopped_for"].(float64), 0.6) {
+		t.Errorf("expected the clock stopped for 0.6s, got %v", events[1].Fields["stopped_for"])
+	}
+}
+
+func TestGameClockSensor_Anomalies(t *testing.T) {
+	tests := []struct {
+		name   string
+		clocks []float64
+		kind   string
+	}{
+		{"backwards", []float64{100, 99.9, 105}, ClockAnomalyBackwards},
+		{"jump", []float64{100, 99.9, 90}, ClockAnomalyJump},
+		{"stall", []float64{100, 100, 100, 100, 100, 100, 100, 100, 100, 100, 100, 100}, ClockAnomalyStall},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sensor := NewGameClockSensor(DefaultGameClockConfig())
+			var anomalies []*CustomEvent
+			for _, e := range runClock(sensor, GameStatusPlaying, tt.clocks...) {
+				if e.Name == EventClockAnomaly {
+					anomalies = append(anomalies, e)
+				}
+			}
+			if len(anomalies) != 1 || anomalies[0].Fields["kind"] != tt.kind {
+				t.Errorf("expected one %s anomaly, got %v", tt.kind, anomalies)
+			}
+		})
+	}
+}
+
+func TestGameClockSensor_NoStallWhilePaused(t *testing.T) {
+	sensor := NewGameClockSensor(DefaultGameClockConfig())
+
+	events := runClock(sensor, GameStatusPaused, 100, 100, 100, 100, 100, 100, 100, 100, 100, 100, 100, 100, 100)
+	if got := clockNames(events); !slices.Equal(got, []string{EventClockStopped}) {
+		t.Errorf("expected only a clock stop while paused, got %v", got)
+	}
+}
+
+func TestGameClockSensor_StallWithoutTimestamps(t *testing.T) {
+	sensor := NewGameClockSensor(GameClockConfig{StopDuration: 0.25, StallDuration: 1, JumpTolerance: 1, FrameInterval: 0.125})
+
+	var events []*CustomEvent
+	for range 12 {
+		frame := clockFrame(0, GameStatusPlaying, 100)
+		frame.Timestamp = nil
+		events = sensor.DetectCustomEvents(frame, events)
+	}
+
+	if got := clockNames(events); !slices.Equal(got, []string{EventClockStopped, EventClockAnomaly}) {
+		t.Fatalf("expected a stop and a stall timed by frame count, got %v", got)
+	}
+	if stalled := events[1].Fields["elapsed"].(float64); !approx(stalled, 1) {
+		t.Errorf("expected the stall reported after 1s of frames, got %v", stalled)
+	}
+}
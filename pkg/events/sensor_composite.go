@@ -0,0 +1,364 @@
+package events
+
+import (
+	"encoding/json"
+	"slices"
+
+	"github.com/echotools/nevr-capture/v3/pkg/arena"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// Custom events reported by the composite derived sensors
+const (
+	// EventGoalWithAssist is a goal credited to its scorer and assist.
+	// Fields: scorer_slot and assist_slot (-1 when unknown or unassisted),
+	// attribution (how the assist was found: "stat", "possession" or
+	// "none"), team, points, chain (slots of the scoring team's possessions
+	// leading to the goal, oldest first), person_scored and assist_scored
+	// (names reported by the game).
+	EventGoalWithAssist = "goal_with_assist"
+	// EventSaveCounterattack is a team carrying the disc into the opponent's
+	// half shortly after a save. Fields: saver_slot, carrier_slot, team,
+	// elapsed (seconds since the save).
+	EventSaveCounterattack = "save_counterattack"
+	// EventTurnoverGoal is a goal scored shortly after the scoring team won
+	// the disc. Fields: takeaway_slot, takeaway ("steal" or
+	// "interception"), scorer_slot, team, elapsed (seconds from the
+	// takeaway to the goal).
+	EventTurnoverGoal = "turnover_goal"
+)
+
+// CompositeConfig configures the composite derived sensors
+type CompositeConfig struct {
+	// Window is how far back, in seconds, the events leading to a composite
+	// event are looked for
+	Window float64 `json:"window"`
+}
+
+// DefaultGoalAssistConfig returns the default window for crediting assists
+func DefaultGoalAssistConfig() CompositeConfig {
+	return CompositeConfig{Window: 10}
+}
+
+// DefaultSaveCounterattackConfig returns the default window for a
+// counterattack to follow a save
+func DefaultSaveCounterattackConfig() CompositeConfig {
+	return CompositeConfig{Window: 3}
+}
+
+// DefaultTurnoverGoalConfig returns the default window for a goal to follow
+// a takeaway
+func DefaultTurnoverGoalConfig() CompositeConfig {
+	return CompositeConfig{Window: 10}
+}
+
+// recentFrames returns the frames at most window seconds before current
+// followed by current
+func recentFrames(current DetectedEvents, history *EventHistory, window float64) []DetectedEvents {
+	return append(slices.Clone(history.Within(current, window)), current)
+}
+
+// possessions returns the slots of the players who held the disc in frames,
+// oldest first. A disc becoming free credits the player who released it.
+func possessions(frames []DetectedEvents) []int32 {
+	var out []int32
+	for _, f := range frames {
+		for _, ev := range f.Events {
+			change := ev.GetDiscPossessionChanged()
+			if change == nil {
+				continue
+			}
+			for _, slot := range [...]int32{change.GetPreviousPlayerSlot(), change.GetPlayerSlot()} {
+				if slot >= 0 && (len(out) == 0 || out[len(out)-1] != slot) {
+					out = append(out, slot)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// latestSlot returns the slot of the newest event in frames picked by slot,
+// or -1 if there is none
+func latestSlot(frames []DetectedEvents, slot func(*telemetry.LobbySessionEvent) (int32, bool)) int32 {
+	for _, f := range slices.Backward(frames) {
+		for _, ev := range slices.Backward(f.Events) {
+			if s, ok := slot(ev); ok {
+				return s
+			}
+		}
+	}
+	return -1
+}
+
+// GoalAssistSensor credits every goal to its scorer and assist. Goal and
+// assist stats are used when they are updated by the goal; otherwise the
+// scorer is the last player to hold the disc and the assist the teammate who
+// held it before them.
+type GoalAssistSensor struct {
+	config CompositeConfig
+}
+
+var _ DerivedSensor = (*GoalAssistSensor)(nil)
+
+// NewGoalAssistSensor creates a new GoalAssistSensor
+func NewGoalAssistSensor(cfg CompositeConfig) *GoalAssistSensor {
+	return &GoalAssistSensor{config: cfg}
+}
+
+// DetectDerivedEvents appends a goal_with_assist event for every goal of the
+// frame
+func (s *GoalAssistSensor) DetectDerivedEvents(current DetectedEvents, history *EventHistory, dst []*CustomEvent) []*CustomEvent {
+	for _, ev := range current.Events {
+		goal := ev.GetGoalScored()
+		if goal == nil {
+			continue
+		}
+		frames := recentFrames(current, history, s.config.Window)
+		roster := NewRoster(current.Frame.GetSession())
+		scorer, assist, attribution, chain := creditGoal(frames, roster)
+
+		team := ""
+		if player, ok := roster.BySlot(scorer); ok {
+			team = player.Role.String()
+		}
+		details := goal.GetScoreDetails()
+		dst = append(dst, &CustomEvent{
+			Name: EventGoalWithAssist,
+			Fields: map[string]any{
+				"scorer_slot":   scorer,
+				"assist_slot":   assist,
+				"attribution":   attribution,
+				"team":          team,
+				"points":        details.GetPointAmount(),
+				"chain":         chain,
+				"person_scored": details.GetPersonScored(),
+				"assist_scored": details.GetAssistScored(),
+			},
+		})
+	}
+	return dst
+}
+
+// creditGoal finds the scorer and assist of a goal from the events leading
+// to it, along with the scoring team's chain of possessions
+func creditGoal(frames []DetectedEvents, roster *Roster) (scorer, assist int32, attribution string, chain []int32) {
+	held := possessions(frames)
+	scorer = latestSlot(frames, func(ev *telemetry.LobbySessionEvent) (int32, bool) {
+		return ev.GetPlayerGoal().GetPlayerSlot(), ev.GetPlayerGoal() != nil
+	})
+	if scorer < 0 && len(held) > 0 {
+		scorer = held[len(held)-1]
+	}
+	if scorer < 0 {
+		return -1, -1, "none", nil
+	}
+
+	// Walk back from the scorer's last possession while the scoring team
+	// held the disc
+	scorerPlayer, _ := roster.BySlot(scorer)
+	end := len(held)
+	for end > 0 && held[end-1] != scorer {
+		end--
+	}
+	start := end
+	for start > 0 {
+		player, ok := roster.BySlot(held[start-1])
+		if !ok || player.Role != scorerPlayer.Role {
+			break
+		}
+		start--
+	}
+	chain = slices.Clone(held[start:end])
+
+	if assist = latestSlot(frames, func(ev *telemetry.LobbySessionEvent) (int32, bool) {
+		return ev.GetPlayerAssist().GetPlayerSlot(), ev.GetPlayerAssist() != nil
+	}); assist >= 0 && assist != scorer {
+		return scorer, assist, "stat", chain
+	}
+	for _, slot := range slices.Backward(chain) {
+		if slot != scorer {
+			return scorer, slot, "possession", chain
+		}
+	}
+	return scorer, -1, "none", chain
+}
+
+// SaveCounterattackSensor reports a team carrying the disc into the
+// opponent's half shortly after one of its players made a save
+type SaveCounterattackSensor struct {
+	config CompositeConfig
+	// credited is the last save of each team already reported
+	credited map[telemetry.Role]creditedSave
+}
+
+// creditedSave identifies a save by the frame it was detected in and the
+// saver, so it survives a snapshot
+type creditedSave struct {
+	at   FrameTime
+	slot int32
+}
+
+// saveCounterattackSensorState is the snapshot of a SaveCounterattackSensor
+type saveCounterattackSensorState struct {
+	Credited map[telemetry.Role]creditedSaveState `json:"credited,omitempty"`
+}
+
+type creditedSaveState struct {
+	At   FrameTime `json:"at"`
+	Slot int32     `json:"slot"`
+}
+
+var _ DerivedSensor = (*SaveCounterattackSensor)(nil)
+
+// NewSaveCounterattackSensor creates a new SaveCounterattackSensor
+func NewSaveCounterattackSensor(cfg CompositeConfig) *SaveCounterattackSensor {
+	return &SaveCounterattackSensor{
+		config:   cfg,
+		credited: make(map[telemetry.Role]creditedSave),
+	}
+}
+
+// Reset clears the sensor state
+func (s *SaveCounterattackSensor) Reset() {
+	clear(s.credited)
+}
+
+// Snapshot serializes the sensor state
+func (s *SaveCounterattackSensor) Snapshot() ([]byte, error) {
+	var state saveCounterattackSensorState
+	if len(s.credited) > 0 {
+		state.Credited = make(map[telemetry.Role]creditedSaveState, len(s.credited))
+		for role, save := range s.credited {
+			state.Credited[role] = creditedSaveState{At: save.at, Slot: save.slot}
+		}
+	}
+	return json.Marshal(state)
+}
+
+// Restore replaces the sensor state with a previous snapshot
+func (s *SaveCounterattackSensor) Restore(data []byte) error {
+	var state saveCounterattackSensorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	s.Reset()
+	for role, save := range state.Credited {
+		s.credited[role] = creditedSave{at: save.At, slot: save.Slot}
+	}
+	return nil
+}
+
+// DetectDerivedEvents appends a save_counterattack event when the disc
+// carrier's team saved within the window
+func (s *SaveCounterattackSensor) DetectDerivedEvents(current DetectedEvents, history *EventHistory, dst []*CustomEvent) []*CustomEvent {
+	roster := NewRoster(current.Frame.GetSession())
+	var carrier RosterPlayer
+	found := false
+	for _, player := range roster.Players() {
+		if isTeamRole(player.Role) && player.Member.GetHasPossession() {
+			carrier, found = player, true
+			break
+		}
+	}
+	if !found {
+		return dst
+	}
+	pos, ok := arena.PlayerPosition(carrier.Member)
+	if !ok || (pos.Z > 0) != (carrier.Role == telemetry.Role_ROLE_BLUE_TEAM) {
+		return dst
+	}
+
+	frames := recentFrames(current, history, s.config.Window)
+	for _, f := range slices.Backward(frames) {
+		for _, ev := range slices.Backward(f.Events) {
+			save := ev.GetPlayerSave()
+			if save == nil {
+				continue
+			}
+			saver, ok := roster.BySlot(save.GetPlayerSlot())
+			if !ok || saver.Role != carrier.Role {
+				continue
+			}
+			credit := creditedSave{at: f.at, slot: save.GetPlayerSlot()}
+			if prev, ok := s.credited[carrier.Role]; ok && prev.at.Equal(credit.at) && prev.slot == credit.slot {
+				return dst
+			}
+			s.credited[carrier.Role] = credit
+			return append(dst, &CustomEvent{
+				Name: EventSaveCounterattack,
+				Fields: map[string]any{
+					"saver_slot":   save.GetPlayerSlot(),
+					"carrier_slot": carrier.Member.GetSlotNumber(),
+					"team":         carrier.Role.String(),
+					"elapsed":      current.Since(f),
+				},
+			})
+		}
+	}
+	return dst
+}
+
+// TurnoverGoalSensor reports goals scored shortly after the scoring team
+// stole or intercepted the disc
+type TurnoverGoalSensor struct {
+	config CompositeConfig
+}
+
+var _ DerivedSensor = (*TurnoverGoalSensor)(nil)
+
+// NewTurnoverGoalSensor creates a new TurnoverGoalSensor
+func NewTurnoverGoalSensor(cfg CompositeConfig) *TurnoverGoalSensor {
+	return &TurnoverGoalSensor{config: cfg}
+}
+
+// DetectDerivedEvents appends a turnover_goal event for a goal that
+// followed a takeaway by the scoring team
+func (s *TurnoverGoalSensor) DetectDerivedEvents(current DetectedEvents, history *EventHistory, dst []*CustomEvent) []*CustomEvent {
+	if !slices.ContainsFunc(current.Events, func(ev *telemetry.LobbySessionEvent) bool {
+		return ev.GetGoalScored() != nil
+	}) {
+		return dst
+	}
+	frames := recentFrames(current, history, s.config.Window)
+	roster := NewRoster(current.Frame.GetSession())
+	scorerSlot, _, _, _ := creditGoal(frames, roster)
+	scorer, ok := roster.BySlot(scorerSlot)
+	if !ok {
+		return dst
+	}
+
+	for _, f := range slices.Backward(frames) {
+		for _, ev := range slices.Backward(f.Events) {
+			var slot int32
+			var takeaway string
+			switch {
+			case ev.GetPlayerSteal() != nil:
+				slot, takeaway = ev.GetPlayerSteal().GetPlayerSlot(), "steal"
+			case ev.GetPlayerInterception() != nil:
+				slot, takeaway = ev.GetPlayerInterception().GetPlayerSlot(), "interception"
+			default:
+				continue
+			}
+			player, ok := roster.BySlot(slot)
+			if !ok {
+				continue
+			}
+			if player.Role != scorer.Role {
+				// The other team took the disc back since
+				return dst
+			}
+			return append(dst, &CustomEvent{
+				Name: EventTurnoverGoal,
+				Fields: map[string]any{
+					"takeaway_slot": slot,
+					"takeaway":      takeaway,
+					"scorer_slot":   scorerSlot,
+					"team":          scorer.Role.String(),
+					"elapsed":       current.Since(f),
+				},
+			})
+		}
+	}
+	return dst
+}
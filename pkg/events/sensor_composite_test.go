@@ -0,0 +1,173 @@
+package events
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// compositeStep is one frame fed to a derived sensor: blue has slots 0 and
+// 1, orange slots 2 and 3. carrier holds the disc at carrierZ, or nobody
+// when -1.
+type compositeStep struct {
+	at       time.Duration
+	events   []*telemetry.LobbySessionEvent
+	carrier  int32
+	carrierZ float64
+}
+
+func compositeFrame(step compositeStep) *telemetry.LobbySessionStateFrame {
+	member := func(slot int32) *apigame.TeamMember {
+		m := &apigame.TeamMember{SlotNumber: slot, DisplayName: "P" + string(rune('0'+slot))}
+		if slot == step.carrier {
+			m.HasPossession = true
+			m.Body = &apigame.BodyPart{Position: []float64{0, 0, step.carrierZ}}
+		}
+		return m
+	}
+	frame := createFrameWithTeams(
+		[]*apigame.TeamMember{member(0), member(1)},
+		[]*apigame.TeamMember{member(2), member(3)},
+		nil)
+	frame.Timestamp = timestamppb.New(bonesEpoch.Add(step.at))
+	frame.Session.GameStatus = GameStatusPlaying
+	return frame
+}
+
+func runComposite(sensor DerivedSensor, steps ...compositeStep) []*CustomEvent {
+	history := NewEventHistory(DefaultEventHistoryWindow)
+	var out []*CustomEvent
+	for _, step := range steps {
+		current := newDetectedEvents(compositeFrame(step), step.events, nil)
+		out = sensor.DetectDerivedEvents(current, history, out)
+		history.Add(current)
+	}
+	return out
+}
+
+func possessionChange(slot, prev int32) *telemetry.LobbySessionEvent {
+	return &telemetry.LobbySessionEvent{Event: &telemetry.LobbySessionEvent_DiscPossessionChanged{
+		DiscPossessionChanged: &telemetry.DiscPossessionChanged{PlayerSlot: slot, PreviousPlayerSlot: prev},
+	}}
+}
+
+func goalScored() *telemetry.LobbySessionEvent {
+	return &telemetry.LobbySessionEvent{Event: &telemetry.LobbySessionEvent_GoalScored{
+		GoalScored: &telemetry.GoalScored{ScoreDetails: &apigame.LastScore{PointAmount: 2, PersonScored: "P1"}},
+	}}
+}
+
+func playerSave(slot int32) *telemetry.LobbySessionEvent {
+	return &telemetry.LobbySessionEvent{Event: &telemetry.LobbySessionEvent_PlayerSave{PlayerSave: &telemetry.PlayerSave{PlayerSlot: slot}}}
+}
+
+func playerSteal(slot int32) *telemetry.LobbySessionEvent {
+	return &telemetry.LobbySessionEvent{Event: &telemetry.LobbySessionEvent_PlayerSteal{PlayerSteal: &telemetry.PlayerSteal{PlayerSlot: slot}}}
+}
+
+func playerAssist(slot int32) *telemetry.LobbySessionEvent {
+	return &telemetry.LobbySessionEvent{Event: &telemetry.LobbySessionEvent_PlayerAssist{PlayerAssist: &telemetry.PlayerAssist{PlayerSlot: slot}}}
+}
+
+func TestGoalAssistSensor_CreditsPossessionChain(t *testing.T) {
+	events := runComposite(NewGoalAssistSensor(DefaultGoalAssistConfig()),
+		compositeStep{at: 0, events: []*telemetry.LobbySessionEvent{possessionChange(2, -1)}, carrier: 2},
+		compositeStep{at: time.Second, events: []*telemetry.LobbySessionEvent{possessionChange(0, 2)}, carrier: 0},
+		compositeStep{at: 2 * time.Second, events: []*telemetry.LobbySessionEvent{possessionChange(1, 0)}, carrier: 1},
+		compositeStep{at: 3 * time.Second, events: []*telemetry.LobbySessionEvent{possessionChange(-1, 1)}, carrier: -1},
+		compositeStep{at: 4 * time.Second, events: []*telemetry.LobbySessionEvent{goalScored()}, carrier: -1},
+	)
+
+	if len(events) != 1 || events[0].Name != EventGoalWithAssist {
+		t.Fatalf("expected one goal, got %v", events)
+	}
+	f := events[0].Fields
+	if f["scorer_slot"] != int32(1) || f["assist_slot"] != int32(0) || f["attribution"] != "possession" {
+		t.Errorf("expected slot 1 assisted by slot 0, got %v", f)
+	}
+	if !slices.Equal(f["chain"].([]int32), []int32{0, 1}) || f["team"] != "ROLE_BLUE_TEAM" || f["points"] != int32(2) {
+		t.Errorf("unexpected goal details: %v", f)
+	}
+}
+
+func TestGoalAssistSensor_PrefersStats(t *testing.T) {
+	events := runComposite(NewGoalAssistSensor(DefaultGoalAssistConfig()),
+		compositeStep{at: 0, events: []*telemetry.LobbySessionEvent{possessionChange(1, -1)}, carrier: 1},
+		compositeStep{at: time.Second, events: []*telemetry.LobbySessionEvent{goalScored(), playerAssist(0)}, carrier: -1},
+	)
+
+	if len(events) != 1 {
+		t.Fatalf("expected one goal, got %v", events)
+	}
+	if f := events[0].Fields; f["assist_slot"] != int32(0) || f["attribution"] != "stat" {
+		t.Errorf("expected the assist stat to credit slot 0, got %v", f)
+	}
+}
+
+func TestGoalAssistSensor_Unassisted(t *testing.T) {
+	events := runComposite(NewGoalAssistSensor(DefaultGoalAssistConfig()),
+		compositeStep{at: 0, events: []*telemetry.LobbySessionEvent{possessionChange(1, 2)}, carrier: 1},
+		compositeStep{at: time.Second, events: []*telemetry.LobbySessionEvent{goalScored()}, carrier: -1},
+	)
+
+	if f := events[0].Fields; f["scorer_slot"] != int32(1) || f["assist_slot"] != int32(-1) || f["attribution"] != "none" {
+		t.Errorf("expected an unassisted goal after a steal, got %v", f)
+	}
+}
+
+func TestSaveCounterattackSensor(t *testing.T) {
+	sensor := NewSaveCounterattackSensor(DefaultSaveCounterattackConfig())
+	events := runComposite(sensor,
+		compositeStep{at: 0, events: []*telemetry.LobbySessionEvent{playerSave(0)}, carrier: 0, carrierZ: -30},
+		compositeStep{at: time.Second, carrier: 1, carrierZ: -5},
+		compositeStep{at: 2 * time.Second, carrier: 1, carrierZ: 5},
+		compositeStep{at: 2500 * time.Millisecond, carrier: 1, carrierZ: 10},
+	)
+
+	if len(events) != 1 || events[0].Name != EventSaveCounterattack {
+		t.Fatalf("expected one counterattack, got %v", events)
+	}
+	if f := events[0].Fields; f["saver_slot"] != int32(0) || f["carrier_slot"] != int32(1) || !approx(f["elapsed"].(float64), 2) {
+		t.Errorf("unexpected counterattack: %v", f)
+	}
+}
+
+func TestSaveCounterattackSensor_TooSlow(t *testing.T) {
+	events := runComposite(NewSaveCounterattackSensor(DefaultSaveCounterattackConfig()),
+		compositeStep{at: 0, events: []*telemetry.LobbySessionEvent{playerSave(2)}, carrier: 2, carrierZ: 30},
+		compositeStep{at: 4 * time.Second, carrier: 3, carrierZ: -5},
+	)
+	if len(events) != 0 {
+		t.Errorf("expected no counterattack outside the window, got %v", events)
+	}
+}
+
+func TestTurnoverGoalSensor(t *testing.T) {
+	events := runComposite(NewTurnoverGoalSensor(DefaultTurnoverGoalConfig()),
+		compositeStep{at: 0, events: []*telemetry.LobbySessionEvent{possessionChange(1, 2), playerSteal(1)}, carrier: 1},
+		compositeStep{at: 3 * time.Second, events: []*telemetry.LobbySessionEvent{possessionChange(-1, 1)}, carrier: -1},
+		compositeStep{at: 4 * time.Second, events: []*telemetry.LobbySessionEvent{goalScored()}, carrier: -1},
+	)
+
+	if len(events) != 1 || events[0].Name != EventTurnoverGoal {
+		t.Fatalf("expected one turnover goal, got %v", events)
+	}
+	if f := events[0].Fields; f["takeaway_slot"] != int32(1) || f["takeaway"] != "steal" || !approx(f["elapsed"].(float64), 4) {
+		t.Errorf("unexpected turnover goal: %v", f)
+	}
+
+	// A takeaway by the other team since breaks the sequence
+	events = runComposite(NewTurnoverGoalSensor(DefaultTurnoverGoalConfig()),
+		compositeStep{at: 0, events: []*telemetry.LobbySessionEvent{playerSteal(1)}, carrier: 1},
+		compositeStep{at: time.Second, events: []*telemetry.LobbySessionEvent{playerSteal(2)}, carrier: 2},
+		compositeStep{at: 2 * time.Second, events: []*telemetry.LobbySessionEvent{possessionChange(0, 2)}, carrier: 0},
+		compositeStep{at: 3 * time.Second, events: []*telemetry.LobbySessionEvent{goalScored()}, carrier: -1},
+	)
+	if len(events) != 0 {
+		t.Errorf("expected no turnover goal, got %v", events)
+	}
+}
@@ -0,0 +1,417 @@
+package events
+
+import (
+	"cmp"
+	"encoding/json"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// Custom events reported by the ConnectionQualitySensor
+const (
+	// EventPingThreshold is a player's average ping over the window crossing
+	// a threshold. Fields: player_slot, team, threshold (ms), ping (window
+	// average in ms) and rising (true when crossing above the threshold).
+	EventPingThreshold = "ping_threshold"
+	// EventJitterSpike is the spread of a player's ping over the window
+	// rising above the jitter threshold. Fields: player_slot, team, jitter
+	// (standard deviation in ms), ping, min_ping and max_ping (ms).
+	EventJitterSpike = "jitter_spike"
+	// EventConnectionReport is a player's connection quality over the
+	// match, reported at match end. Fields: player_slot, team, time
+	// (seconds observed), ping_min, ping_max, ping_average, max_jitter (ms),
+	// time_above (map of threshold in ms, formatted as a string, to seconds
+	// the window average spent above it), jitter_spikes, packet_loss_average
+	// and packet_loss_max (ratios).
+	EventConnectionReport = "connection_report"
+)
+
+// ConnectionQualityConfig configures a ConnectionQualitySensor
+type ConnectionQualityConfig struct {
+	// Window is the length, in seconds, of the sliding window ping is
+	// averaged over
+	Window float64 `json:"window"`
+	// WindowSamples caps the number of samples in the window, so it still
+	// slides when frames have no timestamp and the game clock is stopped. A
+	// zero cap uses DefaultConnectionWindowSamples.
+	WindowSamples int `json:"window_samples"`
+	// PingThresholds are the pings, in ms, whose crossings are reported, in
+	// any order. Duplicates are ignored.
+	PingThresholds []int32 `json:"ping_thresholds"`
+	// JitterThreshold is the standard deviation of ping, in ms, above which
+	// a jitter spike is reported
+	JitterThreshold float64 `json:"jitter_threshold"`
+}
+
+// DefaultConnectionWindowSamples is the default cap on the samples in the
+// ping window, five seconds of frames at 60 Hz
+const DefaultConnectionWindowSamples = 300
+
+// DefaultConnectionQualityConfig returns the default connection thresholds
+func DefaultConnectionQualityConfig() ConnectionQualityConfig {
+	return ConnectionQualityConfig{
+		Window:          5,
+		WindowSamples:   DefaultConnectionWindowSamples,
+		PingThresholds:  []int32{100, 150, 250},
+		JitterThreshold: 30,
+	}
+}
+
+// ConnectionQualitySensor tracks every player's ping over a sliding window.
+// It reports the window average crossing ping thresholds and jitter spikes,
+// and a connection report per player at match end.
+type ConnectionQualitySensor struct {
+	customOnly
+	playerTracker
+	config ConnectionQualityConfig
+
+	players    map[PlayerKey]*connectionState
+	prevTime   FrameTime
+	hasPrev    bool
+	prevStatus string
+}
+
+// connectionState is the connection history of one player
+type connectionState struct {
+	player  RosterPlayer
+	window  []pingSample
+	level   int // number of thresholds the window average is above
+	jittery bool
+
+	time         float64
+	samples      int
+	pingSum      float64
+	pingMin      int32
+	pingMax      int32
+	maxJitter    float64
+	timeAbove    []float64 // by threshold
+	jitterSpikes int
+	lossSum      float64
+	lossMax      float64
+}
+
+// pingSample is a ping reading at a point in time
+type pingSample struct {
+	at   FrameTime
+	ping int32
+}
+
+var _ CustomEventSensor = (*ConnectionQualitySensor)(nil)
+
+// NewConnectionQualitySensor creates a new ConnectionQualitySensor
+func NewConnectionQualitySensor(cfg ConnectionQualityConfig) *ConnectionQualitySensor {
+	// Crossings are found by walking the thresholds in ascending order
+	cfg.PingThresholds = slices.Compact(slices.Sorted(slices.Values(cfg.PingThresholds)))
+	if cfg.WindowSamples <= 0 {
+		cfg.WindowSamples = DefaultConnectionWindowSamples
+	}
+	return &ConnectionQualitySensor{
+		playerTracker: newPlayerTracker(),
+		config:        cfg,
+		players:       make(map[PlayerKey]*connectionState),
+	}
+}
+
+// Reset clears the sensor state
+func (s *ConnectionQualitySensor) Reset() {
+	s.reset()
+	s.players = make(map[PlayerKey]*connectionState)
+	s.hasPrev = false
+	s.prevStatus = ""
+}
+
+// connectionQualitySensorState is the snapshot of a ConnectionQualitySensor
+type connectionQualitySensorState struct {
+	States     []connectionStateSnapshot `json:"states"`
+	PrevTime   FrameTime                 `json:"prev_time"`
+	HasPrev    bool                      `json:"has_prev"`
+	PrevStatus string                    `json:"prev_status,omitempty"`
+	Players    json.RawMessage           `json:"players,omitempty"`
+}
+
+type connectionStateSnapshot struct {
+	Player       rosterPlayerState `json:"player"`
+	Window       []pingSampleState `json:"window"`
+	Level        int               `json:"level"`
+	Jittery      bool              `json:"jittery"`
+	Time         float64           `json:"time"`
+	Samples      int               `json:"samples"`
+	PingSum      float64           `json:"ping_sum"`
+	PingMin      int32             `json:"ping_min"`
+	PingMax      int32             `json:"ping_max"`
+	MaxJitter    float64           `json:"max_jitter"`
+	TimeAbove    []float64         `json:"time_above"`
+	JitterSpikes int               `json:"jitter_spikes"`
+	LossSum      float64           `json:"loss_sum"`
+	LossMax      float64           `json:"loss_max"`
+}
+
+type pingSampleState struct {
+	At   FrameTime `json:"at"`
+	Ping int32     `json:"ping"`
+}
+
+// Snapshot serializes the sensor state
+func (s *ConnectionQualitySensor) Snapshot() ([]byte, error) {
+	players, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	state := connectionQualitySensorState{
+		States:     make([]connectionStateSnapshot, 0, len(s.players)),
+		PrevTime:   s.prevTime,
+		HasPrev:    s.hasPrev,
+		PrevStatus: s.prevStatus,
+		Players:    players,
+	}
+	for _, cs := range sortedConnections(s.players) {
+		player, err := snapshotRosterPlayer(cs.player)
+		if err != nil {
+			return nil, err
+		}
+		window := make([]pingSampleState, len(cs.window))
+		for i, sample := range cs.window {
+			window[i] = pingSampleState{At: sample.at, Ping: sample.ping}
+		}
+		state.States = append(state.States, connectionStateSnapshot{
+			Player:       player,
+			Window:       window,
+			Level:        cs.level,
+			Jittery:      cs.jittery,
+			Time:         cs.time,
+			Samples:      cs.samples,
+			PingSum:      cs.pingSum,
+			PingMin:      cs.pingMin,
+			PingMax:      cs.pingMax,
+			MaxJitter:    cs.maxJitter,
+			TimeAbove:    cs.timeAbove,
+			JitterSpikes: cs.jitterSpikes,
+			LossSum:      cs.lossSum,
+			LossMax:      cs.lossMax,
+		})
+	}
+	return json.Marshal(state)
+}
+
+// Restore replaces the sensor state with a previous snapshot
+func (s *ConnectionQualitySensor) Restore(data []byte) error {
+	var state connectionQualitySensorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	s.Reset()
+	for _, cs := range state.States {
+		player, err := restoreRosterPlayer(cs.Player)
+		if err != nil {
+			return err
+		}
+		window := make([]pingSample, len(cs.Window))
+		for i, sample := range cs.Window {
+			window[i] = pingSample{at: sample.At, ping: sample.Ping}
+		}
+		// Sized by the current thresholds so a mismatched snapshot cannot
+		// index out of range
+		timeAbove := make([]float64, len(s.config.PingThresholds))
+		copy(timeAbove, cs.TimeAbove)
+		s.players[player.Key] = &connectionState{
+			player:       player,
+			window:       window,
+			level:        min(cs.Level, len(s.config.PingThresholds)),
+			jittery:      cs.Jittery,
+			time:         cs.Time,
+			samples:      cs.Samples,
+			pingSum:      cs.PingSum,
+			pingMin:      cs.PingMin,
+			pingMax:      cs.PingMax,
+			maxJitter:    cs.MaxJitter,
+			timeAbove:    timeAbove,
+			jitterSpikes: cs.JitterSpikes,
+			lossSum:      cs.LossSum,
+			lossMax:      cs.LossMax,
+		}
+	}
+	s.prevTime, s.hasPrev, s.prevStatus = state.PrevTime, state.HasPrev, state.PrevStatus
+	if state.Players != nil {
+		return s.restore(state.Players)
+	}
+	return nil
+}
+
+// DetectCustomEvents samples the frame's pings and appends threshold
+// crossings, jitter spikes and, at match end, the connection reports
+func (s *ConnectionQualitySensor) DetectCustomEvents(frame *telemetry.LobbySessionStateFrame, dst []*CustomEvent) []*CustomEvent {
+	if frame == nil || frame.GetSession() == nil {
+		return dst
+	}
+	roster := s.observe(frame).Roster()
+	status := frame.GetSession().GetGameStatus()
+	prevStatus := s.prevStatus
+	s.prevStatus = status
+
+	if status == GameStatusPostMatch && prevStatus != GameStatusPostMatch && prevStatus != "" {
+		dst = s.appendReports(dst)
+		s.players = make(map[PlayerKey]*connectionState)
+	}
+
+	now := FrameTimeOf(frame)
+	dt := now.Since(s.prevTime)
+	if !s.hasPrev || dt <= 0 || dt > maxFrameGap {
+		dt = 0
+	}
+	s.prevTime, s.hasPrev = now, true
+	if status == GameStatusPostMatch {
+		return dst
+	}
+
+	for _, player := range roster.Players() {
+		// A ping of zero is a player the server has no reading for yet
+		if !isTeamRole(player.Role) || player.Member.GetPing() <= 0 {
+			continue
+		}
+		state := s.players[player.Key]
+		if state == nil {
+			state = &connectionState{timeAbove: make([]float64, len(s.config.PingThresholds))}
+			s.players[player.Key] = state
+		}
+		state.player = player
+		dst = s.sample(dst, state, now, dt)
+	}
+	return dst
+}
+
+// sample adds the player's current ping to their window and appends any
+// threshold crossing or jitter spike
+func (s *ConnectionQualitySensor) sample(dst []*CustomEvent, state *connectionState, now FrameTime, dt float64) []*CustomEvent {
+	ping := state.player.Member.GetPing()
+	loss := state.player.Member.GetPacketLossRatio()
+
+	state.window = append(state.window, pingSample{at: now, ping: ping})
+	expired := max(len(state.window)-s.config.WindowSamples, 0)
+	for expired < len(state.window)-1 && now.Since(state.window[expired].at) > s.config.Window {
+		expired++
+	}
+	state.window = slices.Delete(state.window, 0, expired)
+
+	average, jitter, lo, hi := pingSpread(state.window)
+
+	if state.samples == 0 {
+		state.pingMin, state.pingMax = ping, ping
+	}
+	state.samples++
+	state.time += dt
+	state.pingSum += float64(ping)
+	state.pingMin = min(state.pingMin, ping)
+	state.pingMax = max(state.pingMax, ping)
+	state.maxJitter = max(state.maxJitter, jitter)
+	state.lossSum += loss
+	state.lossMax = max(state.lossMax, loss)
+
+	slot := state.player.Member.GetSlotNumber()
+	team := state.player.Role.String()
+
+	level := 0
+	for i, threshold := range s.config.PingThresholds {
+		if average > float64(threshold) {
+			level = i + 1
+			state.timeAbove[i] += dt
+		}
+	}
+	for ; state.level < level; state.level++ {
+		dst = append(dst, pingThresholdEvent(slot, team, s.config.PingThresholds[state.level], average, true))
+	}
+	for ; state.level > level; state.level-- {
+		dst = append(dst, pingThresholdEvent(slot, team, s.config.PingThresholds[state.level-1], average, false))
+	}
+
+	jittery := jitter > s.config.JitterThreshold
+	if jittery && !state.jittery {
+		state.jitterSpikes++
+		dst = append(dst, &CustomEvent{
+			Name: EventJitterSpike,
+			Fields: map[string]any{
+				"player_slot": slot,
+				"team":        team,
+				"jitter":      jitter,
+				"ping":        average,
+				"min_ping":    lo,
+				"max_ping":    hi,
+			},
+		})
+	}
+	state.jittery = jittery
+	return dst
+}
+
+func pingThresholdEvent(slot int32, team string, threshold int32, ping float64, rising bool) *CustomEvent {
+	return &CustomEvent{
+		Name: EventPingThreshold,
+		Fields: map[string]any{
+			"player_slot": slot,
+			"team":        team,
+			"threshold":   threshold,
+			"ping":        ping,
+			"rising":      rising,
+		},
+	}
+}
+
+// pingSpread returns the average, standard deviation, minimum and maximum
+// of a non-empty window
+func pingSpread(window []pingSample) (average, stddev float64, lo, hi int32) {
+	lo, hi = window[0].ping, window[0].ping
+	for _, sample := range window {
+		average += float64(sample.ping)
+		lo, hi = min(lo, sample.ping), max(hi, sample.ping)
+	}
+	average /= float64(len(window))
+	for _, sample := range window {
+		d := float64(sample.ping) - average
+		stddev += d * d
+	}
+	return average, math.Sqrt(stddev / float64(len(window))), lo, hi
+}
+
+// appendReports appends a connection report for every tracked player in
+// slot order
+func (s *ConnectionQualitySensor) appendReports(dst []*CustomEvent) []*CustomEvent {
+	for _, state := range sortedConnections(s.players) {
+		timeAbove := make(map[string]float64, len(state.timeAbove))
+		for i, threshold := range s.config.PingThresholds {
+			timeAbove[strconv.Itoa(int(threshold))] = state.timeAbove[i]
+		}
+		samples := float64(state.samples)
+		dst = append(dst, &CustomEvent{
+			Name: EventConnectionReport,
+			Fields: map[string]any{
+				"player_slot":         state.player.Member.GetSlotNumber(),
+				"team":                state.player.Role.String(),
+				"time":                state.time,
+				"ping_min":            state.pingMin,
+				"ping_max":            state.pingMax,
+				"ping_average":        state.pingSum / samples,
+				"max_jitter":          state.maxJitter,
+				"time_above":          timeAbove,
+				"jitter_spikes":       state.jitterSpikes,
+				"packet_loss_average": state.lossSum / samples,
+				"packet_loss_max":     state.lossMax,
+			},
+		})
+	}
+	return dst
+}
+
+// sortedConnections returns the tracked players in slot order
+func sortedConnections(players map[PlayerKey]*connectionState) []*connectionState {
+	states := slices.Collect(maps.Values(players))
+	slices.SortFunc(states, func(a, b *connectionState) int {
+		return cmp.Or(
+			cmp.Compare(a.player.Member.GetSlotNumber(), b.player.Member.GetSlotNumber()),
+			comparePlayerKeys(a.player.Key, b.player.Key),
+		)
+	})
+	return states
+}
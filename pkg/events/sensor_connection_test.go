@@ -0,0 +1,154 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func connectionFrame(at time.Duration, status string, bluePing, orangePing int32, loss float64) *telemetry.LobbySessionStateFrame {
+	frame := createFrameWithTeams(
+		[]*apigame.TeamMember{{SlotNumber: 0, DisplayName: "Blue", Ping: bluePing, PacketLossRatio: loss}},
+		[]*apigame.TeamMember{{SlotNumber: 1, DisplayName: "Orange", Ping: orangePing}},
+		nil)
+	frame.Timestamp = timestamppb.New(bonesEpoch.Add(at))
+	frame.Session.GameStatus = status
+	return frame
+}
+
+func connectionEvents(events []*CustomEvent, name string) []*CustomEvent {
+	var out []*CustomEvent
+	for _, e := range events {
+		if e.Name == name {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func TestConnectionQualitySensor_PingThresholds(t *testing.T) {
+	sensor := NewConnectionQualitySensor(ConnectionQualityConfig{
+		Window:          1,
+		PingThresholds:  []int32{150, 100, 150},
+		JitterThreshold: 1000,
+	})
+
+	var events []*CustomEvent
+	var at time.Duration
+	step := func(n int, ping int32) {
+		for range n {
+			events = sensor.DetectCustomEvents(connectionFrame(at, GameStatusPlaying, ping, 40, 0), events)
+			at += 100 * time.Millisecond
+		}
+	}
+	step(20, 50)
+	step(20, 200)
+	if crossings := connectionEvents(events, EventPingThreshold); len(crossings) != 2 {
+		t.Fatalf("expected both thresholds crossed, got %v", crossings)
+	}
+	first := events[0].Fields
+	if first["player_slot"] != int32(0) || first["threshold"] != int32(100) || first["rising"] != true {
+		t.Errorf("expected the lower threshold crossed first, got %v", first)
+	}
+
+	events = events[:0]
+	step(20, 50)
+	crossings := connectionEvents(events, EventPingThreshold)
+	if len(crossings) != 2 || crossings[0].Fields["threshold"] != int32(150) || crossings[1].Fields["rising"] != false {
+		t.Errorf("expected both thresholds crossed back down, got %v", crossings)
+	}
+}
+
+func TestConnectionQualitySensor_JitterSpike(t *testing.T) {
+	sensor := NewConnectionQualitySensor(DefaultConnectionQualityConfig())
+
+	var events []*CustomEvent
+	var at time.Duration
+	for i := range 30 {
+		ping := int32(60)
+		if i >= 10 && i < 20 && i%2 == 0 {
+			ping = 160
+		}
+		events = sensor.DetectCustomEvents(connectionFrame(at, GameStatusPlaying, ping, 40, 0), events)
+		at += 100 * time.Millisecond
+	}
+
+	spikes := connectionEvents(events, EventJitterSpike)
+	if len(spikes) != 1 {
+		t.Fatalf("expected one jitter spike, got %v", spikes)
+	}
+	if f := spikes[0].Fields; f["player_slot"] != int32(0) || f["max_ping"] != int32(160) || f["min_ping"] != int32(60) {
+		t.Errorf("unexpected jitter spike: %v", f)
+	}
+}
+
+func TestConnectionQualitySensor_MatchReport(t *testing.T) {
+	sensor := NewConnectionQualitySensor(DefaultConnectionQualityConfig())
+
+	var at time.Duration
+	for i := range 11 {
+		ping, loss := int32(80), 0.0
+		if i == 5 {
+			ping, loss = int32(120), 0.5
+		}
+		sensor.DetectCustomEvents(connectionFrame(at, GameStatusPlaying, ping, 40, loss), nil)
+		at += 100 * time.Millisecond
+	}
+	// Pauses between rounds are still observed
+	sensor.DetectCustomEvents(connectionFrame(at, GameStatusRoundOver, 80, 40, 0), nil)
+	events := sensor.DetectCustomEvents(connectionFrame(at+100*time.Millisecond, GameStatusPostMatch, 80, 40, 0), nil)
+
+	reports := connectionEvents(events, EventConnectionReport)
+	if len(reports) != 2 {
+		t.Fatalf("expected a report per player, got %v", events)
+	}
+	f := reports[0].Fields
+	if f["player_slot"] != int32(0) || f["ping_min"] != int32(80) || f["ping_max"] != int32(120) {
+		t.Errorf("unexpected ping range: %v", f)
+	}
+	if !approx(f["time"].(float64), 1.1) || !approx(f["packet_loss_max"].(float64), 0.5) || !approx(f["packet_loss_average"].(float64), 0.5/12) {
+		t.Errorf("unexpected report: %v", f)
+	}
+	if reports[1].Fields["ping_average"] != 40.0 {
+		t.Errorf("expected orange's steady ping, got %v", reports[1].Fields)
+	}
+
+	// The next match starts a fresh report
+	if events := sensor.DetectCustomEvents(connectionFrame(at+time.Second, GameStatusPostMatch, 80, 40, 0), nil); len(events) != 0 {
+		t.Errorf("expected a single report per match, got %v", events)
+	}
+}
+
+func TestConnectionQualitySensor_WindowCappedWithoutTime(t *testing.T) {
+	sensor := NewConnectionQualitySensor(ConnectionQualityConfig{
+		Window:          5,
+		WindowSamples:   10,
+		PingThresholds:  []int32{100},
+		JitterThreshold: 1000,
+	})
+
+	// No timestamps and a stopped game clock: only the cap slides the window
+	var events []*CustomEvent
+	for i := range 50 {
+		ping := int32(50)
+		if i >= 40 {
+			ping = 200
+		}
+		frame := connectionFrame(0, GameStatusPlaying, ping, 40, 0)
+		frame.Timestamp = nil
+		events = sensor.DetectCustomEvents(frame, events)
+	}
+
+	for _, state := range sensor.players {
+		if len(state.window) != 10 {
+			t.Errorf("expected the window capped at 10 samples, got %d", len(state.window))
+		}
+	}
+	// An uncapped window would average 80ms over all 50 samples
+	if crossings := connectionEvents(events, EventPingThreshold); len(crossings) != 1 {
+		t.Errorf("expected the capped window to cross the threshold, got %v", crossings)
+	}
+}
@@ -1,6 +1,8 @@
 package events
 
 import (
+	"encoding/json"
+
 	"github.com/echotools/nevr-common/v4/gen/go/apigame"
 	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
 )
@@ -18,6 +20,36 @@ func NewDiscPossessionSensor() *DiscPossessionSensor {
 	}
 }
 
+// Reset clears the sensor state
+func (s *DiscPossessionSensor) Reset() {
+	*s = *NewDiscPossessionSensor()
+}
+
+// discPossessionSensorState is the snapshot of a DiscPossessionSensor
+type discPossessionSensorState struct {
+	PrevPossessorSlot int32 `json:"prev_possessor_slot"`
+	Initialized       bool  `json:"initialized"`
+}
+
+// Snapshot serializes the sensor state
+func (s *DiscPossessionSensor) Snapshot() ([]byte, error) {
+	return json.Marshal(discPossessionSensorState{
+		PrevPossessorSlot: s.prevPossessorSlot,
+		Initialized:       s.initialized,
+	})
+}
+
+// Restore replaces the sensor state with a previous snapshot
+func (s *DiscPossessionSensor) Restore(data []byte) error {
+	var state discPossessionSensorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	s.prevPossessorSlot = state.PrevPossessorSlot
+	s.initialized = state.Initialized
+	return nil
+}
+
 // AddFrame processes a frame and returns a DiscPossessionChanged event if detected
 func (s *DiscPossessionSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
 	if frame == nil || frame.GetSession() == nil {
@@ -61,6 +93,48 @@ func NewDiscThrownSensor() *DiscThrownSensor {
 	}
 }
 
+// Reset clears the sensor state
+func (s *DiscThrownSensor) Reset() {
+	*s = *NewDiscThrownSensor()
+}
+
+// discThrownSensorState is the snapshot of a DiscThrownSensor
+type discThrownSensorState struct {
+	PrevLastThrow json.RawMessage `json:"prev_last_throw,omitempty"`
+	PrevPossessor int32           `json:"prev_possessor"`
+}
+
+// Snapshot serializes the sensor state
+func (s *DiscThrownSensor) Snapshot() ([]byte, error) {
+	raw, err := marshalProto(s.prevLastThrow)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(discThrownSensorState{
+		PrevLastThrow: raw,
+		PrevPossessor: s.prevPossessor,
+	})
+}
+
+// Restore replaces the sensor state with a previous snapshot
+func (s *DiscThrownSensor) Restore(data []byte) error {
+	var state discThrownSensorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	lastThrow := &apigame.LastThrowInfo{}
+	ok, err := unmarshalProto(state.PrevLastThrow, lastThrow)
+	if err != nil {
+		return err
+	}
+	s.Reset()
+	if ok {
+		s.prevLastThrow = lastThrow
+	}
+	s.prevPossessor = state.PrevPossessor
+	return nil
+}
+
 // AddFrame processes a frame and returns a DiscThrown event if detected
 func (s *DiscThrownSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
 	if frame == nil || frame.GetSession() == nil {
@@ -119,6 +193,36 @@ func NewDiscCaughtSensor() *DiscCaughtSensor {
 	}
 }
 
+// Reset clears the sensor state
+func (s *DiscCaughtSensor) Reset() {
+	*s = *NewDiscCaughtSensor()
+}
+
+// discCaughtSensorState is the snapshot of a DiscCaughtSensor
+type discCaughtSensorState struct {
+	PrevPossessorSlot int32 `json:"prev_possessor_slot"`
+	Initialized       bool  `json:"initialized"`
+}
+
+// Snapshot serializes the sensor state
+func (s *DiscCaughtSensor) Snapshot() ([]byte, error) {
+	return json.Marshal(discCaughtSensorState{
+		PrevPossessorSlot: s.prevPossessorSlot,
+		Initialized:       s.initialized,
+	})
+}
+
+// Restore replaces the sensor state with a previous snapshot
+func (s *DiscCaughtSensor) Restore(data []byte) error {
+	var state discCaughtSensorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	s.prevPossessorSlot = state.PrevPossessorSlot
+	s.initialized = state.Initialized
+	return nil
+}
+
 // AddFrame processes a frame and returns a DiscCaught event if detected
 func (s *DiscCaughtSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
 	if frame == nil || frame.GetSession() == nil {
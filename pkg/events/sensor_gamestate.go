@@ -1,6 +1,8 @@
 package events
 
 import (
+	"encoding/json"
+
 	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
 )
 
@@ -24,6 +26,36 @@ func NewRoundStartSensor() *RoundStartSensor {
 	return &RoundStartSensor{}
 }
 
+// Reset clears the sensor state
+func (s *RoundStartSensor) Reset() {
+	*s = *NewRoundStartSensor()
+}
+
+// roundStartSensorState is the snapshot of a RoundStartSensor
+type roundStartSensorState struct {
+	PrevGameStatus string `json:"prev_game_status"`
+	RoundNumber    int32  `json:"round_number"`
+}
+
+// Snapshot serializes the sensor state
+func (s *RoundStartSensor) Snapshot() ([]byte, error) {
+	return json.Marshal(roundStartSensorState{
+		PrevGameStatus: s.prevGameStatus,
+		RoundNumber:    s.roundNumber,
+	})
+}
+
+// Restore replaces the sensor state with a previous snapshot
+func (s *RoundStartSensor) Restore(data []byte) error {
+	var state roundStartSensorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	s.prevGameStatus = state.PrevGameStatus
+	s.roundNumber = state.RoundNumber
+	return nil
+}
+
 // AddFrame processes a frame and returns a RoundStarted event if detected
 func (s *RoundStartSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
 	if frame == nil || frame.GetSession() == nil {
@@ -65,6 +97,33 @@ func NewPauseSensor() *PauseSensor {
 	return &PauseSensor{}
 }
 
+// Reset clears the sensor state
+func (s *PauseSensor) Reset() {
+	*s = *NewPauseSensor()
+}
+
+// pauseSensorState is the snapshot of a PauseSensor
+type pauseSensorState struct {
+	PrevPauseState string `json:"prev_pause_state"`
+}
+
+// Snapshot serializes the sensor state
+func (s *PauseSensor) Snapshot() ([]byte, error) {
+	return json.Marshal(pauseSensorState{
+		PrevPauseState: s.prevPauseState,
+	})
+}
+
+// Restore replaces the sensor state with a previous snapshot
+func (s *PauseSensor) Restore(data []byte) error {
+	var state pauseSensorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	s.prevPauseState = state.PrevPauseState
+	return nil
+}
+
 // AddFrame processes a frame and returns pause-related events
 func (s *PauseSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
 	if frame == nil || frame.GetSession() == nil {
@@ -128,6 +187,42 @@ func NewRoundEndSensor() *RoundEndSensor {
 	return &RoundEndSensor{}
 }
 
+// Reset clears the sensor state
+func (s *RoundEndSensor) Reset() {
+	*s = *NewRoundEndSensor()
+}
+
+// roundEndSensorState is the snapshot of a RoundEndSensor
+type roundEndSensorState struct {
+	PrevGameStatus       string `json:"prev_game_status"`
+	PrevBlueRoundScore   int32  `json:"prev_blue_round_score"`
+	PrevOrangeRoundScore int32  `json:"prev_orange_round_score"`
+	Initialized          bool   `json:"initialized"`
+}
+
+// Snapshot serializes the sensor state
+func (s *RoundEndSensor) Snapshot() ([]byte, error) {
+	return json.Marshal(roundEndSensorState{
+		PrevGameStatus:       s.prevGameStatus,
+		PrevBlueRoundScore:   s.prevBlueRoundScore,
+		PrevOrangeRoundScore: s.prevOrangeRoundScore,
+		Initialized:          s.initialized,
+	})
+}
+
+// Restore replaces the sensor state with a previous snapshot
+func (s *RoundEndSensor) Restore(data []byte) error {
+	var state roundEndSensorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	s.prevGameStatus = state.PrevGameStatus
+	s.prevBlueRoundScore = state.PrevBlueRoundScore
+	s.prevOrangeRoundScore = state.PrevOrangeRoundScore
+	s.initialized = state.Initialized
+	return nil
+}
+
 // AddFrame processes a frame and returns a RoundEnded event if detected
 func (s *RoundEndSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
 	if frame == nil || frame.GetSession() == nil {
@@ -192,6 +287,33 @@ func NewMatchEndSensor() *MatchEndSensor {
 	return &MatchEndSensor{}
 }
 
+// Reset clears the sensor state
+func (s *MatchEndSensor) Reset() {
+	*s = *NewMatchEndSensor()
+}
+
+// matchEndSensorState is the snapshot of a MatchEndSensor
+type matchEndSensorState struct {
+	PrevGameStatus string `json:"prev_game_status"`
+}
+
+// Snapshot serializes the sensor state
+func (s *MatchEndSensor) Snapshot() ([]byte, error) {
+	return json.Marshal(matchEndSensorState{
+		PrevGameStatus: s.prevGameStatus,
+	})
+}
+
+// Restore replaces the sensor state with a previous snapshot
+func (s *MatchEndSensor) Restore(data []byte) error {
+	var state matchEndSensorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	s.prevGameStatus = state.PrevGameStatus
+	return nil
+}
+
 // AddFrame processes a frame and returns a MatchEnded event if detected
 func (s *MatchEndSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
 	if frame == nil || frame.GetSession() == nil {
@@ -0,0 +1,218 @@
+package events
+
+import (
+	"encoding/json"
+
+	"github.com/echotools/nevr-capture/v3/pkg/arena"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// EventJoust is the result of the race to the disc when play starts after
+// a round start or a goal. Fields:
+//   - winner_slot, winner_team: the first player to take possession
+//   - time_to_disc: seconds from the start of play to the winner's touch
+//   - blue_fastest_slot, blue_fastest_time, orange_fastest_slot,
+//     orange_fastest_time: the first player of each team to reach the disc
+//     and when. A team that did not reach the disc reports the player
+//     closest to it with a time of -1.
+const EventJoust = "joust"
+
+// JoustConfig configures a JoustSensor
+type JoustConfig struct {
+	// ReachDistance is how close, in meters, a player must get to the disc
+	// to count as having reached it
+	ReachDistance float64 `json:"reach_distance"`
+	// Timeout is how many seconds a joust may last before it is abandoned
+	Timeout float64 `json:"timeout"`
+}
+
+// DefaultJoustConfig returns the default joust parameters
+func DefaultJoustConfig() JoustConfig {
+	return JoustConfig{ReachDistance: 1.5, Timeout: 15}
+}
+
+// JoustSensor measures jousts. A joust starts on the first playing frame
+// after a round_start and ends when a player takes possession of the disc.
+// Jousts interrupted by a pause or lasting longer than the timeout are not
+// reported.
+type JoustSensor struct {
+	customOnly
+	playerTracker
+	config JoustConfig
+
+	prevStatus string
+	active     bool
+	start      FrameTime
+	fastest    map[telemetry.Role]joustReach
+}
+
+// joustReach is the first player of a team to reach the disc
+type joustReach struct {
+	slot int32
+	time float64
+}
+
+var _ CustomEventSensor = (*JoustSensor)(nil)
+
+// NewJoustSensor creates a new JoustSensor
+func NewJoustSensor(cfg JoustConfig) *JoustSensor {
+	return &JoustSensor{playerTracker: newPlayerTracker(), config: cfg}
+}
+
+// Reset clears the sensor state
+func (s *JoustSensor) Reset() {
+	s.reset()
+	s.prevStatus = ""
+	s.active = false
+	s.fastest = nil
+}
+
+// joustSensorState is the snapshot of a JoustSensor
+type joustSensorState struct {
+	PrevStatus string                             `json:"prev_status,omitempty"`
+	Active     bool                               `json:"active"`
+	Start      FrameTime                          `json:"start"`
+	Fastest    map[telemetry.Role]joustReachState `json:"fastest,omitempty"`
+	Players    json.RawMessage                    `json:"players,omitempty"`
+}
+
+type joustReachState struct {
+	Slot int32   `json:"slot"`
+	Time float64 `json:"time"`
+}
+
+// Snapshot serializes the sensor state
+func (s *JoustSensor) Snapshot() ([]byte, error) {
+	players, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	state := joustSensorState{PrevStatus: s.prevStatus, Active: s.active, Start: s.start, Players: players}
+	if s.fastest != nil {
+		state.Fastest = make(map[telemetry.Role]joustReachState, len(s.fastest))
+		for role, reach := range s.fastest {
+			state.Fastest[role] = joustReachState{Slot: reach.slot, Time: reach.time}
+		}
+	}
+	return json.Marshal(state)
+}
+
+// Restore replaces the sensor state with a previous snapshot
+func (s *JoustSensor) Restore(data []byte) error {
+	var state joustSensorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	s.Reset()
+	s.prevStatus, s.active, s.start = state.PrevStatus, state.Active, state.Start
+	if state.Active {
+		s.fastest = make(map[telemetry.Role]joustReach, len(state.Fastest))
+		for role, reach := range state.Fastest {
+			s.fastest[role] = joustReach{slot: reach.Slot, time: reach.Time}
+		}
+	}
+	if state.Players != nil {
+		return s.restore(state.Players)
+	}
+	return nil
+}
+
+// DetectCustomEvents appends a joust event when a joust is decided
+func (s *JoustSensor) DetectCustomEvents(frame *telemetry.LobbySessionStateFrame, dst []*CustomEvent) []*CustomEvent {
+	if frame == nil || frame.GetSession() == nil {
+		return dst
+	}
+	session := frame.GetSession()
+	roster := s.observe(frame).Roster()
+
+	status := session.GetGameStatus()
+	prevStatus := s.prevStatus
+	s.prevStatus = status
+
+	if status != GameStatusPlaying {
+		s.active = false
+		return dst
+	}
+	now := FrameTimeOf(frame)
+	if prevStatus == GameStatusRoundStart {
+		s.active = true
+		s.start = now
+		s.fastest = make(map[telemetry.Role]joustReach, 2)
+	}
+	if !s.active {
+		return dst
+	}
+
+	elapsed := now.Since(s.start)
+	if elapsed > s.config.Timeout {
+		s.active = false
+		return dst
+	}
+
+	disc, hasDisc := arena.DiscPosition(session)
+	var winner *RosterPlayer
+	for _, player := range roster.Players() {
+		if !isTeamRole(player.Role) {
+			continue
+		}
+		reached := player.Member.GetHasPossession()
+		if pos, ok := arena.PlayerPosition(player.Member); ok && hasDisc && pos.Dist(disc) <= s.config.ReachDistance {
+			reached = true
+		}
+		if _, done := s.fastest[player.Role]; reached && !done {
+			s.fastest[player.Role] = joustReach{slot: player.Member.GetSlotNumber(), time: elapsed}
+		}
+		if player.Member.GetHasPossession() && winner == nil {
+			winner = &player
+		}
+	}
+	if winner == nil {
+		return dst
+	}
+
+	s.active = false
+	fields := map[string]any{
+		"winner_slot":  winner.Member.GetSlotNumber(),
+		"winner_team":  winner.Role.String(),
+		"time_to_disc": elapsed,
+	}
+	for _, team := range [...]struct {
+		role   telemetry.Role
+		prefix string
+	}{
+		{telemetry.Role_ROLE_BLUE_TEAM, "blue"},
+		{telemetry.Role_ROLE_ORANGE_TEAM, "orange"},
+	} {
+		reach, ok := s.fastest[team.role]
+		if !ok {
+			reach = joustReach{slot: closestToDisc(roster, team.role, disc), time: -1}
+		}
+		fields[team.prefix+"_fastest_slot"] = reach.slot
+		fields[team.prefix+"_fastest_time"] = reach.time
+	}
+	return append(dst, &CustomEvent{Name: EventJoust, Fields: fields})
+}
+
+// isTeamRole reports whether role is one of the two playing teams
+func isTeamRole(role telemetry.Role) bool {
+	return role == telemetry.Role_ROLE_BLUE_TEAM || role == telemetry.Role_ROLE_ORANGE_TEAM
+}
+
+// closestToDisc returns the slot of the team's player closest to the disc,
+// or -1 if the team has no positioned players
+func closestToDisc(roster *Roster, role telemetry.Role, disc arena.Vec3) int32 {
+	slot, best := int32(-1), 0.0
+	for _, player := range roster.Players() {
+		if player.Role != role {
+			continue
+		}
+		pos, ok := arena.PlayerPosition(player.Member)
+		if !ok {
+			continue
+		}
+		if d := pos.Dist(disc); slot == -1 || d < best {
+			slot, best = player.Member.GetSlotNumber(), d
+		}
+	}
+	return slot
+}
@@ -0,0 +1,103 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// joustPlayer places a player at z meters from the disc at the center
+func joustPlayer(slot int32, z float64, possession bool) *apigame.TeamMember {
+	return &apigame.TeamMember{
+		SlotNumber:    slot,
+		DisplayName:   "P" + string(rune('0'+slot)),
+		Body:          &apigame.BodyPart{Position: []float64{0, 0, z}},
+		HasPossession: possession,
+	}
+}
+
+func joustFrame(status string, at time.Duration, blue, orange []*apigame.TeamMember) *telemetry.LobbySessionStateFrame {
+	frame := createFrameWithTeams(blue, orange, nil)
+	frame.Timestamp = timestamppb.New(bonesEpoch.Add(at))
+	frame.Session.GameStatus = status
+	frame.Session.Disc = &apigame.Disc{Position: []float64{0, 0, 0}}
+	return frame
+}
+
+func TestJoustSensor_ReportsWinnerAndFastestPlayers(t *testing.T) {
+	sensor := NewJoustSensor(DefaultJoustConfig())
+	second := time.Second
+
+	frames := []*telemetry.LobbySessionStateFrame{
+		joustFrame(GameStatusRoundStart, 0, []*apigame.TeamMember{joustPlayer(0, -30, false)}, []*apigame.TeamMember{joustPlayer(1, 30, false)}),
+		joustFrame(GameStatusPlaying, second, []*apigame.TeamMember{joustPlayer(0, -20, false)}, []*apigame.TeamMember{joustPlayer(1, 20, false)}),
+		// Orange reaches the disc first, but blue grabs it
+		joustFrame(GameStatusPlaying, 3*second, []*apigame.TeamMember{joustPlayer(0, -5, false)}, []*apigame.TeamMember{joustPlayer(1, 1, false)}),
+		joustFrame(GameStatusPlaying, 4*second, []*apigame.TeamMember{joustPlayer(0, 0, true)}, []*apigame.TeamMember{joustPlayer(1, 1, false)}),
+	}
+	var events []*CustomEvent
+	for _, frame := range frames {
+		events = sensor.DetectCustomEvents(frame, events)
+	}
+
+	if len(events) != 1 || events[0].Name != EventJoust {
+		t.Fatalf("expected 1 joust, got %v", events)
+	}
+	want := map[string]any{
+		"winner_slot":         int32(0),
+		"winner_team":         telemetry.Role_ROLE_BLUE_TEAM.String(),
+		"time_to_disc":        3.0,
+		"blue_fastest_slot":   int32(0),
+		"blue_fastest_time":   3.0,
+		"orange_fastest_slot": int32(1),
+		"orange_fastest_time": 2.0,
+	}
+	for k, v := range want {
+		if got := events[0].Fields[k]; got != v {
+			t.Errorf("%s: expected %v, got %v", k, v, got)
+		}
+	}
+}
+
+func TestJoustSensor_TeamThatNeverReached(t *testing.T) {
+	sensor := NewJoustSensor(DefaultJoustConfig())
+
+	sensor.DetectCustomEvents(joustFrame(GameStatusRoundStart, 0,
+		[]*apigame.TeamMember{joustPlayer(0, -30, false)},
+		[]*apigame.TeamMember{joustPlayer(1, 30, false), joustPlayer(2, 25, false)}), nil)
+	events := sensor.DetectCustomEvents(joustFrame(GameStatusPlaying, time.Second,
+		[]*apigame.TeamMember{joustPlayer(0, 0, true)},
+		[]*apigame.TeamMember{joustPlayer(1, 10, false), joustPlayer(2, 8, false)}), nil)
+
+	if len(events) != 1 {
+		t.Fatalf("expected 1 joust, got %v", events)
+	}
+	if got := events[0].Fields["orange_fastest_slot"]; got != int32(2) {
+		t.Errorf("expected closest orange player 2, got %v", got)
+	}
+	if got := events[0].Fields["orange_fastest_time"]; got != -1.0 {
+		t.Errorf("expected -1 for a team that did not reach the disc, got %v", got)
+	}
+}
+
+func TestJoustSensor_IgnoresPlayWithoutRoundStart(t *testing.T) {
+	sensor := NewJoustSensor(DefaultJoustConfig())
+
+	sensor.DetectCustomEvents(joustFrame(GameStatusPlaying, 0, []*apigame.TeamMember{joustPlayer(0, -5, false)}, nil), nil)
+	if events := sensor.DetectCustomEvents(joustFrame(GameStatusPlaying, time.Second, []*apigame.TeamMember{joustPlayer(0, 0, true)}, nil), nil); len(events) != 0 {
+		t.Errorf("expected no joust outside of a round start, got %v", events)
+	}
+}
+
+func TestJoustSensor_Timeout(t *testing.T) {
+	sensor := NewJoustSensor(JoustConfig{ReachDistance: 1, Timeout: 5})
+
+	sensor.DetectCustomEvents(joustFrame(GameStatusRoundStart, 0, []*apigame.TeamMember{joustPlayer(0, -30, false)}, nil), nil)
+	sensor.DetectCustomEvents(joustFrame(GameStatusPlaying, time.Second, []*apigame.TeamMember{joustPlayer(0, -20, false)}, nil), nil)
+	if events := sensor.DetectCustomEvents(joustFrame(GameStatusPlaying, 10*time.Second, []*apigame.TeamMember{joustPlayer(0, 0, true)}, nil), nil); len(events) != 0 {
+		t.Errorf("expected timed out joust not to be reported, got %v", events)
+	}
+}
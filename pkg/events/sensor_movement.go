@@ -0,0 +1,304 @@
+package events
+
+import (
+	"cmp"
+	"encoding/json"
+	"slices"
+	"strconv"
+
+	"github.com/echotools/nevr-capture/v3/pkg/arena"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// EventMovementSummary summarizes a player's movement so far. Fields:
+// player_slot, team, final (true for the summary at match end), time
+// (seconds of play tracked), distance (meters), top_speed and average_speed
+// (m/s), time_above (map of threshold in m/s, formatted as a string, to
+// seconds spent above it) and boosts.
+const EventMovementSummary = "movement_summary"
+
+// MovementConfig configures a MovementSensor
+type MovementConfig struct {
+	// SpeedThresholds are the speeds, in m/s, for which time above is
+	// tracked
+	SpeedThresholds []float64 `json:"speed_thresholds"`
+	// BoostAcceleration is the acceleration, in m/s², above which a speed
+	// increase counts as a boost
+	BoostAcceleration float64 `json:"boost_acceleration"`
+	// SummaryInterval is the number of seconds of play between periodic
+	// summaries. Zero only reports the summary at match end.
+	SummaryInterval float64 `json:"summary_interval"`
+}
+
+// DefaultMovementConfig returns the default movement thresholds
+func DefaultMovementConfig() MovementConfig {
+	return MovementConfig{
+		SpeedThresholds:   []float64{3, 5, 7},
+		BoostAcceleration: 12,
+		SummaryInterval:   60,
+	}
+}
+
+// MovementSensor accumulates distance travelled, top speed, time spent
+// above speed thresholds and boosts for every player while the game is
+// playing. Boosts are inferred from sudden speed increases.
+type MovementSensor struct {
+	customOnly
+	playerTracker
+	config MovementConfig
+
+	players     map[PlayerKey]*movementStats
+	prevTime    FrameTime
+	hasPrev     bool
+	prevStatus  string
+	sinceReport float64
+}
+
+// movementStats is the movement of one player
+type movementStats struct {
+	player    RosterPlayer
+	time      float64
+	distance  float64
+	topSpeed  float64
+	timeAbove []float64 // by threshold
+	boosts    int
+	prevVel   arena.Vec3
+	hasVel    bool
+	boosting  bool
+}
+
+var _ CustomEventSensor = (*MovementSensor)(nil)
+
+// NewMovementSensor creates a new MovementSensor
+func NewMovementSensor(cfg MovementConfig) *MovementSensor {
+	return &MovementSensor{
+		playerTracker: newPlayerTracker(),
+		config:        cfg,
+		players:       make(map[PlayerKey]*movementStats),
+	}
+}
+
+// Reset clears the sensor state
+func (s *MovementSensor) Reset() {
+	s.reset()
+	s.players = make(map[PlayerKey]*movementStats)
+	s.hasPrev = false
+	s.prevStatus = ""
+	s.sinceReport = 0
+}
+
+// movementSensorState is the snapshot of a MovementSensor
+type movementSensorState struct {
+	Stats       []movementStatsState `json:"stats"`
+	PrevTime    FrameTime            `json:"prev_time"`
+	HasPrev     bool                 `json:"has_prev"`
+	PrevStatus  string               `json:"prev_status,omitempty"`
+	SinceReport float64              `json:"since_report"`
+	Players     json.RawMessage      `json:"players,omitempty"`
+}
+
+type movementStatsState struct {
+	Player    rosterPlayerState `json:"player"`
+	Time      float64           `json:"time"`
+	Distance  float64           `json:"distance"`
+	TopSpeed  float64           `json:"top_speed"`
+	TimeAbove []float64         `json:"time_above"`
+	Boosts    int               `json:"boosts"`
+	PrevVel   arena.Vec3        `json:"prev_vel"`
+	HasVel    bool              `json:"has_vel"`
+	Boosting  bool              `json:"boosting"`
+}
+
+// Snapshot serializes the sensor state
+func (s *MovementSensor) Snapshot() ([]byte, error) {
+	players, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	state := movementSensorState{
+		Stats:       make([]movementStatsState, 0, len(s.players)),
+		PrevTime:    s.prevTime,
+		HasPrev:     s.hasPrev,
+		PrevStatus:  s.prevStatus,
+		SinceReport: s.sinceReport,
+		Players:     players,
+	}
+	for _, m := range sortedMovement(s.players) {
+		player, err := snapshotRosterPlayer(m.player)
+		if err != nil {
+			return nil, err
+		}
+		state.Stats = append(state.Stats, movementStatsState{
+			Player:    player,
+			Time:      m.time,
+			Distance:  m.distance,
+			TopSpeed:  m.topSpeed,
+			TimeAbove: m.timeAbove,
+			Boosts:    m.boosts,
+			PrevVel:   m.prevVel,
+			HasVel:    m.hasVel,
+			Boosting:  m.boosting,
+		})
+	}
+	return json.Marshal(state)
+}
+
+// Restore replaces the sensor state with a previous snapshot
+func (s *MovementSensor) Restore(data []byte) error {
+	var state movementSensorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	s.Reset()
+	for _, m := range state.Stats {
+		player, err := restoreRosterPlayer(m.Player)
+		if err != nil {
+			return err
+		}
+		// Sized by the current thresholds so a mismatched snapshot cannot
+		// index out of range
+		timeAbove := make([]float64, len(s.config.SpeedThresholds))
+		copy(timeAbove, m.TimeAbove)
+		s.players[player.Key] = &movementStats{
+			player:    player,
+			time:      m.Time,
+			distance:  m.Distance,
+			topSpeed:  m.TopSpeed,
+			timeAbove: timeAbove,
+			boosts:    m.Boosts,
+			prevVel:   m.PrevVel,
+			hasVel:    m.HasVel,
+			boosting:  m.Boosting,
+		}
+	}
+	s.prevTime, s.hasPrev = state.PrevTime, state.HasPrev
+	s.prevStatus, s.sinceReport = state.PrevStatus, state.SinceReport
+	if state.Players != nil {
+		return s.restore(state.Players)
+	}
+	return nil
+}
+
+// DetectCustomEvents accumulates the frame's movement and appends summaries
+// when one is due
+func (s *MovementSensor) DetectCustomEvents(frame *telemetry.LobbySessionStateFrame, dst []*CustomEvent) []*CustomEvent {
+	if frame == nil || frame.GetSession() == nil {
+		return dst
+	}
+	roster := s.observe(frame).Roster()
+	status := frame.GetSession().GetGameStatus()
+	prevStatus := s.prevStatus
+	s.prevStatus = status
+
+	if status == GameStatusPostMatch && prevStatus != GameStatusPostMatch && prevStatus != "" {
+		dst = s.appendSummaries(dst, true)
+		s.players = make(map[PlayerKey]*movementStats)
+		s.sinceReport = 0
+	}
+
+	now := FrameTimeOf(frame)
+	dt := now.Since(s.prevTime)
+	if !s.hasPrev || dt <= 0 || dt > maxFrameGap {
+		dt = 0
+	}
+	s.prevTime, s.hasPrev = now, true
+	if status != GameStatusPlaying {
+		// Velocities across a stoppage would look like boosts
+		for _, m := range s.players {
+			m.hasVel = false
+		}
+		return dst
+	}
+
+	for _, player := range roster.Players() {
+		if !isTeamRole(player.Role) {
+			continue
+		}
+		vel, ok := arena.PlayerVelocity(player.Member)
+		if !ok {
+			continue
+		}
+		m := s.players[player.Key]
+		if m == nil {
+			m = &movementStats{timeAbove: make([]float64, len(s.config.SpeedThresholds))}
+			s.players[player.Key] = m
+		}
+		m.player = player
+		s.accumulate(m, vel, dt)
+	}
+
+	if s.sinceReport += dt; s.config.SummaryInterval > 0 && s.sinceReport >= s.config.SummaryInterval {
+		s.sinceReport -= s.config.SummaryInterval
+		dst = s.appendSummaries(dst, false)
+	}
+	return dst
+}
+
+// accumulate adds dt seconds at velocity vel to a player's movement
+func (s *MovementSensor) accumulate(m *movementStats, vel arena.Vec3, dt float64) {
+	speed := vel.Len()
+	m.topSpeed = max(m.topSpeed, speed)
+	if dt > 0 {
+		m.time += dt
+		m.distance += speed * dt
+		for i, threshold := range s.config.SpeedThresholds {
+			if speed > threshold {
+				m.timeAbove[i] += dt
+			}
+		}
+		if m.hasVel {
+			accel := vel.Sub(m.prevVel).Len() / dt
+			boosting := accel >= s.config.BoostAcceleration && speed > m.prevVel.Len()
+			if boosting && !m.boosting {
+				m.boosts++
+			}
+			m.boosting = boosting
+		}
+	}
+	m.prevVel, m.hasVel = vel, true
+}
+
+// appendSummaries appends a movement summary for every tracked player in
+// slot order
+func (s *MovementSensor) appendSummaries(dst []*CustomEvent, final bool) []*CustomEvent {
+	for _, m := range sortedMovement(s.players) {
+		timeAbove := make(map[string]float64, len(m.timeAbove))
+		for i, threshold := range s.config.SpeedThresholds {
+			timeAbove[strconv.FormatFloat(threshold, 'f', -1, 64)] = m.timeAbove[i]
+		}
+		average := 0.0
+		if m.time > 0 {
+			average = m.distance / m.time
+		}
+		dst = append(dst, &CustomEvent{
+			Name: EventMovementSummary,
+			Fields: map[string]any{
+				"player_slot":   m.player.Member.GetSlotNumber(),
+				"team":          m.player.Role.String(),
+				"final":         final,
+				"time":          m.time,
+				"distance":      m.distance,
+				"top_speed":     m.topSpeed,
+				"average_speed": average,
+				"time_above":    timeAbove,
+				"boosts":        m.boosts,
+			},
+		})
+	}
+	return dst
+}
+
+// sortedMovement returns the tracked players ordered by slot
+func sortedMovement(players map[PlayerKey]*movementStats) []*movementStats {
+	out := make([]*movementStats, 0, len(players))
+	for _, m := range players {
+		out = append(out, m)
+	}
+	slices.SortFunc(out, func(a, b *movementStats) int {
+		return cmp.Or(
+			cmp.Compare(a.player.Member.GetSlotNumber(), b.player.Member.GetSlotNumber()),
+			comparePlayerKeys(a.player.Key, b.player.Key),
+		)
+	})
+	return out
+}
@@ -0,0 +1,106 @@
+package events
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func movementFrame(at time.Duration, status string, blueVel, orangeVel float64) *telemetry.LobbySessionStateFrame {
+	frame := createFrameWithTeams(
+		[]*apigame.TeamMember{{SlotNumber: 0, DisplayName: "Blue", Velocity: []float64{blueVel, 0, 0}}},
+		[]*apigame.TeamMember{{SlotNumber: 1, DisplayName: "Orange", Velocity: []float64{0, 0, orangeVel}}},
+		nil)
+	frame.Timestamp = timestamppb.New(bonesEpoch.Add(at))
+	frame.Session.GameStatus = status
+	return frame
+}
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestMovementSensor_FinalSummary(t *testing.T) {
+	sensor := NewMovementSensor(MovementConfig{SpeedThresholds: []float64{3, 5}, BoostAcceleration: 12})
+
+	// Blue cruises at 4 m/s for a second, then boosts to 8 m/s for a second
+	var at time.Duration
+	for i := range 20 {
+		blue := 4.0
+		if i >= 10 {
+			blue = 8
+		}
+		if events := sensor.DetectCustomEvents(movementFrame(at, GameStatusPlaying, blue, 2), nil); len(events) != 0 {
+			t.Fatalf("unexpected summary with periodic summaries disabled: %v", events)
+		}
+		at += 100 * time.Millisecond
+	}
+	events := sensor.DetectCustomEvents(movementFrame(at, GameStatusPostMatch, 0, 0), nil)
+
+	if len(events) != 2 {
+		t.Fatalf("expected a final summary per player, got %v", events)
+	}
+	blue := events[0].Fields
+	if blue["player_slot"] != int32(0) || blue["final"] != true {
+		t.Fatalf("expected blue's final summary first, got %v", blue)
+	}
+	// 19 intervals: 9 at 4 m/s and 10 at 8 m/s
+	if !approx(blue["time"].(float64), 1.9) || !approx(blue["distance"].(float64), 9*0.4+10*0.8) {
+		t.Errorf("unexpected time/distance: %v/%v", blue["time"], blue["distance"])
+	}
+	if blue["top_speed"] != 8.0 || blue["boosts"] != 1 {
+		t.Errorf("expected top speed 8 and 1 boost, got %v and %v", blue["top_speed"], blue["boosts"])
+	}
+	above := blue["time_above"].(map[string]float64)
+	if !approx(above["3"], 1.9) || !approx(above["5"], 1.0) {
+		t.Errorf("unexpected time above thresholds: %v", above)
+	}
+
+	orange := events[1].Fields
+	if orange["boosts"] != 0 || !approx(orange["average_speed"].(float64), 2) {
+		t.Errorf("expected a steady orange player, got %v", orange)
+	}
+}
+
+func TestMovementSensor_PeriodicSummaries(t *testing.T) {
+	cfg := DefaultMovementConfig()
+	cfg.SummaryInterval = 1
+	sensor := NewMovementSensor(cfg)
+
+	var summaries int
+	var at time.Duration
+	for range 35 {
+		for _, e := range sensor.DetectCustomEvents(movementFrame(at, GameStatusPlaying, 1, 1), nil) {
+			if e.Fields["final"] != false {
+				t.Fatalf("expected periodic summary, got %v", e.Fields)
+			}
+			summaries++
+		}
+		at += 100 * time.Millisecond
+	}
+	if summaries != 6 {
+		t.Errorf("expected 3 summaries per player, got %d", summaries)
+	}
+}
+
+func TestMovementSensor_IgnoresStoppages(t *testing.T) {
+	sensor := NewMovementSensor(DefaultMovementConfig())
+
+	sensor.DetectCustomEvents(movementFrame(0, GameStatusPlaying, 1, 1), nil)
+	sensor.DetectCustomEvents(movementFrame(100*time.Millisecond, GameStatusPaused, 0, 0), nil)
+	// Resuming at speed after a pause is not a boost, and the gap is not
+	// counted as movement
+	sensor.DetectCustomEvents(movementFrame(10*time.Second, GameStatusPlaying, 8, 8), nil)
+	events := sensor.DetectCustomEvents(movementFrame(10*time.Second+100*time.Millisecond, GameStatusPostMatch, 0, 0), nil)
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 final summaries, got %v", events)
+	}
+	if f := events[0].Fields; f["boosts"] != 0 || f["time"] != 0.0 || f["top_speed"] != 8.0 {
+		t.Errorf("expected no movement across the pause, got %v", f)
+	}
+}
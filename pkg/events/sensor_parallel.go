@@ -0,0 +1,46 @@
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// detectSensorsParallel runs the sensors on up to workers goroutines, the
+// calling goroutine included, and appends their events to dst and custom in
+// sensor order
+func detectSensorsParallel(frame *telemetry.LobbySessionStateFrame, entries []*sensorEntry, workers int, dst []*telemetry.LobbySessionEvent, custom []*CustomEvent) ([]*telemetry.LobbySessionEvent, []*CustomEvent) {
+	var next atomic.Int64
+	run := func() {
+		for {
+			i := int(next.Add(1)) - 1
+			if i >= len(entries) {
+				return
+			}
+			e := entries[i]
+			start := time.Now()
+			e.buf = e.sensor.DetectEvents(frame, e.buf[:0])
+			if e.custom != nil {
+				e.customBuf = e.custom.DetectCustomEvents(frame, e.customBuf[:0])
+			}
+			e.record(time.Since(start))
+		}
+	}
+
+	var wg sync.WaitGroup
+	for range min(workers, len(entries)) - 1 {
+		wg.Go(run)
+	}
+	run()
+	wg.Wait()
+
+	for _, e := range entries {
+		dst = append(dst, e.buf...)
+		clear(e.buf)
+		custom = append(custom, e.customBuf...)
+		clear(e.customBuf)
+	}
+	return dst, custom
+}
@@ -0,0 +1,89 @@
+package events
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// orderedSensor reports events tagged with its slot after a delay, so
+// sensors finish out of registration order
+type orderedSensor struct {
+	slot  int32
+	count int
+	delay time.Duration
+}
+
+func (s *orderedSensor) DetectEvents(frame *telemetry.LobbySessionStateFrame, dst []*telemetry.LobbySessionEvent) []*telemetry.LobbySessionEvent {
+	time.Sleep(s.delay)
+	for range s.count {
+		dst = append(dst, &telemetry.LobbySessionEvent{
+			Event: &telemetry.LobbySessionEvent_PlayerGoal{
+				PlayerGoal: &telemetry.PlayerGoal{PlayerSlot: s.slot},
+			},
+		})
+	}
+	return dst
+}
+
+func TestAsyncDetector_ParallelSensorsPreserveOrder(t *testing.T) {
+	detector := New(
+		WithParallelSensors(4),
+		WithEventSensors(
+			&orderedSensor{slot: 0, count: 2, delay: 3 * time.Millisecond},
+			&orderedSensor{slot: 1, count: 0, delay: 2 * time.Millisecond},
+			&orderedSensor{slot: 2, count: 1, delay: time.Millisecond},
+			&orderedSensor{slot: 3, count: 3},
+		),
+	)
+	defer detector.Stop()
+
+	want := []int32{0, 0, 2, 3, 3, 3}
+	for range 3 {
+		events, _ := detector.Detect(newStatusOnlyFrame(GameStatusPlaying))
+		if len(events) != len(want) {
+			t.Fatalf("expected %d events, got %d", len(want), len(events))
+		}
+		for i, event := range events {
+			if got := event.GetPlayerGoal().GetPlayerSlot(); got != want[i] {
+				t.Fatalf("event %d: expected slot %d, got %d", i, want[i], got)
+			}
+		}
+	}
+
+	for _, s := range detector.Stats().Sensors {
+		if s.Frames != 3 {
+			t.Errorf("%s: expected 3 frames, got %d", s.Type, s.Frames)
+		}
+	}
+}
+
+func TestAsyncDetector_ParallelSensorsMatchSequential(t *testing.T) {
+	sequential := NewWithDefaultSensors(WithSynchronousProcessing())
+	defer sequential.Stop()
+	parallel := NewWithDefaultSensors(WithSynchronousProcessing(), WithParallelSensors(4))
+	defer parallel.Stop()
+
+	frames := []*telemetry.LobbySessionStateFrame{
+		createFrameWithPlayers(createPlayer(0, "Alice", 1)),
+		createFrameWithPlayers(createPlayer(0, "Alice", 1), createPlayer(1, "Bob", 2)),
+		createFrameWithPlayers(createPlayer(1, "Bob", 2)),
+		newStatusOnlyFrame(GameStatusRoundOver),
+		newStatusOnlyFrame(GameStatusPostMatch),
+	}
+
+	for i, frame := range frames {
+		want, _ := sequential.Detect(frame)
+		got, _ := parallel.Detect(frame)
+		if len(got) != len(want) {
+			t.Fatalf("frame %d: expected %d events, got %d", i, len(want), len(got))
+		}
+		for j := range want {
+			if wantType, gotType := fmt.Sprintf("%T", want[j].Event), fmt.Sprintf("%T", got[j].Event); wantType != gotType {
+				t.Errorf("frame %d event %d: expected %s, got %s", i, j, wantType, gotType)
+			}
+		}
+	}
+}
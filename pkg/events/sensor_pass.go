@@ -0,0 +1,255 @@
+package events
+
+import (
+	"encoding/json"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// EventPass is a classified release of the disc. Fields: outcome (one of
+// the PassOutcome constants), thrower_slot, receiver_slot (-1 if nobody
+// took the disc), team, air_time (seconds in flight), distance (meters),
+// thrown (whether the game registered a throw), throw_speed (m/s) and, for
+// thrown passes, throw_frame, the FrameIndex of the DiscThrown event.
+const EventPass = "pass"
+
+// Outcomes of a release
+const (
+	// PassCompleted is a pass caught by a teammate
+	PassCompleted = "completed"
+	// PassIntercepted is a pass caught in flight by an opponent
+	PassIntercepted = "intercepted"
+	// PassSelfRegrab is the thrower taking the disc back
+	PassSelfRegrab = "self_regrab"
+	// PassTurnover is a disc that came to rest and was picked up by an
+	// opponent
+	PassTurnover = "turnover"
+	// PassRecovered is a disc that came to rest and was picked up by the
+	// thrower's team
+	PassRecovered = "recovered"
+	// PassUncaught is a release nobody took before play stopped
+	PassUncaught = "uncaught"
+)
+
+// PassSensor classifies every release of the disc by combining the flights
+// of a DiscTracker with the team of the thrower and of the player who takes
+// the disc next. Flights ending in a goal are shots and are not reported.
+type PassSensor struct {
+	customOnly
+	playerTracker
+	tracker *DiscTracker
+
+	release *passRelease
+	loose   *passRelease // released disc that came to rest
+}
+
+// passRelease is a release whose outcome is not known yet
+type passRelease struct {
+	thrower    RosterPlayer
+	thrown     bool
+	throwFrame uint32
+	throwSpeed float64
+	airTime    float64
+	distance   float64
+}
+
+var _ CustomEventSensor = (*PassSensor)(nil)
+
+// NewPassSensor creates a new PassSensor following the disc with tracker,
+// which may be shared with a DiscTrajectorySensor
+func NewPassSensor(tracker *DiscTracker) *PassSensor {
+	return &PassSensor{playerTracker: newPlayerTracker(), tracker: tracker}
+}
+
+// Reset clears the sensor state
+func (s *PassSensor) Reset() {
+	s.reset()
+	s.tracker.Reset()
+	s.release = nil
+	s.loose = nil
+}
+
+// passSensorState is the snapshot of a PassSensor
+type passSensorState struct {
+	Tracker json.RawMessage   `json:"tracker"`
+	Release *passReleaseState `json:"release,omitempty"`
+	Loose   *passReleaseState `json:"loose,omitempty"`
+	Players json.RawMessage   `json:"players,omitempty"`
+}
+
+type passReleaseState struct {
+	Thrower    rosterPlayerState `json:"thrower"`
+	Thrown     bool              `json:"thrown"`
+	ThrowFrame uint32            `json:"throw_frame,omitempty"`
+	ThrowSpeed float64           `json:"throw_speed"`
+	AirTime    float64           `json:"air_time"`
+	Distance   float64           `json:"distance"`
+}
+
+func snapshotPassRelease(r *passRelease) (*passReleaseState, error) {
+	if r == nil {
+		return nil, nil
+	}
+	thrower, err := snapshotRosterPlayer(r.thrower)
+	if err != nil {
+		return nil, err
+	}
+	return &passReleaseState{
+		Thrower:    thrower,
+		Thrown:     r.thrown,
+		ThrowFrame: r.throwFrame,
+		ThrowSpeed: r.throwSpeed,
+		AirTime:    r.airTime,
+		Distance:   r.distance,
+	}, nil
+}
+
+func restorePassRelease(state *passReleaseState) (*passRelease, error) {
+	if state == nil {
+		return nil, nil
+	}
+	thrower, err := restoreRosterPlayer(state.Thrower)
+	if err != nil {
+		return nil, err
+	}
+	return &passRelease{
+		thrower:    thrower,
+		thrown:     state.Thrown,
+		throwFrame: state.ThrowFrame,
+		throwSpeed: state.ThrowSpeed,
+		airTime:    state.AirTime,
+		distance:   state.Distance,
+	}, nil
+}
+
+// Snapshot serializes the sensor state
+func (s *PassSensor) Snapshot() ([]byte, error) {
+	var state passSensorState
+	var err error
+	if state.Tracker, err = s.tracker.Snapshot(); err != nil {
+		return nil, err
+	}
+	if state.Release, err = snapshotPassRelease(s.release); err != nil {
+		return nil, err
+	}
+	if state.Loose, err = snapshotPassRelease(s.loose); err != nil {
+		return nil, err
+	}
+	if state.Players, err = s.snapshot(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(state)
+}
+
+// Restore replaces the sensor state with a previous snapshot
+func (s *PassSensor) Restore(data []byte) error {
+	var state passSensorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	release, err := restorePassRelease(state.Release)
+	if err != nil {
+		return err
+	}
+	loose, err := restorePassRelease(state.Loose)
+	if err != nil {
+		return err
+	}
+
+	s.Reset()
+	if err := s.tracker.Restore(state.Tracker); err != nil {
+		return err
+	}
+	s.release, s.loose = release, loose
+	if state.Players != nil {
+		return s.restore(state.Players)
+	}
+	return nil
+}
+
+// DetectCustomEvents appends a pass event for every release decided in the
+// frame
+func (s *PassSensor) DetectCustomEvents(frame *telemetry.LobbySessionStateFrame, dst []*CustomEvent) []*CustomEvent {
+	if frame == nil || frame.GetSession() == nil {
+		return dst
+	}
+	session := frame.GetSession()
+	roster := s.observe(frame).Roster()
+	r := s.tracker.Update(frame)
+
+	if r.Started {
+		s.release = nil
+		if thrower, ok := roster.BySlot(r.ThrowerSlot); ok && r.ThrowerSlot != -1 {
+			s.release = &passRelease{thrower: thrower}
+		}
+	}
+
+	if session.GetGameStatus() != GameStatusPlaying && s.loose != nil {
+		dst = appendPass(dst, s.loose, roster, -1, true)
+		s.loose = nil
+	}
+	if holder := findPossessorSlot(session); holder != -1 && s.loose != nil {
+		// A disc that came to rest was picked up
+		dst = appendPass(dst, s.loose, roster, holder, true)
+		s.loose = nil
+	}
+
+	f := r.Completed
+	if f == nil || s.release == nil {
+		return dst
+	}
+	release := s.release
+	s.release = nil
+	release.airTime += f.Duration
+	release.distance += f.Distance
+	release.thrown, release.throwFrame, release.throwSpeed = f.Thrown, f.ThrowFrame, f.ThrowSpeed
+
+	switch f.EndedBy {
+	case FlightEndCaught:
+		dst = appendPass(dst, release, roster, f.CatcherSlot, false)
+	case FlightEndRest:
+		s.loose = release
+	case FlightEndInterrupted:
+		dst = appendPass(dst, release, roster, -1, false)
+	}
+	return dst
+}
+
+// appendPass classifies a release taken by the player in slot receiver, or
+// by nobody if receiver is -1. loose is set when the disc came to rest
+// before it was taken.
+func appendPass(dst []*CustomEvent, release *passRelease, roster *Roster, receiver int32, loose bool) []*CustomEvent {
+	thrower := release.thrower
+	catcher, _ := roster.BySlot(receiver)
+
+	var outcome string
+	switch {
+	case receiver == -1:
+		outcome = PassUncaught
+	case loose && catcher.Role == thrower.Role:
+		outcome = PassRecovered
+	case loose:
+		outcome = PassTurnover
+	case catcher.Key == thrower.Key:
+		outcome = PassSelfRegrab
+	case catcher.Role == thrower.Role:
+		outcome = PassCompleted
+	default:
+		outcome = PassIntercepted
+	}
+
+	fields := map[string]any{
+		"outcome":       outcome,
+		"thrower_slot":  thrower.Member.GetSlotNumber(),
+		"receiver_slot": receiver,
+		"team":          thrower.Role.String(),
+		"air_time":      release.airTime,
+		"distance":      release.distance,
+		"thrown":        release.thrown,
+		"throw_speed":   release.throwSpeed,
+	}
+	if release.thrown {
+		fields["throw_frame"] = release.throwFrame
+	}
+	return append(dst, &CustomEvent{Name: EventPass, Fields: fields})
+}
@@ -0,0 +1,100 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/echotools/nevr-capture/v3/pkg/arena"
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// passFrame is a discFrame with a second blue player in slot 2
+func passFrame(i uint32, d discState, lastThrow *apigame.LastThrowInfo) *telemetry.LobbySessionStateFrame {
+	frame := discFrame(i, d)
+	frame.Session.Teams[0].Players = append(frame.Session.Teams[0].Players, &apigame.TeamMember{
+		SlotNumber:    2,
+		DisplayName:   "P2",
+		HasPossession: d.holder == 2,
+	})
+	frame.Session.LastThrow = lastThrow
+	return frame
+}
+
+// runPass throws the disc from slot 0 and lets the given frames play out
+func runPass(t *testing.T, tail ...discState) []*CustomEvent {
+	t.Helper()
+	sensor := NewPassSensor(NewDiscTracker(DefaultDiscTrackerConfig()))
+	throw := &apigame.LastThrowInfo{TotalSpeed: 12}
+
+	events := sensor.DetectCustomEvents(passFrame(0, discState{holder: 0}, nil), nil)
+	events = sensor.DetectCustomEvents(passFrame(1, discState{vel: arena.Vec3{Z: 12}, holder: -1}, throw), events)
+	for i, d := range tail {
+		events = sensor.DetectCustomEvents(passFrame(uint32(i+2), d, throw), events)
+	}
+	return events
+}
+
+func TestPassSensor_Outcomes(t *testing.T) {
+	flying := discState{vel: arena.Vec3{Z: 12}, holder: -1}
+	still := discState{holder: -1}
+
+	tests := []struct {
+		name     string
+		tail     []discState
+		outcome  string
+		receiver int32
+	}{
+		{"completed", []discState{flying, {holder: 2}}, PassCompleted, 2},
+		{"intercepted", []discState{flying, {holder: 1}}, PassIntercepted, 1},
+		{"self regrab", []discState{flying, {holder: 0}}, PassSelfRegrab, 0},
+		{"turnover", append(repeatDisc(still, 12), discState{holder: 1}), PassTurnover, 1},
+		{"recovered", append(repeatDisc(still, 12), discState{holder: 2}), PassRecovered, 2},
+		{"uncaught in flight", []discState{flying, {status: GameStatusRoundOver, holder: -1}}, PassUncaught, -1},
+		{"uncaught at rest", append(repeatDisc(still, 12), discState{status: GameStatusRoundOver, holder: -1}), PassUncaught, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := runPass(t, tt.tail...)
+			if len(events) != 1 || events[0].Name != EventPass {
+				t.Fatalf("expected 1 pass, got %v", events)
+			}
+			fields := events[0].Fields
+			if fields["outcome"] != tt.outcome || fields["receiver_slot"] != tt.receiver || fields["thrower_slot"] != int32(0) {
+				t.Errorf("unexpected pass %v", fields)
+			}
+			if fields["thrown"] != true || fields["throw_speed"] != 12.0 {
+				t.Errorf("expected the registered throw, got %v", fields)
+			}
+			if fields["team"] != telemetry.Role_ROLE_BLUE_TEAM.String() {
+				t.Errorf("expected blue thrower, got %v", fields["team"])
+			}
+		})
+	}
+}
+
+func TestPassSensor_AirTime(t *testing.T) {
+	flying := discState{vel: arena.Vec3{Z: 12}, holder: -1}
+	events := runPass(t, flying, flying, flying, discState{holder: 2})
+
+	if len(events) != 1 {
+		t.Fatalf("expected 1 pass, got %v", events)
+	}
+	if airTime := events[0].Fields["air_time"].(float64); airTime < 0.399 || airTime > 0.401 {
+		t.Errorf("expected 0.4s air time, got %f", airTime)
+	}
+}
+
+func TestPassSensor_GoalIsNotAPass(t *testing.T) {
+	events := runPass(t, discState{vel: arena.Vec3{Z: 12}, holder: -1}, discState{status: GameStatusScore, holder: -1})
+	if len(events) != 0 {
+		t.Errorf("expected no pass for a shot that scored, got %v", events)
+	}
+}
+
+func repeatDisc(d discState, n int) []discState {
+	out := make([]discState, n)
+	for i := range out {
+		out[i] = d
+	}
+	return out
+}
@@ -1,209 +1,284 @@
 package events
 
 import (
-	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+	"cmp"
+	"encoding/json"
+	"slices"
+
 	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
 )
 
 // PlayerJoinSensor detects when players join the session
 type PlayerJoinSensor struct {
-	previousPlayers map[int32]*apigame.TeamMember // keyed by slot number
+	playerTracker
+	queue eventQueue
 }
 
 // NewPlayerJoinSensor creates a new PlayerJoinSensor
 func NewPlayerJoinSensor() *PlayerJoinSensor {
-	return &PlayerJoinSensor{
-		previousPlayers: make(map[int32]*apigame.TeamMember),
-	}
+	return &PlayerJoinSensor{playerTracker: newPlayerTracker()}
+}
+
+// Reset clears the sensor state
+func (s *PlayerJoinSensor) Reset() {
+	s.reset()
+	s.queue = eventQueue{}
+}
+
+// Snapshot serializes the sensor state
+func (s *PlayerJoinSensor) Snapshot() ([]byte, error) {
+	return s.snapshot()
+}
+
+// Restore replaces the sensor state with a previous snapshot
+func (s *PlayerJoinSensor) Restore(data []byte) error {
+	s.queue = eventQueue{}
+	return s.restore(data)
 }
 
-// AddFrame processes a frame and returns a PlayerJoined event if detected
+// AddFrame processes a frame and returns a PlayerJoined event if detected.
+// Simultaneous joins are returned by subsequent calls.
 func (s *PlayerJoinSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
+	return s.queue.next(frame, s.DetectEvents)
+}
+
+// DetectEvents appends a PlayerJoined event for every player that joined,
+// including players taking over a slot from another player
+func (s *PlayerJoinSensor) DetectEvents(frame *telemetry.LobbySessionStateFrame, dst []*telemetry.LobbySessionEvent) []*telemetry.LobbySessionEvent {
 	if frame == nil || frame.GetSession() == nil {
-		return nil
+		return dst
 	}
 
-	currentPlayers := extractPlayersMap(frame.GetSession())
-
-	// Find new players (in current but not in previous)
-	for slot, player := range currentPlayers {
-		if _, existed := s.previousPlayers[slot]; !existed {
-			// Update state before returning
-			s.previousPlayers = currentPlayers
-			return &telemetry.LobbySessionEvent{
-				Event: &telemetry.LobbySessionEvent_PlayerJoined{
-					PlayerJoined: &telemetry.PlayerJoined{
-						Player: player,
-						Role:   determinePlayerRole(player),
-					},
+	for _, player := range s.observe(frame).Changes().Joined {
+		dst = append(dst, &telemetry.LobbySessionEvent{
+			Event: &telemetry.LobbySessionEvent_PlayerJoined{
+				PlayerJoined: &telemetry.PlayerJoined{
+					Player: player.Member,
+					Role:   player.Role,
 				},
-			}
-		}
+			},
+		})
 	}
-
-	s.previousPlayers = currentPlayers
-	return nil
+	return dst
 }
 
 // PlayerLeaveSensor detects when players leave the session
 type PlayerLeaveSensor struct {
-	previousPlayers map[int32]*apigame.TeamMember
+	playerTracker
+	queue eventQueue
 }
 
 // NewPlayerLeaveSensor creates a new PlayerLeaveSensor
 func NewPlayerLeaveSensor() *PlayerLeaveSensor {
-	return &PlayerLeaveSensor{
-		previousPlayers: make(map[int32]*apigame.TeamMember),
-	}
+	return &PlayerLeaveSensor{playerTracker: newPlayerTracker()}
+}
+
+// Reset clears the sensor state
+func (s *PlayerLeaveSensor) Reset() {
+	s.reset()
+	s.queue = eventQueue{}
+}
+
+// Snapshot serializes the sensor state
+func (s *PlayerLeaveSensor) Snapshot() ([]byte, error) {
+	return s.snapshot()
+}
+
+// Restore replaces the sensor state with a previous snapshot
+func (s *PlayerLeaveSensor) Restore(data []byte) error {
+	s.queue = eventQueue{}
+	return s.restore(data)
 }
 
-// AddFrame processes a frame and returns a PlayerLeft event if detected
+// AddFrame processes a frame and returns a PlayerLeft event if detected.
+// Simultaneous departures are returned by subsequent calls.
 func (s *PlayerLeaveSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
+	return s.queue.next(frame, s.DetectEvents)
+}
+
+// DetectEvents appends a PlayerLeft event for every player that left,
+// including players whose slot was taken over by another player
+func (s *PlayerLeaveSensor) DetectEvents(frame *telemetry.LobbySessionStateFrame, dst []*telemetry.LobbySessionEvent) []*telemetry.LobbySessionEvent {
 	if frame == nil || frame.GetSession() == nil {
-		return nil
+		return dst
 	}
 
-	currentPlayers := extractPlayersMap(frame.GetSession())
-
-	// Find missing players (in previous but not in current)
-	for slot, player := range s.previousPlayers {
-		if _, exists := currentPlayers[slot]; !exists {
-			// Update state before returning
-			s.previousPlayers = currentPlayers
-			return &telemetry.LobbySessionEvent{
-				Event: &telemetry.LobbySessionEvent_PlayerLeft{
-					PlayerLeft: &telemetry.PlayerLeft{
-						PlayerSlot:  slot,
-						DisplayName: player.GetDisplayName(),
-					},
+	for _, player := range s.observe(frame).Changes().Left {
+		dst = append(dst, &telemetry.LobbySessionEvent{
+			Event: &telemetry.LobbySessionEvent_PlayerLeft{
+				PlayerLeft: &telemetry.PlayerLeft{
+					PlayerSlot:  player.Member.GetSlotNumber(),
+					DisplayName: player.Member.GetDisplayName(),
 				},
-			}
-		}
+			},
+		})
 	}
-
-	s.previousPlayers = currentPlayers
-	return nil
+	return dst
 }
 
 // PlayerTeamSwitchSensor detects when players switch teams
 type PlayerTeamSwitchSensor struct {
-	previousPlayers map[int32]*apigame.TeamMember
+	playerTracker
+	queue eventQueue
 }
 
 // NewPlayerTeamSwitchSensor creates a new PlayerTeamSwitchSensor
 func NewPlayerTeamSwitchSensor() *PlayerTeamSwitchSensor {
-	return &PlayerTeamSwitchSensor{
-		previousPlayers: make(map[int32]*apigame.TeamMember),
-	}
+	return &PlayerTeamSwitchSensor{playerTracker: newPlayerTracker()}
+}
+
+// Reset clears the sensor state
+func (s *PlayerTeamSwitchSensor) Reset() {
+	s.reset()
+	s.queue = eventQueue{}
+}
+
+// Snapshot serializes the sensor state
+func (s *PlayerTeamSwitchSensor) Snapshot() ([]byte, error) {
+	return s.snapshot()
 }
 
-// AddFrame processes a frame and returns a PlayerSwitchedTeam event if detected
+// Restore replaces the sensor state with a previous snapshot
+func (s *PlayerTeamSwitchSensor) Restore(data []byte) error {
+	s.queue = eventQueue{}
+	return s.restore(data)
+}
+
+// AddFrame processes a frame and returns a PlayerSwitchedTeam event if detected.
+// Simultaneous switches are returned by subsequent calls.
 func (s *PlayerTeamSwitchSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
+	return s.queue.next(frame, s.DetectEvents)
+}
+
+// DetectEvents appends a PlayerSwitchedTeam event for every player that switched teams
+func (s *PlayerTeamSwitchSensor) DetectEvents(frame *telemetry.LobbySessionStateFrame, dst []*telemetry.LobbySessionEvent) []*telemetry.LobbySessionEvent {
 	if frame == nil || frame.GetSession() == nil {
-		return nil
+		return dst
 	}
 
-	currentPlayers := extractPlayersMap(frame.GetSession())
-
-	// Check for team switches (same slot, different team)
-	for slot, currentPlayer := range currentPlayers {
-		if prevPlayer, existed := s.previousPlayers[slot]; existed {
-			prevRole := determinePlayerRole(prevPlayer)
-			currRole := determinePlayerRole(currentPlayer)
-			if prevRole != currRole {
-				s.previousPlayers = currentPlayers
-				return &telemetry.LobbySessionEvent{
-					Event: &telemetry.LobbySessionEvent_PlayerSwitchedTeam{
-						PlayerSwitchedTeam: &telemetry.PlayerSwitchedTeam{
-							PlayerSlot: slot,
-							NewRole:    currRole,
-							PrevRole:   prevRole,
-						},
-					},
-				}
-			}
-		}
+	for _, sw := range s.observe(frame).Changes().Switched {
+		dst = append(dst, &telemetry.LobbySessionEvent{
+			Event: &telemetry.LobbySessionEvent_PlayerSwitchedTeam{
+				PlayerSwitchedTeam: &telemetry.PlayerSwitchedTeam{
+					PlayerSlot: sw.Player.Member.GetSlotNumber(),
+					NewRole:    sw.Player.Role,
+					PrevRole:   sw.PrevRole,
+				},
+			},
+		})
 	}
-
-	s.previousPlayers = currentPlayers
-	return nil
+	return dst
 }
 
 // EmoteSensor detects when players play emotes
 type EmoteSensor struct {
-	previousEmoteStates map[int32]bool // keyed by slot number
+	playerTracker
+	previousEmoteStates map[PlayerKey]bool
+	queue               eventQueue
 }
 
 // NewEmoteSensor creates a new EmoteSensor
 func NewEmoteSensor() *EmoteSensor {
 	return &EmoteSensor{
-		previousEmoteStates: make(map[int32]bool),
+		playerTracker:       newPlayerTracker(),
+		previousEmoteStates: make(map[PlayerKey]bool),
 	}
 }
 
-// AddFrame processes a frame and returns an EmotePlayed event if detected
-func (s *EmoteSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
-	if frame == nil || frame.GetSession() == nil {
-		return nil
-	}
+// Reset clears the sensor state
+func (s *EmoteSensor) Reset() {
+	s.reset()
+	s.previousEmoteStates = make(map[PlayerKey]bool)
+	s.queue = eventQueue{}
+}
 
-	for _, team := range frame.GetSession().GetTeams() {
-		for _, player := range team.GetPlayers() {
-			slot := player.GetSlotNumber()
-			isPlaying := player.GetIsEmotePlaying()
-			wasPlaying := s.previousEmoteStates[slot]
-
-			// Detect transition from not playing to playing
-			if isPlaying && !wasPlaying {
-				s.previousEmoteStates[slot] = isPlaying
-				return &telemetry.LobbySessionEvent{
-					Event: &telemetry.LobbySessionEvent_EmotePlayed{
-						EmotePlayed: &telemetry.EmotePlayed{
-							PlayerSlot: slot,
-							Emote:      telemetry.EmotePlayed_EMOTE_TYPE_PRIMARY,
-						},
-					},
-				}
-			}
-			s.previousEmoteStates[slot] = isPlaying
+// emoteSensorState is the snapshot of an EmoteSensor
+type emoteSensorState struct {
+	Playing []PlayerKey     `json:"playing"`
+	Players json.RawMessage `json:"players,omitempty"`
+}
+
+// Snapshot serializes the sensor state
+func (s *EmoteSensor) Snapshot() ([]byte, error) {
+	state := emoteSensorState{Playing: []PlayerKey{}}
+	for key, playing := range s.previousEmoteStates {
+		if playing {
+			state.Playing = append(state.Playing, key)
 		}
 	}
+	slices.SortFunc(state.Playing, comparePlayerKeys)
+	players, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	state.Players = players
+	return json.Marshal(state)
+}
 
+// Restore replaces the sensor state with a previous snapshot
+func (s *EmoteSensor) Restore(data []byte) error {
+	var state emoteSensorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	s.Reset()
+	for _, key := range state.Playing {
+		s.previousEmoteStates[key] = true
+	}
+	if state.Players != nil {
+		return s.restore(state.Players)
+	}
 	return nil
 }
 
-// extractPlayersMap extracts all players from a session into a map keyed by slot
-func extractPlayersMap(session *apigame.SessionResponse) map[int32]*apigame.TeamMember {
-	players := make(map[int32]*apigame.TeamMember)
-	for _, team := range session.GetTeams() {
-		for _, player := range team.GetPlayers() {
-			players[player.GetSlotNumber()] = player
-		}
-	}
-	return players
+// AddFrame processes a frame and returns an EmotePlayed event if detected.
+// Simultaneous emotes are returned by subsequent calls.
+func (s *EmoteSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
+	return s.queue.next(frame, s.DetectEvents)
 }
 
-// determinePlayerRole determines a player's role based on their jersey number and slot
-func determinePlayerRole(player *apigame.TeamMember) telemetry.Role {
-	if player == nil {
-		return telemetry.Role_ROLE_UNSPECIFIED
+// DetectEvents appends an EmotePlayed event for every player that started an emote
+func (s *EmoteSensor) DetectEvents(frame *telemetry.LobbySessionStateFrame, dst []*telemetry.LobbySessionEvent) []*telemetry.LobbySessionEvent {
+	if frame == nil || frame.GetSession() == nil {
+		return dst
 	}
 
-	jerseyNumber := player.GetJerseyNumber()
+	// Rebuild the state each frame so departed players are forgotten
+	previous := s.previousEmoteStates
+	s.previousEmoteStates = make(map[PlayerKey]bool, len(previous))
+	for _, player := range s.observe(frame).Roster().Players() {
+		isPlaying := player.Member.GetIsEmotePlaying()
+		wasPlaying := previous[player.Key]
 
-	// Spectators have jersey number -1
-	if jerseyNumber == -1 {
-		return telemetry.Role_ROLE_SPECTATOR
+		// Detect transition from not playing to playing
+		if isPlaying && !wasPlaying {
+			dst = append(dst, &telemetry.LobbySessionEvent{
+				Event: &telemetry.LobbySessionEvent_EmotePlayed{
+					EmotePlayed: &telemetry.EmotePlayed{
+						PlayerSlot: player.Member.GetSlotNumber(),
+						Emote:      telemetry.EmotePlayed_EMOTE_TYPE_PRIMARY,
+					},
+				},
+			})
+		}
+		s.previousEmoteStates[player.Key] = isPlaying
 	}
 
-	// Blue team: jersey 0-4, Orange team: jersey 5-9 (or similar pattern)
-	// In Echo Arena, blue team players have even slot offsets, orange team have odd
-	// Actually, teams are determined by which team array they're in, but we use jersey as heuristic
-	slot := player.GetSlotNumber()
+	return dst
+}
 
-	// Players 0-3 are typically blue, 4-7 are typically orange
-	if slot < 4 {
-		return telemetry.Role_ROLE_BLUE_TEAM
-	}
-	return telemetry.Role_ROLE_ORANGE_TEAM
+// playersBySlot returns the players of a roster sorted by slot
+func playersBySlot(r *Roster) []RosterPlayer {
+	return slices.SortedFunc(slices.Values(r.Players()), func(a, b RosterPlayer) int {
+		return cmp.Compare(a.Member.GetSlotNumber(), b.Member.GetSlotNumber())
+	})
+}
+
+// comparePlayerKeys orders player keys by account number, display name, then slot
+func comparePlayerKeys(a, b PlayerKey) int {
+	return cmp.Or(
+		cmp.Compare(a.AccountNumber, b.AccountNumber),
+		cmp.Compare(a.DisplayName, b.DisplayName),
+		cmp.Compare(a.SlotNumber, b.SlotNumber),
+	)
 }
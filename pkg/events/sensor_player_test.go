@@ -9,6 +9,18 @@ import (
 
 // Test helper functions
 
+func createFrameWithTeams(blue, orange, spectators []*apigame.TeamMember) *telemetry.LobbySessionStateFrame {
+	return &telemetry.LobbySessionStateFrame{
+		Session: &apigame.SessionResponse{
+			Teams: []*apigame.Team{
+				{TeamName: "BLUE TEAM", Players: blue},
+				{TeamName: "ORANGE TEAM", Players: orange},
+				{TeamName: "SPECTATORS", Players: spectators},
+			},
+		},
+	}
+}
+
 func createFrameWithPlayers(players ...*apigame.TeamMember) *telemetry.LobbySessionStateFrame {
 	return &telemetry.LobbySessionStateFrame{
 		Session: &apigame.SessionResponse{
@@ -132,40 +144,18 @@ func TestPlayerLeaveSensor_NilFrame(t *testing.T) {
 func TestPlayerTeamSwitchSensor_DetectsTeamSwitch(t *testing.T) {
 	sensor := NewPlayerTeamSwitchSensor()
 
-	// First frame: player on blue team (slot 0-3)
-	frame1 := createFrameWithPlayers(createPlayer(1, "Player1", 0))
-	event := sensor.AddFrame(frame1)
+	// First frame: player on the blue team
+	player := createPlayer(1, "Player1", 0)
+	player.AccountNumber = 1001
+	event := sensor.AddFrame(createFrameWithTeams([]*apigame.TeamMember{player}, nil, nil))
 	if event != nil {
 		t.Fatalf("expected no event on first frame, got %v", event)
 	}
 
-	// Second frame: same player, different slot (indicating team switch)
-	frame2 := createFrameWithPlayers(createPlayer(1, "Player1", 5)) // jersey 5 = orange
-	// Actually team is determined by slot in our implementation
-	frame2 = createFrameWithPlayers(&apigame.TeamMember{
-		SlotNumber:   5, // Changed slot to orange team range
-		DisplayName:  "Player1",
-		JerseyNumber: 5,
-	})
-	// We need to use same slot but change the role determination
-	// Actually let's test with jersey number change for spectator
-
-	// Reset and test spectator transition
-	sensor = NewPlayerTeamSwitchSensor()
-	frame1 = createFrameWithPlayers(&apigame.TeamMember{
-		SlotNumber:   1,
-		DisplayName:  "Player1",
-		JerseyNumber: 0, // Blue team
-	})
-	sensor.AddFrame(frame1)
-
-	// Player becomes spectator
-	frame2 = createFrameWithPlayers(&apigame.TeamMember{
-		SlotNumber:   1,
-		DisplayName:  "Player1",
-		JerseyNumber: -1, // Spectator
-	})
-	event = sensor.AddFrame(frame2)
+	// Player moves to the spectator team, taking a different slot
+	spectator := createPlayer(9, "Player1", -1)
+	spectator.AccountNumber = 1001
+	event = sensor.AddFrame(createFrameWithTeams(nil, nil, []*apigame.TeamMember{spectator}))
 
 	if event == nil {
 		t.Fatal("expected PlayerSwitchedTeam event")
@@ -176,8 +166,12 @@ func TestPlayerTeamSwitchSensor_DetectsTeamSwitch(t *testing.T) {
 		t.Fatalf("expected PlayerSwitchedTeam, got %T", event.Event)
 	}
 
-	if switched.PlayerSlot != 1 {
-		t.Errorf("expected slot 1, got %d", switched.PlayerSlot)
+	if switched.PlayerSlot != 9 {
+		t.Errorf("expected slot 9, got %d", switched.PlayerSlot)
+	}
+
+	if switched.PrevRole != telemetry.Role_ROLE_BLUE_TEAM {
+		t.Errorf("expected BLUE_TEAM previous role, got %v", switched.PrevRole)
 	}
 
 	if switched.NewRole != telemetry.Role_ROLE_SPECTATOR {
@@ -185,6 +179,16 @@ func TestPlayerTeamSwitchSensor_DetectsTeamSwitch(t *testing.T) {
 	}
 }
 
+func TestPlayerTeamSwitchSensor_IgnoresSlotLayout(t *testing.T) {
+	sensor := NewPlayerTeamSwitchSensor()
+
+	// Orange players in low slots must not be mistaken for blue players
+	sensor.AddFrame(createFrameWithTeams(nil, []*apigame.TeamMember{createPlayer(0, "Player0", 0)}, nil))
+	if event := sensor.AddFrame(createFrameWithTeams(nil, []*apigame.TeamMember{createPlayer(0, "Player0", 0)}, nil)); event != nil {
+		t.Fatalf("expected no event for a player staying on orange, got %v", event)
+	}
+}
+
 // EmoteSensor Tests
 
 func TestEmoteSensor_DetectsEmotePlayed(t *testing.T) {
@@ -245,67 +249,127 @@ func TestEmoteSensor_NoEventWhenAlreadyPlaying(t *testing.T) {
 	}
 }
 
-// Helper function tests
+func TestPlayerJoinSensor_ReportsTeamRole(t *testing.T) {
+	sensor := NewPlayerJoinSensor()
+	sensor.DetectEvents(createFrameWithTeams(nil, nil, nil), nil)
 
-func TestDeterminePlayerRole_Spectator(t *testing.T) {
-	player := &apigame.TeamMember{JerseyNumber: -1}
-	role := determinePlayerRole(player)
-	if role != telemetry.Role_ROLE_SPECTATOR {
+	events := sensor.DetectEvents(createFrameWithTeams(
+		nil,
+		[]*apigame.TeamMember{createPlayer(0, "Orange0", 0)},
+		[]*apigame.TeamMember{createPlayer(7, "Spectator", -1)},
+	), nil)
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 joins, got %d", len(events))
+	}
+	if role := events[0].GetPlayerJoined().GetRole(); role != telemetry.Role_ROLE_ORANGE_TEAM {
+		t.Errorf("expected ORANGE_TEAM for slot 0 on the orange team, got %v", role)
+	}
+	if role := events[1].GetPlayerJoined().GetRole(); role != telemetry.Role_ROLE_SPECTATOR {
 		t.Errorf("expected SPECTATOR, got %v", role)
 	}
 }
 
-func TestDeterminePlayerRole_BlueTeam(t *testing.T) {
-	player := &apigame.TeamMember{SlotNumber: 1, JerseyNumber: 0}
-	role := determinePlayerRole(player)
-	if role != telemetry.Role_ROLE_BLUE_TEAM {
-		t.Errorf("expected BLUE_TEAM, got %v", role)
+func TestPlayerSensors_SlotReuse(t *testing.T) {
+	join := NewPlayerJoinSensor()
+	leave := NewPlayerLeaveSensor()
+
+	alice := createPlayer(3, "Alice", 1)
+	alice.AccountNumber = 1
+	bob := createPlayer(3, "Bob", 1)
+	bob.AccountNumber = 2
+
+	frame1 := createFrameWithPlayers(alice)
+	join.DetectEvents(frame1, nil)
+	leave.DetectEvents(frame1, nil)
+
+	// Bob takes Alice's slot between two frames
+	frame2 := createFrameWithPlayers(bob)
+	joined := join.DetectEvents(frame2, nil)
+	left := leave.DetectEvents(frame2, nil)
+
+	if len(joined) != 1 || joined[0].GetPlayerJoined().GetPlayer().GetDisplayName() != "Bob" {
+		t.Errorf("expected Bob to join, got %v", joined)
+	}
+	if len(left) != 1 || left[0].GetPlayerLeft().GetDisplayName() != "Alice" {
+		t.Errorf("expected Alice to leave, got %v", left)
 	}
 }
 
-func TestDeterminePlayerRole_OrangeTeam(t *testing.T) {
-	player := &apigame.TeamMember{SlotNumber: 5, JerseyNumber: 1}
-	role := determinePlayerRole(player)
-	if role != telemetry.Role_ROLE_ORANGE_TEAM {
-		t.Errorf("expected ORANGE_TEAM, got %v", role)
+func TestPlayerJoinSensor_DetectEventsReportsSimultaneousJoins(t *testing.T) {
+	sensor := NewPlayerJoinSensor()
+	sensor.DetectEvents(createFrameWithPlayers(), nil)
+
+	events := sensor.DetectEvents(createFrameWithPlayers(
+		createPlayer(0, "Player0", 0),
+		createPlayer(1, "Player1", 1),
+		createPlayer(2, "Player2", 2),
+	), nil)
+
+	if len(events) != 3 {
+		t.Fatalf("expected 3 PlayerJoined events, got %d", len(events))
+	}
+	for i, event := range events {
+		joined := event.GetPlayerJoined()
+		if joined == nil {
+			t.Fatalf("event %d: expected PlayerJoined, got %T", i, event.Event)
+		}
+		if joined.Player.GetSlotNumber() != int32(i) {
+			t.Errorf("event %d: expected slot %d, got %d", i, i, joined.Player.GetSlotNumber())
+		}
 	}
 }
 
-func TestDeterminePlayerRole_NilPlayer(t *testing.T) {
-	role := determinePlayerRole(nil)
-	if role != telemetry.Role_ROLE_UNSPECIFIED {
-		t.Errorf("expected UNSPECIFIED, got %v", role)
+func TestPlayerJoinSensor_AddFrameQueuesSimultaneousJoins(t *testing.T) {
+	sensor := NewPlayerJoinSensor()
+	sensor.AddFrame(createFrameWithPlayers())
+
+	frame := createFrameWithPlayers(createPlayer(0, "Player0", 0), createPlayer(1, "Player1", 1))
+	first := sensor.AddFrame(frame)
+	second := sensor.AddFrame(frame)
+
+	if first.GetPlayerJoined() == nil || second.GetPlayerJoined() == nil {
+		t.Fatalf("expected two PlayerJoined events, got %v and %v", first, second)
+	}
+	if third := sensor.AddFrame(frame); third != nil {
+		t.Errorf("expected no more events, got %v", third)
 	}
 }
 
-func TestExtractPlayersMap(t *testing.T) {
-	session := &apigame.SessionResponse{
-		Teams: []*apigame.Team{
-			{
-				Players: []*apigame.TeamMember{
-					{SlotNumber: 1, DisplayName: "Player1"},
-					{SlotNumber: 2, DisplayName: "Player2"},
-				},
-			},
-			{
-				Players: []*apigame.TeamMember{
-					{SlotNumber: 5, DisplayName: "Player3"},
-				},
-			},
-		},
-	}
+func TestPlayerLeaveSensor_DetectEventsReportsSimultaneousLeaves(t *testing.T) {
+	sensor := NewPlayerLeaveSensor()
+	sensor.DetectEvents(createFrameWithPlayers(
+		createPlayer(3, "Player3", 3),
+		createPlayer(1, "Player1", 1),
+		createPlayer(2, "Player2", 2),
+	), nil)
 
-	players := extractPlayersMap(session)
+	events := sensor.DetectEvents(createFrameWithPlayers(createPlayer(2, "Player2", 2)), nil)
 
-	if len(players) != 3 {
-		t.Errorf("expected 3 players, got %d", len(players))
+	if len(events) != 2 {
+		t.Fatalf("expected 2 PlayerLeft events, got %d", len(events))
 	}
+	if events[0].GetPlayerLeft().GetPlayerSlot() != 1 || events[1].GetPlayerLeft().GetPlayerSlot() != 3 {
+		t.Errorf("expected leaves in slot order 1, 3; got %d, %d",
+			events[0].GetPlayerLeft().GetPlayerSlot(), events[1].GetPlayerLeft().GetPlayerSlot())
+	}
+}
+
+func TestEmoteSensor_DetectEventsReportsSimultaneousEmotes(t *testing.T) {
+	sensor := NewEmoteSensor()
+
+	p1 := createPlayer(1, "Player1", 1)
+	p2 := createPlayer(2, "Player2", 2)
+	p1.IsEmotePlaying = true
+	p2.IsEmotePlaying = true
 
-	if players[1].GetDisplayName() != "Player1" {
-		t.Errorf("expected Player1 at slot 1")
+	events := sensor.DetectEvents(createFrameWithPlayers(p1, p2), nil)
+	if len(events) != 2 {
+		t.Fatalf("expected 2 EmotePlayed events, got %d", len(events))
 	}
 
-	if players[5].GetDisplayName() != "Player3" {
-		t.Errorf("expected Player3 at slot 5")
+	// Both emotes were recorded, so nothing fires on the next frame
+	if events := sensor.DetectEvents(createFrameWithPlayers(p1, p2), nil); len(events) != 0 {
+		t.Errorf("expected no events while emotes keep playing, got %d", len(events))
 	}
 }
@@ -0,0 +1,319 @@
+package events
+
+import (
+	"cmp"
+	"encoding/json"
+	"maps"
+	"math"
+	"slices"
+
+	"github.com/echotools/nevr-capture/v3/pkg/arena"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// Custom events reported by the PositionalRoleSensor
+const (
+	// EventPositionalRoleChanged is a player moving into a different
+	// positional role. Fields: player_slot, team, role, previous_role, x, y,
+	// z.
+	EventPositionalRoleChanged = "positional_role_changed"
+	// EventRoleTime is a player's time in each positional role during a
+	// round, reported when the round ends. Fields: player_slot, team, round,
+	// goalie, defense, offense and transit (seconds).
+	EventRoleTime = "role_time"
+)
+
+// PositionalRole is where a player is positioned relative to the play
+type PositionalRole string
+
+// Positional roles
+const (
+	// PositionGoalie is the team's player nearest its own goal while the
+	// other team holds the disc
+	PositionGoalie PositionalRole = "goalie"
+	// PositionDefense is a player in their own half
+	PositionDefense PositionalRole = "defense"
+	// PositionOffense is a player in the opponent's half
+	PositionOffense PositionalRole = "offense"
+	// PositionTransit is a player around the middle of the arena
+	PositionTransit PositionalRole = "transit"
+)
+
+// positionalRoles lists the positional roles in reporting order
+var positionalRoles = [...]PositionalRole{PositionGoalie, PositionDefense, PositionOffense, PositionTransit}
+
+// PositionalRoleConfig configures a PositionalRoleSensor
+type PositionalRoleConfig struct {
+	// GoalieRange is the furthest, in meters, a player can be from their own
+	// goal and still be its goalie
+	GoalieRange float64 `json:"goalie_range"`
+	// TransitDepth is the distance, in meters, either side of the center
+	// line that counts as transit
+	TransitDepth float64 `json:"transit_depth"`
+}
+
+// DefaultPositionalRoleConfig returns the default positional role zones
+func DefaultPositionalRoleConfig() PositionalRoleConfig {
+	return PositionalRoleConfig{
+		GoalieRange:  12,
+		TransitDepth: 8,
+	}
+}
+
+// PositionalRoleSensor classifies every player as goalie, defense, offense
+// or transit each frame from their position and which team holds the disc.
+// It reports role changes and each player's time in role per round.
+type PositionalRoleSensor struct {
+	customOnly
+	playerTracker
+	config PositionalRoleConfig
+
+	players    map[PlayerKey]*positionalState
+	round      int32
+	prevTime   FrameTime
+	hasPrev    bool
+	prevStatus string
+}
+
+// positionalState is the positional role history of one player
+type positionalState struct {
+	player RosterPlayer
+	role   PositionalRole
+	time   map[PositionalRole]float64
+}
+
+var _ CustomEventSensor = (*PositionalRoleSensor)(nil)
+
+// NewPositionalRoleSensor creates a new PositionalRoleSensor
+func NewPositionalRoleSensor(cfg PositionalRoleConfig) *PositionalRoleSensor {
+	return &PositionalRoleSensor{
+		playerTracker: newPlayerTracker(),
+		config:        cfg,
+		players:       make(map[PlayerKey]*positionalState),
+	}
+}
+
+// Reset clears the sensor state
+func (s *PositionalRoleSensor) Reset() {
+	s.reset()
+	s.players = make(map[PlayerKey]*positionalState)
+	s.round = 0
+	s.hasPrev = false
+	s.prevStatus = ""
+}
+
+// positionalRoleSensorState is the snapshot of a PositionalRoleSensor
+type positionalRoleSensorState struct {
+	States     []positionalStateSnapshot `json:"states"`
+	Round      int32                     `json:"round"`
+	PrevTime   FrameTime                 `json:"prev_time"`
+	HasPrev    bool                      `json:"has_prev"`
+	PrevStatus string                    `json:"prev_status,omitempty"`
+	Players    json.RawMessage           `json:"players,omitempty"`
+}
+
+type positionalStateSnapshot struct {
+	Player rosterPlayerState          `json:"player"`
+	Role   PositionalRole             `json:"role"`
+	Time   map[PositionalRole]float64 `json:"time"`
+}
+
+// Snapshot serializes the sensor state
+func (s *PositionalRoleSensor) Snapshot() ([]byte, error) {
+	players, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	state := positionalRoleSensorState{
+		States:     make([]positionalStateSnapshot, 0, len(s.players)),
+		Round:      s.round,
+		PrevTime:   s.prevTime,
+		HasPrev:    s.hasPrev,
+		PrevStatus: s.prevStatus,
+		Players:    players,
+	}
+	for _, ps := range sortedPositional(s.players) {
+		player, err := snapshotRosterPlayer(ps.player)
+		if err != nil {
+			return nil, err
+		}
+		state.States = append(state.States, positionalStateSnapshot{Player: player, Role: ps.role, Time: ps.time})
+	}
+	return json.Marshal(state)
+}
+
+// Restore replaces the sensor state with a previous snapshot
+func (s *PositionalRoleSensor) Restore(data []byte) error {
+	var state positionalRoleSensorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	s.Reset()
+	for _, ps := range state.States {
+		player, err := restoreRosterPlayer(ps.Player)
+		if err != nil {
+			return err
+		}
+		time := make(map[PositionalRole]float64, len(positionalRoles))
+		maps.Copy(time, ps.Time)
+		s.players[player.Key] = &positionalState{player: player, role: ps.Role, time: time}
+	}
+	s.round, s.prevTime, s.hasPrev, s.prevStatus = state.Round, state.PrevTime, state.HasPrev, state.PrevStatus
+	if state.Players != nil {
+		return s.restore(state.Players)
+	}
+	return nil
+}
+
+// DetectCustomEvents classifies the frame's players and appends role
+// changes, and the round's role times when a round ends
+func (s *PositionalRoleSensor) DetectCustomEvents(frame *telemetry.LobbySessionStateFrame, dst []*CustomEvent) []*CustomEvent {
+	if frame == nil || frame.GetSession() == nil {
+		return dst
+	}
+	session := frame.GetSession()
+	roster := s.observe(frame).Roster()
+	status := session.GetGameStatus()
+	prevStatus := s.prevStatus
+	s.prevStatus = status
+
+	if (status == GameStatusRoundOver || status == GameStatusPostMatch) &&
+		prevStatus != GameStatusRoundOver && prevStatus != GameStatusPostMatch && prevStatus != "" {
+		dst = s.appendRoleTimes(dst)
+		s.players = make(map[PlayerKey]*positionalState)
+	}
+
+	now := FrameTimeOf(frame)
+	dt := now.Since(s.prevTime)
+	if !s.hasPrev || prevStatus != GameStatusPlaying || dt <= 0 || dt > maxFrameGap {
+		dt = 0
+	}
+	s.prevTime, s.hasPrev = now, true
+	if status != GameStatusPlaying {
+		return dst
+	}
+	if len(s.players) == 0 {
+		s.round = session.GetBlueRoundScore() + session.GetOrangeRoundScore() + 1
+	}
+
+	possession := telemetry.Role_ROLE_UNSPECIFIED
+	for _, player := range roster.Players() {
+		if isTeamRole(player.Role) && player.Member.GetHasPossession() {
+			possession = player.Role
+		}
+	}
+	goalies := map[telemetry.Role]PlayerKey{}
+	for _, team := range [...]telemetry.Role{telemetry.Role_ROLE_BLUE_TEAM, telemetry.Role_ROLE_ORANGE_TEAM} {
+		if possession == telemetry.Role_ROLE_UNSPECIFIED || possession == team {
+			continue
+		}
+		if key, ok := s.nearestToGoal(roster, team); ok {
+			goalies[team] = key
+		}
+	}
+
+	for _, player := range roster.Players() {
+		if !isTeamRole(player.Role) {
+			continue
+		}
+		pos, ok := arena.PlayerPosition(player.Member)
+		if !ok {
+			continue
+		}
+		role := s.classify(player, pos)
+		if goalie, ok := goalies[player.Role]; ok && goalie == player.Key {
+			role = PositionGoalie
+		}
+
+		state := s.players[player.Key]
+		if state == nil {
+			state = &positionalState{role: role, time: make(map[PositionalRole]float64, len(positionalRoles))}
+			s.players[player.Key] = state
+		}
+		state.player = player
+		state.time[role] += dt
+		if role == state.role {
+			continue
+		}
+		dst = append(dst, &CustomEvent{
+			Name: EventPositionalRoleChanged,
+			Fields: map[string]any{
+				"player_slot":   player.Member.GetSlotNumber(),
+				"team":          player.Role.String(),
+				"role":          string(role),
+				"previous_role": string(state.role),
+				"x":             pos.X,
+				"y":             pos.Y,
+				"z":             pos.Z,
+			},
+		})
+		state.role = role
+	}
+	return dst
+}
+
+// classify returns the role of a player from their position alone
+func (s *PositionalRoleSensor) classify(player RosterPlayer, pos arena.Vec3) PositionalRole {
+	switch {
+	case math.Abs(pos.Z) <= s.config.TransitDepth:
+		return PositionTransit
+	case (pos.Z < 0) == (player.Role == telemetry.Role_ROLE_BLUE_TEAM):
+		return PositionDefense
+	default:
+		return PositionOffense
+	}
+}
+
+// nearestToGoal returns the team's player nearest its own goal if they are
+// within goalie range of it
+func (s *PositionalRoleSensor) nearestToGoal(roster *Roster, team telemetry.Role) (PlayerKey, bool) {
+	goal := arena.OrangeGoal
+	if team == telemetry.Role_ROLE_BLUE_TEAM {
+		goal = arena.BlueGoal
+	}
+	var key PlayerKey
+	best := s.config.GoalieRange
+	found := false
+	for _, player := range roster.Players() {
+		if player.Role != team {
+			continue
+		}
+		pos, ok := arena.PlayerPosition(player.Member)
+		if !ok {
+			continue
+		}
+		if d := pos.Dist(goal); d <= best {
+			key, best, found = player.Key, d, true
+		}
+	}
+	return key, found
+}
+
+// appendRoleTimes appends the round's role times for every tracked player
+// in slot order
+func (s *PositionalRoleSensor) appendRoleTimes(dst []*CustomEvent) []*CustomEvent {
+	for _, state := range sortedPositional(s.players) {
+		fields := map[string]any{
+			"player_slot": state.player.Member.GetSlotNumber(),
+			"team":        state.player.Role.String(),
+			"round":       s.round,
+		}
+		for _, role := range positionalRoles {
+			fields[string(role)] = state.time[role]
+		}
+		dst = append(dst, &CustomEvent{Name: EventRoleTime, Fields: fields})
+	}
+	return dst
+}
+
+// sortedPositional returns the tracked players in slot order
+func sortedPositional(players map[PlayerKey]*positionalState) []*positionalState {
+	states := slices.Collect(maps.Values(players))
+	slices.SortFunc(states, func(a, b *positionalState) int {
+		return cmp.Or(
+			cmp.Compare(a.player.Member.GetSlotNumber(), b.player.Member.GetSlotNumber()),
+			comparePlayerKeys(a.player.Key, b.player.Key),
+		)
+	})
+	return states
+}
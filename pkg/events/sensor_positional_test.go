@@ -0,0 +1,124 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// positionalPlayer describes a player of a positional role test frame
+type positionalPlayer struct {
+	slot int32
+	z    float64
+	disc bool
+}
+
+func positionalFrame(at time.Duration, status string, blue, orange []positionalPlayer) *telemetry.LobbySessionStateFrame {
+	members := func(players []positionalPlayer) []*apigame.TeamMember {
+		var out []*apigame.TeamMember
+		for _, p := range players {
+			out = append(out, &apigame.TeamMember{
+				SlotNumber:    p.slot,
+				DisplayName:   "P" + string(rune('0'+p.slot)),
+				Body:          &apigame.BodyPart{Position: []float64{0, 0, p.z}},
+				HasPossession: p.disc,
+			})
+		}
+		return out
+	}
+	frame := createFrameWithTeams(members(blue), members(orange), nil)
+	frame.Timestamp = timestamppb.New(bonesEpoch.Add(at))
+	frame.Session.GameStatus = status
+	return frame
+}
+
+func TestPositionalRoleSensor_Classifies(t *testing.T) {
+	sensor := NewPositionalRoleSensor(DefaultPositionalRoleConfig())
+
+	// Orange attacks: blue slot 0 is nearest its goal, slot 1 is in its own
+	// half and slot 2 is at midfield
+	blue := []positionalPlayer{{slot: 0, z: -30}, {slot: 1, z: -20}, {slot: 2, z: 2}}
+	orange := []positionalPlayer{{slot: 3, z: -15, disc: true}}
+	sensor.DetectCustomEvents(positionalFrame(0, GameStatusPlaying, blue, orange), nil)
+
+	roles := map[int32]PositionalRole{}
+	for _, state := range sensor.players {
+		roles[state.player.Member.GetSlotNumber()] = state.role
+	}
+	want := map[int32]PositionalRole{0: PositionGoalie, 1: PositionDefense, 2: PositionTransit, 3: PositionOffense}
+	for slot, role := range want {
+		if roles[slot] != role {
+			t.Errorf("slot %d: expected %s, got %s", slot, role, roles[slot])
+		}
+	}
+
+	// Blue takes the disc, so the goalie is just a defender
+	blue[2].disc, orange[0].disc = true, false
+	events := sensor.DetectCustomEvents(positionalFrame(100*time.Millisecond, GameStatusPlaying, blue, orange), nil)
+	if len(events) != 1 || events[0].Name != EventPositionalRoleChanged {
+		t.Fatalf("expected 1 role change, got %v", events)
+	}
+	if f := events[0].Fields; f["player_slot"] != int32(0) || f["role"] != "defense" || f["previous_role"] != "goalie" {
+		t.Errorf("expected the goalie to become a defender, got %v", f)
+	}
+}
+
+func TestPositionalRoleSensor_GoalieOutOfRange(t *testing.T) {
+	sensor := NewPositionalRoleSensor(DefaultPositionalRoleConfig())
+
+	blue := []positionalPlayer{{slot: 0, z: -15}}
+	orange := []positionalPlayer{{slot: 1, z: 0, disc: true}}
+	sensor.DetectCustomEvents(positionalFrame(0, GameStatusPlaying, blue, orange), nil)
+
+	for _, state := range sensor.players {
+		if state.role == PositionGoalie {
+			t.Errorf("expected no goalie beyond goalie range, got slot %d", state.player.Member.GetSlotNumber())
+		}
+	}
+}
+
+func TestPositionalRoleSensor_RoleTimePerRound(t *testing.T) {
+	sensor := NewPositionalRoleSensor(DefaultPositionalRoleConfig())
+	orange := []positionalPlayer{{slot: 1, z: -20, disc: true}}
+
+	var at time.Duration
+	for i := range 11 {
+		z := -30.0
+		if i > 5 {
+			z = 0
+		}
+		sensor.DetectCustomEvents(positionalFrame(at, GameStatusPlaying, []positionalPlayer{{slot: 0, z: z}}, orange), nil)
+		at += 100 * time.Millisecond
+	}
+	events := sensor.DetectCustomEvents(positionalFrame(at, GameStatusRoundOver, nil, nil), nil)
+
+	if len(events) != 2 || events[0].Name != EventRoleTime {
+		t.Fatalf("expected role time per player, got %v", events)
+	}
+	f := events[0].Fields
+	if f["player_slot"] != int32(0) || f["round"] != int32(1) {
+		t.Fatalf("expected blue's first round, got %v", f)
+	}
+	if !approx(f["goalie"].(float64), 0.5) || !approx(f["transit"].(float64), 0.5) || f["offense"] != 0.0 {
+		t.Errorf("unexpected role times: %v", f)
+	}
+	if !approx(events[1].Fields["offense"].(float64), 1.0) {
+		t.Errorf("expected orange on offense all round, got %v", events[1].Fields)
+	}
+
+	// The next round starts from zero
+	sensor.DetectCustomEvents(positionalFrame(at+time.Second, GameStatusPlaying, []positionalPlayer{{slot: 0, z: 0}}, orange), nil)
+	if len(sensor.players) != 2 {
+		t.Fatalf("expected players tracked for the next round")
+	}
+	for _, state := range sensor.players {
+		for role, d := range state.time {
+			if d != 0 {
+				t.Errorf("expected no %s time carried over, got %v", role, d)
+			}
+		}
+	}
+}
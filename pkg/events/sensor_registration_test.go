@@ -0,0 +1,118 @@
+package events
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+func TestAsyncDetector_RegisterSensorsAtRuntime(t *testing.T) {
+	detector := New(WithSynchronousProcessing())
+	defer detector.Stop()
+
+	frame := newStatusOnlyFrame(GameStatusPlaying)
+	detector.ProcessFrame(frame)
+	select {
+	case events := <-detector.EventsChan():
+		t.Fatalf("expected no events without sensors, got %v", events)
+	default:
+	}
+
+	detector.RegisterSensors(AdaptSensor(&mockSensor{id: "late"}))
+	detector.ProcessFrame(frame)
+	select {
+	case events := <-detector.EventsChan():
+		if len(events) != 1 {
+			t.Fatalf("expected 1 event from the registered sensor, got %d", len(events))
+		}
+	default:
+		t.Fatal("expected registered sensor to run on the next frame")
+	}
+}
+
+func TestAsyncDetector_RemoveSensor(t *testing.T) {
+	s1 := &mockSensor{id: "s1"}
+	s2 := NewPlayerJoinSensor()
+	detector := New(WithSensors(s1), WithEventSensors(s2))
+	defer detector.Stop()
+
+	// Sensors registered through WithSensors are matched by the wrapped sensor
+	if !detector.RemoveSensor(AdaptSensor(s1)) {
+		t.Fatal("expected adapted sensor to be removed")
+	}
+	if detector.RemoveSensor(AdaptSensor(s1)) {
+		t.Error("expected second removal to report false")
+	}
+
+	sensors := detector.Sensors()
+	if len(sensors) != 1 || sensors[0] != s2 {
+		t.Fatalf("expected only the event sensor to remain, got %v", sensors)
+	}
+
+	if !detector.RemoveSensor(s2) || len(detector.Sensors()) != 0 {
+		t.Error("expected event sensor to be removed")
+	}
+}
+
+func TestAsyncDetector_SensorsReturnsCopy(t *testing.T) {
+	detector := New(WithEventSensors(NewPlayerJoinSensor()))
+	defer detector.Stop()
+
+	sensors := detector.Sensors()
+	sensors[0] = nil
+
+	if detector.Sensors()[0] == nil {
+		t.Error("expected Sensors to return a copy")
+	}
+}
+
+func TestAsyncDetector_ConcurrentSensorRegistration(t *testing.T) {
+	detector := New()
+	defer detector.Stop()
+
+	frame := &telemetry.LobbySessionStateFrame{
+		Session: &apigame.SessionResponse{GameStatus: GameStatusPlaying},
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range detector.EventsChan() {
+		}
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for range 500 {
+			detector.ProcessFrame(frame)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := range 100 {
+			sensor := AdaptSensor(&mockSensor{})
+			detector.RegisterSensors(sensor)
+			_ = detector.Sensors()
+			if i%2 == 0 {
+				detector.RemoveSensor(sensor)
+			}
+		}
+	}()
+	wg.Wait()
+
+	if got := len(detector.Sensors()); got != 50 {
+		t.Errorf("expected 50 sensors, got %d", got)
+	}
+
+	detector.Stop()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for consumer")
+	}
+}
@@ -1,6 +1,8 @@
 package events
 
 import (
+	"encoding/json"
+
 	"github.com/echotools/nevr-common/v4/gen/go/apigame"
 	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
 )
@@ -19,6 +21,45 @@ func NewScoreboardSensor() *ScoreboardSensor {
 	return &ScoreboardSensor{}
 }
 
+// Reset clears the sensor state
+func (s *ScoreboardSensor) Reset() {
+	*s = *NewScoreboardSensor()
+}
+
+// scoreboardSensorState is the snapshot of a ScoreboardSensor
+type scoreboardSensorState struct {
+	PrevBluePoints       int32 `json:"prev_blue_points"`
+	PrevOrangePoints     int32 `json:"prev_orange_points"`
+	PrevBlueRoundScore   int32 `json:"prev_blue_round_score"`
+	PrevOrangeRoundScore int32 `json:"prev_orange_round_score"`
+	Initialized          bool  `json:"initialized"`
+}
+
+// Snapshot serializes the sensor state
+func (s *ScoreboardSensor) Snapshot() ([]byte, error) {
+	return json.Marshal(scoreboardSensorState{
+		PrevBluePoints:       s.prevBluePoints,
+		PrevOrangePoints:     s.prevOrangePoints,
+		PrevBlueRoundScore:   s.prevBlueRoundScore,
+		PrevOrangeRoundScore: s.prevOrangeRoundScore,
+		Initialized:          s.initialized,
+	})
+}
+
+// Restore replaces the sensor state with a previous snapshot
+func (s *ScoreboardSensor) Restore(data []byte) error {
+	var state scoreboardSensorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	s.prevBluePoints = state.PrevBluePoints
+	s.prevOrangePoints = state.PrevOrangePoints
+	s.prevBlueRoundScore = state.PrevBlueRoundScore
+	s.prevOrangeRoundScore = state.PrevOrangeRoundScore
+	s.initialized = state.Initialized
+	return nil
+}
+
 // AddFrame processes a frame and returns a ScoreboardUpdated event if detected
 func (s *ScoreboardSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
 	if frame == nil || frame.GetSession() == nil {
@@ -78,6 +119,43 @@ func NewGoalScoredSensor() *GoalScoredSensor {
 	return &GoalScoredSensor{}
 }
 
+// Reset clears the sensor state
+func (s *GoalScoredSensor) Reset() {
+	*s = *NewGoalScoredSensor()
+}
+
+// goalScoredSensorState is the snapshot of a GoalScoredSensor
+type goalScoredSensorState struct {
+	PrevLastScore json.RawMessage `json:"prev_last_score,omitempty"`
+}
+
+// Snapshot serializes the sensor state
+func (s *GoalScoredSensor) Snapshot() ([]byte, error) {
+	raw, err := marshalProto(s.prevLastScore)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(goalScoredSensorState{PrevLastScore: raw})
+}
+
+// Restore replaces the sensor state with a previous snapshot
+func (s *GoalScoredSensor) Restore(data []byte) error {
+	var state goalScoredSensorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	lastScore := &apigame.LastScore{}
+	ok, err := unmarshalProto(state.PrevLastScore, lastScore)
+	if err != nil {
+		return err
+	}
+	s.Reset()
+	if ok {
+		s.prevLastScore = lastScore
+	}
+	return nil
+}
+
 // AddFrame processes a frame and returns a GoalScored event if detected
 func (s *GoalScoredSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
 	if frame == nil || frame.GetSession() == nil {
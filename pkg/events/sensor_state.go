@@ -0,0 +1,133 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Resettable is implemented by sensors that keep state between frames.
+// The detector calls Reset when it is reset.
+type Resettable interface {
+	Reset()
+}
+
+// Snapshotter is implemented by sensors whose state can be checkpointed.
+// Sensors that implement Resettable must implement it for their detector to
+// be checkpointed.
+type Snapshotter interface {
+	// Snapshot serializes the sensor state
+	Snapshot() ([]byte, error)
+	// Restore replaces the sensor state with a previous snapshot
+	Restore([]byte) error
+}
+
+// ErrNotSnapshotter is returned when checkpointing a sensor that keeps state
+// but does not implement Snapshotter
+var ErrNotSnapshotter = errors.New("sensor state cannot be checkpointed")
+
+// DetectorSnapshot is a serializable checkpoint of a detector and its sensors
+type DetectorSnapshot struct {
+	PreviousGameStatus string           `json:"previous_game_status,omitempty"`
+	Players            json.RawMessage  `json:"players,omitempty"`
+	Sensors            []SensorSnapshot `json:"sensors"`
+	Derived            []SensorSnapshot `json:"derived,omitempty"`
+	History            json.RawMessage  `json:"history,omitempty"`
+	Rules              json.RawMessage  `json:"rules,omitempty"`
+}
+
+// SensorSnapshot is the checkpointed state of a single sensor. State is
+// empty for stateless sensors.
+type SensorSnapshot struct {
+	Type  string          `json:"type"`
+	State json.RawMessage `json:"state,omitempty"`
+}
+
+// unwrapSensor returns the sensor wrapped by an adapter, or s itself
+func unwrapSensor(s any) any {
+	if a, ok := s.(sensorAdapter); ok {
+		return a.Sensor
+	}
+	return s
+}
+
+// sensorTypeName identifies a sensor in a snapshot
+func sensorTypeName(s any) string {
+	return fmt.Sprintf("%T", unwrapSensor(s))
+}
+
+// resetSensor resets a sensor if it supports it
+func resetSensor(s EventSensor) {
+	if r, ok := unwrapSensor(s).(Resettable); ok {
+		r.Reset()
+	}
+}
+
+// snapshotSensors checkpoints every sensor in order. Sensors that keep state
+// without implementing Snapshotter fail with ErrNotSnapshotter.
+func snapshotSensors[S any](sensors []S) ([]SensorSnapshot, error) {
+	snapshots := make([]SensorSnapshot, len(sensors))
+	for i, s := range sensors {
+		snapshots[i].Type = sensorTypeName(s)
+		snap, ok := unwrapSensor(s).(Snapshotter)
+		if !ok {
+			if _, stateful := unwrapSensor(s).(Resettable); stateful {
+				return nil, fmt.Errorf("failed to snapshot sensor %d (%s): %w", i, snapshots[i].Type, ErrNotSnapshotter)
+			}
+			continue
+		}
+		state, err := snap.Snapshot()
+		if err != nil {
+			return nil, fmt.Errorf("failed to snapshot sensor %d (%s): %w", i, snapshots[i].Type, err)
+		}
+		snapshots[i].State = state
+	}
+	return snapshots, nil
+}
+
+// restoreSensors restores sensors from snapshots taken from an identically
+// configured detector
+func restoreSensors[S any](sensors []S, snapshots []SensorSnapshot) error {
+	if len(snapshots) != len(sensors) {
+		return fmt.Errorf("snapshot has %d sensors, detector has %d", len(snapshots), len(sensors))
+	}
+	for i, s := range sensors {
+		if name := sensorTypeName(s); snapshots[i].Type != name {
+			return fmt.Errorf("sensor %d: snapshot is for %s, detector has %s", i, snapshots[i].Type, name)
+		}
+	}
+	for i, s := range sensors {
+		snap, ok := unwrapSensor(s).(Snapshotter)
+		if !ok || len(snapshots[i].State) == 0 {
+			continue
+		}
+		if err := snap.Restore(snapshots[i].State); err != nil {
+			return fmt.Errorf("failed to restore sensor %d (%s): %w", i, snapshots[i].Type, err)
+		}
+	}
+	return nil
+}
+
+// marshalProto encodes a proto message for inclusion in a JSON snapshot.
+// Returns nil for a nil message.
+func marshalProto(m proto.Message) (json.RawMessage, error) {
+	if m == nil || !m.ProtoReflect().IsValid() {
+		return nil, nil
+	}
+	return protojson.Marshal(m)
+}
+
+// unmarshalProto decodes a message encoded by marshalProto into m.
+// Returns false if raw is empty.
+func unmarshalProto(raw json.RawMessage, m proto.Message) (bool, error) {
+	if len(raw) == 0 {
+		return false, nil
+	}
+	if err := protojson.Unmarshal(raw, m); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,347 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+func TestPlayerJoinSensor_SnapshotRestore(t *testing.T) {
+	sensor := NewPlayerJoinSensor()
+	sensor.DetectEvents(createFrameWithPlayers(createPlayer(1, "Alice", 10)), nil)
+
+	data, err := sensor.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	restored := NewPlayerJoinSensor()
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	events := restored.DetectEvents(createFrameWithPlayers(
+		createPlayer(1, "Alice", 10),
+		createPlayer(2, "Bob", 20),
+	), nil)
+	if len(events) != 1 {
+		t.Fatalf("expected 1 join after restore, got %d", len(events))
+	}
+	if got := events[0].GetPlayerJoined().GetPlayer().GetDisplayName(); got != "Bob" {
+		t.Errorf("expected Bob to join, got %s", got)
+	}
+}
+
+func TestPlayerJoinSensor_Reset(t *testing.T) {
+	sensor := NewPlayerJoinSensor()
+	frame := createFrameWithPlayers(createPlayer(1, "Alice", 10))
+	sensor.DetectEvents(frame, nil)
+
+	sensor.Reset()
+
+	if events := sensor.DetectEvents(frame, nil); len(events) != 1 {
+		t.Fatalf("expected player to rejoin after reset, got %d events", len(events))
+	}
+}
+
+func TestScoreboardSensor_SnapshotRestore(t *testing.T) {
+	sensor := NewScoreboardSensor()
+	sensor.prevBluePoints = 4
+	sensor.prevOrangePoints = 2
+	sensor.initialized = true
+
+	data, err := sensor.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	restored := NewScoreboardSensor()
+	if err := restored.Restore(data); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if *restored != *sensor {
+		t.Errorf("expected %+v, got %+v", sensor, restored)
+	}
+}
+
+func TestAsyncDetector_ResetResetsSensors(t *testing.T) {
+	sensor := NewPlayerJoinSensor()
+	detector := New(WithSynchronousProcessing(), WithEventSensors(sensor))
+	defer detector.Stop()
+
+	frame := createFrameWithPlayers(createPlayer(1, "Alice", 10))
+	detector.ProcessFrame(frame)
+	<-detector.EventsChan()
+
+	detector.Reset()
+	// Snapshot runs on the processing goroutine, so the reset has been applied
+	if _, err := detector.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	detector.ProcessFrame(frame)
+	select {
+	case events := <-detector.EventsChan():
+		if len(events) != 1 || events[0].GetPlayerJoined() == nil {
+			t.Fatalf("expected PlayerJoined after reset, got %v", events)
+		}
+	default:
+		t.Fatal("expected sensor state to be cleared by Reset")
+	}
+}
+
+func TestAsyncDetector_SnapshotRestore(t *testing.T) {
+	detector := New(WithSynchronousProcessing(), WithSensors(NewPlayerJoinSensor(), &recordingSensor{}))
+	defer detector.Stop()
+
+	frame := createFrameWithPlayers(createPlayer(1, "Alice", 10))
+	frame.Session.GameStatus = GameStatusPlaying
+	detector.ProcessFrame(frame)
+	<-detector.EventsChan()
+
+	snap, err := detector.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if snap.PreviousGameStatus != GameStatusPlaying {
+		t.Errorf("expected previous game status %q, got %q", GameStatusPlaying, snap.PreviousGameStatus)
+	}
+
+	// Snapshots survive a JSON round trip
+	data, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatalf("failed to marshal snapshot: %v", err)
+	}
+	var decoded DetectorSnapshot
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed to unmarshal snapshot: %v", err)
+	}
+
+	restored := New(WithSynchronousProcessing(), WithSensors(NewPlayerJoinSensor(), &recordingSensor{}))
+	defer restored.Stop()
+	if err := restored.Restore(&decoded); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	// Alice is already known and the game is already playing
+	restored.ProcessFrame(createFrameWithPlayers(createPlayer(1, "Alice", 10)))
+	select {
+	case events := <-restored.EventsChan():
+		t.Fatalf("expected no events after restore, got %v", events)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestAsyncDetector_RestoreMismatchedSensors(t *testing.T) {
+	detector := New(WithEventSensors(NewPlayerJoinSensor()))
+	defer detector.Stop()
+
+	snap, err := detector.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	other := New(WithEventSensors(NewPlayerLeaveSensor()))
+	defer other.Stop()
+	if err := other.Restore(snap); err == nil {
+		t.Error("expected error restoring snapshot with different sensors")
+	}
+
+	empty := New()
+	defer empty.Stop()
+	if err := empty.Restore(snap); err == nil {
+		t.Error("expected error restoring snapshot with a different sensor count")
+	}
+}
+
+func TestAsyncDetector_SnapshotAfterStop(t *testing.T) {
+	detector := New()
+	detector.Stop()
+
+	if _, err := detector.Snapshot(); err != ErrDetectorStopped {
+		t.Errorf("expected ErrDetectorStopped, got %v", err)
+	}
+	if err := detector.Restore(&DetectorSnapshot{}); err != ErrDetectorStopped {
+		t.Errorf("expected ErrDetectorStopped, got %v", err)
+	}
+}
+
+// assertResumes checks that a sensor restored from a snapshot taken after
+// split frames reports the same events for the remaining frames as a sensor
+// that saw every frame
+func assertResumes(t *testing.T, newSensor func() CustomEventSensor, frames []*telemetry.LobbySessionStateFrame, split int) {
+	t.Helper()
+
+	var want []*CustomEvent
+	uninterrupted := newSensor()
+	for i, frame := range frames {
+		events := uninterrupted.DetectCustomEvents(frame, nil)
+		if i >= split {
+			want = append(want, events...)
+		}
+	}
+	if len(want) == 0 {
+		t.Fatal("expected events after the split")
+	}
+
+	before := newSensor()
+	for _, frame := range frames[:split] {
+		before.DetectCustomEvents(frame, nil)
+	}
+	data, err := before.(Snapshotter).Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	after := newSensor()
+	if err := after.(Snapshotter).Restore(data); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	var got []*CustomEvent
+	for _, frame := range frames[split:] {
+		got = after.DetectCustomEvents(frame, got)
+	}
+
+	wantJSON, _ := json.Marshal(want)
+	gotJSON, _ := json.Marshal(got)
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("expected %s after restore, got %s", wantJSON, gotJSON)
+	}
+}
+
+func TestMovementSensor_SnapshotRestore(t *testing.T) {
+	var frames []*telemetry.LobbySessionStateFrame
+	for i := range 20 {
+		blue := 4.0
+		if i >= 10 {
+			blue = 8
+		}
+		frames = append(frames, movementFrame(time.Duration(i)*100*time.Millisecond, GameStatusPlaying, blue, 2))
+	}
+	frames = append(frames, movementFrame(2*time.Second, GameStatusPostMatch, 0, 0))
+
+	assertResumes(t, func() CustomEventSensor {
+		return NewMovementSensor(MovementConfig{SpeedThresholds: []float64{3, 5}, BoostAcceleration: 12})
+	}, frames, 12)
+}
+
+func TestConnectionQualitySensor_SnapshotRestore(t *testing.T) {
+	var frames []*telemetry.LobbySessionStateFrame
+	for i := range 40 {
+		ping := int32(50)
+		if i >= 20 {
+			ping = 200
+		}
+		frames = append(frames, connectionFrame(time.Duration(i)*100*time.Millisecond, GameStatusPlaying, ping, 40, 0))
+	}
+
+	assertResumes(t, func() CustomEventSensor {
+		return NewConnectionQualitySensor(ConnectionQualityConfig{
+			Window:          1,
+			PingThresholds:  []int32{150, 100},
+			JitterThreshold: 1000,
+		})
+	}, frames, 25)
+}
+
+func TestStunSensor_SnapshotRestore(t *testing.T) {
+	frames := []*telemetry.LobbySessionStateFrame{
+		stunFrame(0,
+			[]stunPlayer{{slot: 0, blocking: true}},
+			[]stunPlayer{{slot: 1, x: 1}, {slot: 2, x: 10}}),
+		stunFrame(100*time.Millisecond,
+			[]stunPlayer{{slot: 0, stunned: true}},
+			[]stunPlayer{{slot: 1, x: 1}, {slot: 2, x: 10, stuns: 1}}),
+	}
+
+	assertResumes(t, func() CustomEventSensor {
+		return NewStunSensor(DefaultStunConfig())
+	}, frames, 1)
+}
+
+func TestGameClockSensor_SnapshotRestore(t *testing.T) {
+	frames := []*telemetry.LobbySessionStateFrame{clockFrame(0, GameStatusPlaying, 100)}
+	for i := 1; i <= 5; i++ {
+		frames = append(frames, clockFrame(time.Duration(i)*100*time.Millisecond, GameStatusScore, 100))
+	}
+	frames = append(frames, clockFrame(600*time.Millisecond, GameStatusPlaying, 99.9))
+
+	assertResumes(t, func() CustomEventSensor {
+		return NewGameClockSensor(DefaultGameClockConfig())
+	}, frames, 3)
+}
+
+// resetOnlySensor keeps state it can reset but not checkpoint
+type resetOnlySensor struct{ customOnly }
+
+func (resetOnlySensor) Reset() {}
+
+func TestAsyncDetector_SnapshotUncheckpointableSensor(t *testing.T) {
+	detector := New(WithEventSensors(resetOnlySensor{}))
+	defer detector.Stop()
+
+	if _, err := detector.Snapshot(); !errors.Is(err, ErrNotSnapshotter) {
+		t.Errorf("expected ErrNotSnapshotter, got %v", err)
+	}
+}
+
+func TestAsyncDetector_SnapshotRestoreRulesAndHistory(t *testing.T) {
+	newDetector := func() *AsyncDetector {
+		engine, err := NewRuleEngine(Rule{Name: "Blowout", When: "blue_points - orange_points >= 6"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return New(
+			WithSynchronousProcessing(),
+			WithEventSensors(namedSensor{name: "first"}),
+			WithDerivedSensors(historyCounter{}),
+			WithRules(engine),
+		)
+	}
+	frame := func(at time.Duration, blue int32) *telemetry.LobbySessionStateFrame {
+		f := timedFrame(at)
+		f.Session.BluePoints = blue
+		return f
+	}
+
+	detector := newDetector()
+	defer detector.Stop()
+	if _, custom := detector.Detect(frame(0, 6)); len(custom) != 3 || custom[2].Name != "Blowout" {
+		t.Fatalf("expected Blowout on the first frame, got %v", custom)
+	}
+
+	snap, err := detector.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatalf("failed to marshal snapshot: %v", err)
+	}
+	var decoded DetectorSnapshot
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed to unmarshal snapshot: %v", err)
+	}
+
+	restored := newDetector()
+	defer restored.Stop()
+	if err := restored.Restore(&decoded); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	// The rule is still active and the history holds the first frame
+	_, custom := restored.Detect(frame(100*time.Millisecond, 7))
+	if len(custom) != 2 {
+		t.Fatalf("expected Blowout not to fire again after restore, got %v", custom)
+	}
+	if got := custom[1].Fields["history"]; got != 1 {
+		t.Errorf("expected the restored history to hold 1 frame, got %v", got)
+	}
+
+	noRules := New(WithEventSensors(namedSensor{name: "first"}), WithDerivedSensors(historyCounter{}))
+	defer noRules.Stop()
+	if err := noRules.Restore(&decoded); err == nil {
+		t.Error("expected error restoring rule state into a detector without rules")
+	}
+}
@@ -1,6 +1,9 @@
 package events
 
 import (
+	"encoding/json"
+	"slices"
+
 	"github.com/echotools/nevr-common/v4/gen/go/apigame"
 	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
 )
@@ -41,54 +44,149 @@ func snapshotFromStats(stats *apigame.PlayerStats) playerStatSnapshot {
 
 // StatEventSensor detects all stat-based events for players
 type StatEventSensor struct {
-	prevStats map[int32]playerStatSnapshot // keyed by slot number
-	// Queue of pending events (since we can only return one at a time)
-	pendingEvents []*telemetry.LobbySessionEvent
+	playerTracker
+	prevStats map[PlayerKey]playerStatSnapshot
 	// Track previous possessor for steal attribution
 	prevPossessorSlot int32
 	initialized       bool
+	queue             eventQueue
 }
 
 // NewStatEventSensor creates a new StatEventSensor
 func NewStatEventSensor() *StatEventSensor {
 	return &StatEventSensor{
-		prevStats:         make(map[int32]playerStatSnapshot),
-		pendingEvents:     make([]*telemetry.LobbySessionEvent, 0),
+		playerTracker:     newPlayerTracker(),
+		prevStats:         make(map[PlayerKey]playerStatSnapshot),
 		prevPossessorSlot: -1,
 		initialized:       false,
 	}
 }
 
-// AddFrame processes a frame and returns stat events if detected
-func (s *StatEventSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
-	// Return any pending events first
-	if len(s.pendingEvents) > 0 {
-		event := s.pendingEvents[0]
-		s.pendingEvents = s.pendingEvents[1:]
-		return event
+// Reset clears the sensor state
+func (s *StatEventSensor) Reset() {
+	s.reset()
+	s.prevStats = make(map[PlayerKey]playerStatSnapshot)
+	s.prevPossessorSlot = -1
+	s.initialized = false
+	s.queue = eventQueue{}
+}
+
+// statSnapshotState is the serialized form of a playerStatSnapshot
+type statSnapshotState struct {
+	Player        PlayerKey `json:"player"`
+	Goals         int32     `json:"goals"`
+	Saves         int32     `json:"saves"`
+	Stuns         int32     `json:"stuns"`
+	Passes        int32     `json:"passes"`
+	Catches       int32     `json:"catches"`
+	Steals        int32     `json:"steals"`
+	Blocks        int32     `json:"blocks"`
+	Interceptions int32     `json:"interceptions"`
+	Assists       int32     `json:"assists"`
+	ShotsTaken    int32     `json:"shots_taken"`
+	Points        int32     `json:"points"`
+}
+
+// statEventSensorState is the snapshot of a StatEventSensor
+type statEventSensorState struct {
+	PrevStats         []statSnapshotState `json:"prev_stats"`
+	PrevPossessorSlot int32               `json:"prev_possessor_slot"`
+	Initialized       bool                `json:"initialized"`
+	Players           json.RawMessage     `json:"players,omitempty"`
+}
+
+// Snapshot serializes the sensor state
+func (s *StatEventSensor) Snapshot() ([]byte, error) {
+	state := statEventSensorState{
+		PrevStats:         make([]statSnapshotState, 0, len(s.prevStats)),
+		PrevPossessorSlot: s.prevPossessorSlot,
+		Initialized:       s.initialized,
 	}
+	for key, st := range s.prevStats {
+		state.PrevStats = append(state.PrevStats, statSnapshotState{
+			Player:        key,
+			Goals:         st.goals,
+			Saves:         st.saves,
+			Stuns:         st.stuns,
+			Passes:        st.passes,
+			Catches:       st.catches,
+			Steals:        st.steals,
+			Blocks:        st.blocks,
+			Interceptions: st.interceptions,
+			Assists:       st.assists,
+			ShotsTaken:    st.shotsTaken,
+			Points:        st.points,
+		})
+	}
+	slices.SortFunc(state.PrevStats, func(a, b statSnapshotState) int {
+		return comparePlayerKeys(a.Player, b.Player)
+	})
+	players, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	state.Players = players
+	return json.Marshal(state)
+}
 
+// Restore replaces the sensor state with a previous snapshot
+func (s *StatEventSensor) Restore(data []byte) error {
+	var state statEventSensorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	s.Reset()
+	for _, st := range state.PrevStats {
+		s.prevStats[st.Player] = playerStatSnapshot{
+			goals:         st.Goals,
+			saves:         st.Saves,
+			stuns:         st.Stuns,
+			passes:        st.Passes,
+			catches:       st.Catches,
+			steals:        st.Steals,
+			blocks:        st.Blocks,
+			interceptions: st.Interceptions,
+			assists:       st.Assists,
+			shotsTaken:    st.ShotsTaken,
+			points:        st.Points,
+		}
+	}
+	s.prevPossessorSlot = state.PrevPossessorSlot
+	s.initialized = state.Initialized
+	if state.Players != nil {
+		return s.restore(state.Players)
+	}
+	return nil
+}
+
+// AddFrame processes a frame and returns a stat event if detected.
+// Additional events from the same frame are returned by subsequent calls.
+func (s *StatEventSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *telemetry.LobbySessionEvent {
+	return s.queue.next(frame, s.DetectEvents)
+}
+
+// DetectEvents appends an event for every stat increase in the frame. Stats
+// are compared per player identity, so a player taking over a slot does not
+// inherit the previous occupant's totals.
+func (s *StatEventSensor) DetectEvents(frame *telemetry.LobbySessionStateFrame, dst []*telemetry.LobbySessionEvent) []*telemetry.LobbySessionEvent {
 	if frame == nil || frame.GetSession() == nil {
-		return nil
+		return dst
 	}
 
 	// Find current possessor before processing stats
 	currentPossessorSlot := findPossessorSlotFromSession(frame.GetSession())
 
-	// Collect all stat changes
-	for _, team := range frame.GetSession().GetTeams() {
-		for _, player := range team.GetPlayers() {
-			slot := player.GetSlotNumber()
-			current := snapshotFromStats(player.GetStats())
-			prev, existed := s.prevStats[slot]
-
-			if existed {
-				// Check for stat increases and generate events
-				s.checkStatChanges(slot, prev, current, s.prevPossessorSlot)
-			}
-
-			s.prevStats[slot] = current
+	// Collect all stat changes, rebuilding the baselines so departed
+	// players are forgotten
+	previous := s.prevStats
+	s.prevStats = make(map[PlayerKey]playerStatSnapshot, len(previous))
+	for _, player := range s.observe(frame).Roster().Players() {
+		current := snapshotFromStats(player.Member.GetStats())
+		if prev, existed := previous[player.Key]; existed {
+			// Check for stat increases and generate events
+			dst = checkStatChanges(dst, player.Member.GetSlotNumber(), prev, current, s.prevPossessorSlot)
 		}
+		s.prevStats[player.Key] = current
 	}
 
 	// Update previous possessor for next frame
@@ -99,14 +197,7 @@ func (s *StatEventSensor) AddFrame(frame *telemetry.LobbySessionStateFrame) *tel
 		s.initialized = true
 	}
 
-	// Return first pending event if any were generated
-	if len(s.pendingEvents) > 0 {
-		event := s.pendingEvents[0]
-		s.pendingEvents = s.pendingEvents[1:]
-		return event
-	}
-
-	return nil
+	return dst
 }
 
 // findPossessorSlotFromSession finds the slot of the player who has possession, returns -1 if none
@@ -121,8 +212,8 @@ func findPossessorSlotFromSession(session *apigame.SessionResponse) int32 {
 	return -1
 }
 
-// checkStatChanges compares stats and queues events for any increases
-func (s *StatEventSensor) checkStatChanges(slot int32, prev, current playerStatSnapshot, prevPossessorSlot int32) {
+// checkStatChanges compares stats and appends events for any increases
+func checkStatChanges(dst []*telemetry.LobbySessionEvent, slot int32, prev, current playerStatSnapshot, prevPossessorSlot int32) []*telemetry.LobbySessionEvent {
 	// Goals
 	if current.goals > prev.goals {
 		pointsScored := current.points - prev.points
@@ -130,7 +221,7 @@ func (s *StatEventSensor) checkStatChanges(slot int32, prev, current playerStatS
 			pointsScored = 2 // Default to 2 points if we can't determine
 		}
 		for i := int32(0); i < current.goals-prev.goals; i++ {
-			s.pendingEvents = append(s.pendingEvents, &telemetry.LobbySessionEvent{
+			dst = append(dst, &telemetry.LobbySessionEvent{
 				Event: &telemetry.LobbySessionEvent_PlayerGoal{
 					PlayerGoal: &telemetry.PlayerGoal{
 						PlayerSlot: slot,
@@ -145,7 +236,7 @@ func (s *StatEventSensor) checkStatChanges(slot int32, prev, current playerStatS
 	// Saves
 	if current.saves > prev.saves {
 		for i := int32(0); i < current.saves-prev.saves; i++ {
-			s.pendingEvents = append(s.pendingEvents, &telemetry.LobbySessionEvent{
+			dst = append(dst, &telemetry.LobbySessionEvent{
 				Event: &telemetry.LobbySessionEvent_PlayerSave{
 					PlayerSave: &telemetry.PlayerSave{
 						PlayerSlot: slot,
@@ -159,7 +250,7 @@ func (s *StatEventSensor) checkStatChanges(slot int32, prev, current playerStatS
 	// Stuns
 	if current.stuns > prev.stuns {
 		for i := int32(0); i < current.stuns-prev.stuns; i++ {
-			s.pendingEvents = append(s.pendingEvents, &telemetry.LobbySessionEvent{
+			dst = append(dst, &telemetry.LobbySessionEvent{
 				Event: &telemetry.LobbySessionEvent_PlayerStun{
 					PlayerStun: &telemetry.PlayerStun{
 						PlayerSlot: slot,
@@ -173,7 +264,7 @@ func (s *StatEventSensor) checkStatChanges(slot int32, prev, current playerStatS
 	// Passes
 	if current.passes > prev.passes {
 		for i := int32(0); i < current.passes-prev.passes; i++ {
-			s.pendingEvents = append(s.pendingEvents, &telemetry.LobbySessionEvent{
+			dst = append(dst, &telemetry.LobbySessionEvent{
 				Event: &telemetry.LobbySessionEvent_PlayerPass{
 					PlayerPass: &telemetry.PlayerPass{
 						PlayerSlot:  slot,
@@ -187,7 +278,7 @@ func (s *StatEventSensor) checkStatChanges(slot int32, prev, current playerStatS
 	// Steals
 	if current.steals > prev.steals {
 		for i := int32(0); i < current.steals-prev.steals; i++ {
-			s.pendingEvents = append(s.pendingEvents, &telemetry.LobbySessionEvent{
+			dst = append(dst, &telemetry.LobbySessionEvent{
 				Event: &telemetry.LobbySessionEvent_PlayerSteal{
 					PlayerSteal: &telemetry.PlayerSteal{
 						PlayerSlot:       slot,
@@ -202,7 +293,7 @@ func (s *StatEventSensor) checkStatChanges(slot int32, prev, current playerStatS
 	// Blocks
 	if current.blocks > prev.blocks {
 		for i := int32(0); i < current.blocks-prev.blocks; i++ {
-			s.pendingEvents = append(s.pendingEvents, &telemetry.LobbySessionEvent{
+			dst = append(dst, &telemetry.LobbySessionEvent{
 				Event: &telemetry.LobbySessionEvent_PlayerBlock{
 					PlayerBlock: &telemetry.PlayerBlock{
 						PlayerSlot:  slot,
@@ -216,7 +307,7 @@ func (s *StatEventSensor) checkStatChanges(slot int32, prev, current playerStatS
 	// Interceptions
 	if current.interceptions > prev.interceptions {
 		for i := int32(0); i < current.interceptions-prev.interceptions; i++ {
-			s.pendingEvents = append(s.pendingEvents, &telemetry.LobbySessionEvent{
+			dst = append(dst, &telemetry.LobbySessionEvent{
 				Event: &telemetry.LobbySessionEvent_PlayerInterception{
 					PlayerInterception: &telemetry.PlayerInterception{
 						PlayerSlot:         slot,
@@ -230,7 +321,7 @@ func (s *StatEventSensor) checkStatChanges(slot int32, prev, current playerStatS
 	// Assists
 	if current.assists > prev.assists {
 		for i := int32(0); i < current.assists-prev.assists; i++ {
-			s.pendingEvents = append(s.pendingEvents, &telemetry.LobbySessionEvent{
+			dst = append(dst, &telemetry.LobbySessionEvent{
 				Event: &telemetry.LobbySessionEvent_PlayerAssist{
 					PlayerAssist: &telemetry.PlayerAssist{
 						PlayerSlot:   slot,
@@ -244,7 +335,7 @@ func (s *StatEventSensor) checkStatChanges(slot int32, prev, current playerStatS
 	// Shots Taken
 	if current.shotsTaken > prev.shotsTaken {
 		for i := int32(0); i < current.shotsTaken-prev.shotsTaken; i++ {
-			s.pendingEvents = append(s.pendingEvents, &telemetry.LobbySessionEvent{
+			dst = append(dst, &telemetry.LobbySessionEvent{
 				Event: &telemetry.LobbySessionEvent_PlayerShotTaken{
 					PlayerShotTaken: &telemetry.PlayerShotTaken{
 						PlayerSlot: slot,
@@ -254,4 +345,6 @@ func (s *StatEventSensor) checkStatChanges(slot int32, prev, current playerStatS
 			})
 		}
 	}
+
+	return dst
 }
@@ -442,3 +442,43 @@ func TestSnapshotFromStats_WithStats(t *testing.T) {
 		t.Errorf("expected points=8, got %d", snapshot.points)
 	}
 }
+
+func TestStatEventSensor_DetectEventsReportsAllEventsInFrame(t *testing.T) {
+	sensor := NewStatEventSensor()
+	sensor.DetectEvents(createFrameWithPlayerStats(1, &apigame.PlayerStats{}), nil)
+
+	events := sensor.DetectEvents(createFrameWithPlayerStats(1, &apigame.PlayerStats{
+		Stuns:  2,
+		Passes: 1,
+	}), nil)
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events in the same frame, got %d", len(events))
+	}
+
+	// Nothing is left over for the next frame
+	events = sensor.DetectEvents(createFrameWithPlayerStats(1, &apigame.PlayerStats{
+		Stuns:  2,
+		Passes: 1,
+	}), nil)
+	if len(events) != 0 {
+		t.Errorf("expected no events on the next frame, got %d", len(events))
+	}
+}
+
+func TestStatEventSensor_SlotReuseDoesNotInheritStats(t *testing.T) {
+	sensor := NewStatEventSensor()
+
+	alice := &apigame.TeamMember{SlotNumber: 2, AccountNumber: 1, Stats: &apigame.PlayerStats{Goals: 3}}
+	bob := &apigame.TeamMember{SlotNumber: 2, AccountNumber: 2, Stats: &apigame.PlayerStats{Goals: 5}}
+
+	sensor.DetectEvents(createFrameWithPlayers(alice), nil)
+	if events := sensor.DetectEvents(createFrameWithPlayers(bob), nil); len(events) != 0 {
+		t.Errorf("expected no events when a new player takes the slot, got %v", events)
+	}
+
+	bob.Stats = &apigame.PlayerStats{Goals: 6}
+	events := sensor.DetectEvents(createFrameWithPlayers(bob), nil)
+	if len(events) != 1 || events[0].GetPlayerGoal().GetTotalGoals() != 6 {
+		t.Errorf("expected Bob's goal, got %v", events)
+	}
+}
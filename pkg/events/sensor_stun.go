@@ -0,0 +1,273 @@
+package events
+
+import (
+	"encoding/json"
+	"slices"
+
+	"github.com/echotools/nevr-capture/v3/pkg/arena"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// Custom events reported by the StunSensor
+const (
+	// EventPlayerStunned is a player becoming stunned. Fields: victim_slot,
+	// victim_team, attacker_slot (-1 if unknown), attribution ("stat" when
+	// the attacker's stun count went up, "proximity" for the nearest
+	// opponent, or "none"), was_blocking and was_invulnerable (the victim's
+	// state in the previous frame), x, y, z.
+	EventPlayerStunned = "player_stunned"
+	// EventStunChain is several stuns of one team's players in quick
+	// succession. Fields: stuns, duration (seconds), victims and attackers
+	// ([]int32 slots in stun order, attackers -1 when unknown).
+	EventStunChain = "stun_chain"
+	// EventTeamfight is a stun chain in which players of both teams were
+	// stunned. It has the fields of EventStunChain.
+	EventTeamfight = "teamfight"
+)
+
+// StunConfig configures a StunSensor
+type StunConfig struct {
+	// AttackerRange is how close, in meters, an opponent must be to be
+	// considered the attacker when no stun count went up
+	AttackerRange float64 `json:"attacker_range"`
+	// ChainWindow is the most seconds between two stuns of the same chain
+	ChainWindow float64 `json:"chain_window"`
+	// MinChainStuns is the fewest stuns reported as a chain
+	MinChainStuns int `json:"min_chain_stuns"`
+}
+
+// DefaultStunConfig returns the default stun attribution and chain settings
+func DefaultStunConfig() StunConfig {
+	return StunConfig{AttackerRange: 4, ChainWindow: 3, MinChainStuns: 2}
+}
+
+// StunSensor reports victim-side stuns from each player's stunned flag and
+// pairs them with the likely attacker. Stuns in quick succession are
+// collapsed into stun chains, or teamfights when both teams were hit.
+type StunSensor struct {
+	customOnly
+	playerTracker
+	config StunConfig
+
+	prev  map[PlayerKey]stunState
+	chain []chainStun
+	last  FrameTime
+}
+
+// stunState is a player's state in the previous frame
+type stunState struct {
+	stunned      bool
+	blocking     bool
+	invulnerable bool
+	stuns        int32
+}
+
+// chainStun is one stun of a chain
+type chainStun struct {
+	victim, attacker int32
+	team             telemetry.Role
+	at               FrameTime
+}
+
+var _ CustomEventSensor = (*StunSensor)(nil)
+
+// NewStunSensor creates a new StunSensor
+func NewStunSensor(cfg StunConfig) *StunSensor {
+	return &StunSensor{playerTracker: newPlayerTracker(), config: cfg, prev: make(map[PlayerKey]stunState)}
+}
+
+// Reset clears the sensor state
+func (s *StunSensor) Reset() {
+	s.reset()
+	s.prev = make(map[PlayerKey]stunState)
+	s.chain = nil
+}
+
+// stunSensorState is the snapshot of a StunSensor
+type stunSensorState struct {
+	Prev    []stunPlayerState `json:"prev"`
+	Chain   []chainStunState  `json:"chain,omitempty"`
+	Last    FrameTime         `json:"last"`
+	Players json.RawMessage   `json:"players,omitempty"`
+}
+
+type stunPlayerState struct {
+	Player       PlayerKey `json:"player"`
+	Stunned      bool      `json:"stunned"`
+	Blocking     bool      `json:"blocking"`
+	Invulnerable bool      `json:"invulnerable"`
+	Stuns        int32     `json:"stuns"`
+}
+
+type chainStunState struct {
+	Victim   int32          `json:"victim"`
+	Attacker int32          `json:"attacker"`
+	Team     telemetry.Role `json:"team"`
+	At       FrameTime      `json:"at"`
+}
+
+// Snapshot serializes the sensor state
+func (s *StunSensor) Snapshot() ([]byte, error) {
+	players, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	state := stunSensorState{Prev: make([]stunPlayerState, 0, len(s.prev)), Last: s.last, Players: players}
+	for key, st := range s.prev {
+		state.Prev = append(state.Prev, stunPlayerState{
+			Player:       key,
+			Stunned:      st.stunned,
+			Blocking:     st.blocking,
+			Invulnerable: st.invulnerable,
+			Stuns:        st.stuns,
+		})
+	}
+	slices.SortFunc(state.Prev, func(a, b stunPlayerState) int { return comparePlayerKeys(a.Player, b.Player) })
+	for _, c := range s.chain {
+		state.Chain = append(state.Chain, chainStunState{Victim: c.victim, Attacker: c.attacker, Team: c.team, At: c.at})
+	}
+	return json.Marshal(state)
+}
+
+// Restore replaces the sensor state with a previous snapshot
+func (s *StunSensor) Restore(data []byte) error {
+	var state stunSensorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	s.Reset()
+	for _, p := range state.Prev {
+		s.prev[p.Player] = stunState{stunned: p.Stunned, blocking: p.Blocking, invulnerable: p.Invulnerable, stuns: p.Stuns}
+	}
+	for _, c := range state.Chain {
+		s.chain = append(s.chain, chainStun{victim: c.Victim, attacker: c.Attacker, team: c.Team, at: c.At})
+	}
+	s.last = state.Last
+	if state.Players != nil {
+		return s.restore(state.Players)
+	}
+	return nil
+}
+
+// DetectCustomEvents appends the stuns of the frame and any stun chain that
+// ended
+func (s *StunSensor) DetectCustomEvents(frame *telemetry.LobbySessionStateFrame, dst []*CustomEvent) []*CustomEvent {
+	if frame == nil || frame.GetSession() == nil {
+		return dst
+	}
+	roster := s.observe(frame).Roster()
+	now := FrameTimeOf(frame)
+
+	if len(s.chain) > 0 && (frame.GetSession().GetGameStatus() != GameStatusPlaying || now.Since(s.last) > s.config.ChainWindow) {
+		dst = s.flushChain(dst)
+	}
+
+	// Attackers whose stun count went up this frame
+	var stunners []RosterPlayer
+	current := make(map[PlayerKey]stunState, roster.Len())
+	for _, player := range roster.Players() {
+		if !isTeamRole(player.Role) {
+			continue
+		}
+		m := player.Member
+		state := stunState{
+			stunned:      m.GetIsStunned(),
+			blocking:     m.GetIsBlocking(),
+			invulnerable: m.GetIsInvulnerable(),
+			stuns:        m.GetStats().GetStuns(),
+		}
+		current[player.Key] = state
+		if prev, ok := s.prev[player.Key]; ok && state.stuns > prev.stuns {
+			stunners = append(stunners, player)
+		}
+	}
+
+	for _, victim := range roster.Players() {
+		state, ok := current[victim.Key]
+		prev, seen := s.prev[victim.Key]
+		if !ok || !seen || !state.stunned || prev.stunned {
+			continue
+		}
+
+		attacker, attribution := s.attackerOf(victim, roster, &stunners)
+		pos, _ := arena.PlayerPosition(victim.Member)
+		dst = append(dst, &CustomEvent{
+			Name: EventPlayerStunned,
+			Fields: map[string]any{
+				"victim_slot":      victim.Member.GetSlotNumber(),
+				"victim_team":      victim.Role.String(),
+				"attacker_slot":    attacker,
+				"attribution":      attribution,
+				"was_blocking":     prev.blocking,
+				"was_invulnerable": prev.invulnerable,
+				"x":                pos.X,
+				"y":                pos.Y,
+				"z":                pos.Z,
+			},
+		})
+		s.chain = append(s.chain, chainStun{victim: victim.Member.GetSlotNumber(), attacker: attacker, team: victim.Role, at: now})
+		s.last = now
+	}
+
+	s.prev = current
+	return dst
+}
+
+// attackerOf picks the likely attacker of a victim: an opponent whose stun
+// count went up, which is then consumed, or else the nearest opponent in
+// range
+func (s *StunSensor) attackerOf(victim RosterPlayer, roster *Roster, stunners *[]RosterPlayer) (int32, string) {
+	for i, p := range *stunners {
+		if p.Role != victim.Role {
+			*stunners = slices.Delete(*stunners, i, i+1)
+			return p.Member.GetSlotNumber(), "stat"
+		}
+	}
+
+	at, ok := arena.PlayerPosition(victim.Member)
+	if !ok {
+		return -1, "none"
+	}
+	slot, best := int32(-1), s.config.AttackerRange
+	for _, p := range roster.Players() {
+		if !isTeamRole(p.Role) || p.Role == victim.Role {
+			continue
+		}
+		if pos, ok := arena.PlayerPosition(p.Member); ok && pos.Dist(at) <= best {
+			slot, best = p.Member.GetSlotNumber(), pos.Dist(at)
+		}
+	}
+	if slot == -1 {
+		return -1, "none"
+	}
+	return slot, "proximity"
+}
+
+// flushChain reports the current chain if it is long enough and starts a
+// new one
+func (s *StunSensor) flushChain(dst []*CustomEvent) []*CustomEvent {
+	chain := s.chain
+	s.chain = nil
+	if len(chain) < max(s.config.MinChainStuns, 2) {
+		return dst
+	}
+
+	name := EventStunChain
+	victims := make([]int32, len(chain))
+	attackers := make([]int32, len(chain))
+	for i, c := range chain {
+		victims[i], attackers[i] = c.victim, c.attacker
+		if c.team != chain[0].team {
+			name = EventTeamfight
+		}
+	}
+	return append(dst, &CustomEvent{
+		Name: name,
+		Fields: map[string]any{
+			"stuns":     len(chain),
+			"duration":  chain[len(chain)-1].at.Since(chain[0].at),
+			"victims":   victims,
+			"attackers": attackers,
+		},
+	})
+}
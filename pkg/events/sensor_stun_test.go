@@ -0,0 +1,140 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// stunPlayer describes a player of a stun test frame
+type stunPlayer struct {
+	slot     int32
+	x        float64
+	stunned  bool
+	blocking bool
+	stuns    int32
+}
+
+func (p stunPlayer) member() *apigame.TeamMember {
+	return &apigame.TeamMember{
+		SlotNumber:  p.slot,
+		DisplayName: "P" + string(rune('0'+p.slot)),
+		Body:        &apigame.BodyPart{Position: []float64{p.x, 0, 0}},
+		IsStunned:   p.stunned,
+		IsBlocking:  p.blocking,
+		Stats:       &apigame.PlayerStats{Stuns: p.stuns},
+	}
+}
+
+func stunFrame(at time.Duration, blue, orange []stunPlayer) *telemetry.LobbySessionStateFrame {
+	var b, o []*apigame.TeamMember
+	for _, p := range blue {
+		b = append(b, p.member())
+	}
+	for _, p := range orange {
+		o = append(o, p.member())
+	}
+	frame := createFrameWithTeams(b, o, nil)
+	frame.Timestamp = timestamppb.New(bonesEpoch.Add(at))
+	frame.Session.GameStatus = GameStatusPlaying
+	return frame
+}
+
+func TestStunSensor_AttributesByStunCount(t *testing.T) {
+	sensor := NewStunSensor(DefaultStunConfig())
+
+	sensor.DetectCustomEvents(stunFrame(0,
+		[]stunPlayer{{slot: 0, blocking: true}},
+		[]stunPlayer{{slot: 1, x: 1}, {slot: 2, x: 10}}), nil)
+	events := sensor.DetectCustomEvents(stunFrame(100*time.Millisecond,
+		[]stunPlayer{{slot: 0, stunned: true}},
+		[]stunPlayer{{slot: 1, x: 1}, {slot: 2, x: 10, stuns: 1}}), nil)
+
+	if len(events) != 1 || events[0].Name != EventPlayerStunned {
+		t.Fatalf("expected 1 stun, got %v", events)
+	}
+	fields := events[0].Fields
+	if fields["victim_slot"] != int32(0) || fields["attacker_slot"] != int32(2) || fields["attribution"] != "stat" {
+		t.Errorf("expected slot 2 credited by stun count, got %v", fields)
+	}
+	if fields["was_blocking"] != true {
+		t.Errorf("expected the victim to have been blocking, got %v", fields["was_blocking"])
+	}
+}
+
+func TestStunSensor_AttributesByProximity(t *testing.T) {
+	sensor := NewStunSensor(DefaultStunConfig())
+
+	sensor.DetectCustomEvents(stunFrame(0, []stunPlayer{{slot: 0}}, []stunPlayer{{slot: 1, x: 2}, {slot: 2, x: 3}}), nil)
+	events := sensor.DetectCustomEvents(stunFrame(100*time.Millisecond,
+		[]stunPlayer{{slot: 0, stunned: true}},
+		[]stunPlayer{{slot: 1, x: 2}, {slot: 2, x: 3}}), nil)
+
+	if len(events) != 1 || events[0].Fields["attacker_slot"] != int32(1) || events[0].Fields["attribution"] != "proximity" {
+		t.Fatalf("expected nearest opponent as attacker, got %v", events)
+	}
+
+	// A stun that lasts several frames is reported once
+	if events := sensor.DetectCustomEvents(stunFrame(200*time.Millisecond,
+		[]stunPlayer{{slot: 0, stunned: true}},
+		[]stunPlayer{{slot: 1, x: 2}, {slot: 2, x: 3}}), nil); len(events) != 0 {
+		t.Errorf("expected no new stun, got %v", events)
+	}
+}
+
+func TestStunSensor_ChainsAndTeamfights(t *testing.T) {
+	tests := []struct {
+		name   string
+		frames [][2][]stunPlayer
+		want   string
+	}{
+		{
+			name: "chain",
+			frames: [][2][]stunPlayer{
+				{{{slot: 0}, {slot: 2}}, {{slot: 1}}},
+				{{{slot: 0, stunned: true}, {slot: 2}}, {{slot: 1}}},
+				{{{slot: 0, stunned: true}, {slot: 2, stunned: true}}, {{slot: 1}}},
+			},
+			want: EventStunChain,
+		},
+		{
+			name: "teamfight",
+			frames: [][2][]stunPlayer{
+				{{{slot: 0}}, {{slot: 1}}},
+				{{{slot: 0, stunned: true}}, {{slot: 1}}},
+				{{{slot: 0, stunned: true}}, {{slot: 1, stunned: true}}},
+			},
+			want: EventTeamfight,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sensor := NewStunSensor(DefaultStunConfig())
+			var events []*CustomEvent
+			for i, f := range tt.frames {
+				events = sensor.DetectCustomEvents(stunFrame(time.Duration(i)*time.Second, f[0], f[1]), events)
+			}
+			// The chain is reported once the window has passed
+			last := tt.frames[len(tt.frames)-1]
+			events = sensor.DetectCustomEvents(stunFrame(10*time.Second, last[0], last[1]), events)
+
+			chain := events[len(events)-1]
+			if chain.Name != tt.want || chain.Fields["stuns"] != 2 || chain.Fields["duration"] != 1.0 {
+				t.Errorf("expected %s of 2 stuns over 1s, got %+v", tt.want, chain)
+			}
+		})
+	}
+}
+
+func TestStunSensor_SingleStunIsNotAChain(t *testing.T) {
+	sensor := NewStunSensor(DefaultStunConfig())
+
+	sensor.DetectCustomEvents(stunFrame(0, []stunPlayer{{slot: 0}}, nil), nil)
+	sensor.DetectCustomEvents(stunFrame(time.Second, []stunPlayer{{slot: 0, stunned: true}}, nil), nil)
+	if events := sensor.DetectCustomEvents(stunFrame(10*time.Second, []stunPlayer{{slot: 0}}, nil), nil); len(events) != 0 {
+		t.Errorf("expected no chain for a single stun, got %v", events)
+	}
+}
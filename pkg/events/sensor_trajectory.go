@@ -0,0 +1,130 @@
+package events
+
+import (
+	"github.com/echotools/nevr-capture/v3/pkg/arena"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// Custom events reported by the DiscTrajectorySensor
+const (
+	// EventDiscBounce is the disc bouncing off a wall. Fields: x, y, z,
+	// speed (m/s), angle (degrees).
+	EventDiscBounce = "disc_bounce"
+	// EventDiscDeflection is the disc changing direction away from the
+	// walls, e.g. blocked or tapped. Fields: x, y, z, speed, angle, and
+	// nearest_player_slot, the likely deflector or -1.
+	EventDiscDeflection = "disc_deflection"
+	// EventDiscRest is the disc coming to rest. Fields: x, y, z.
+	EventDiscRest = "disc_rest"
+	// EventDiscFlight is a completed flight. Fields: thrower_slot,
+	// catcher_slot, start_frame, end_frame, duration (seconds), distance
+	// (meters), max_speed, bounces, deflections, ended_by, segments
+	// ([]FlightSegment) and thrown. When thrown is true the flight is the
+	// path of a DiscThrown event: throw_frame is the FrameIndex of that
+	// event's envelope and throw_speed its speed.
+	EventDiscFlight = "disc_flight"
+)
+
+// DiscTrajectorySensor reports bounces, deflections, the disc coming to rest
+// and the completed flights of a DiscTracker
+type DiscTrajectorySensor struct {
+	customOnly
+	tracker *DiscTracker
+}
+
+var _ CustomEventSensor = (*DiscTrajectorySensor)(nil)
+
+// NewDiscTrajectorySensor creates a new DiscTrajectorySensor following the
+// disc with tracker, which may be shared with a PassSensor
+func NewDiscTrajectorySensor(tracker *DiscTracker) *DiscTrajectorySensor {
+	return &DiscTrajectorySensor{tracker: tracker}
+}
+
+// Reset clears the sensor state
+func (s *DiscTrajectorySensor) Reset() {
+	s.tracker.Reset()
+}
+
+// Snapshot serializes the sensor state
+func (s *DiscTrajectorySensor) Snapshot() ([]byte, error) {
+	return s.tracker.Snapshot()
+}
+
+// Restore replaces the sensor state with a previous snapshot
+func (s *DiscTrajectorySensor) Restore(data []byte) error {
+	return s.tracker.Restore(data)
+}
+
+// DetectCustomEvents appends the disc events of the frame
+func (s *DiscTrajectorySensor) DetectCustomEvents(frame *telemetry.LobbySessionStateFrame, dst []*CustomEvent) []*CustomEvent {
+	if frame == nil || frame.GetSession() == nil {
+		return dst
+	}
+	r := s.tracker.Update(frame)
+	pos := r.Position
+
+	switch {
+	case r.Bounce:
+		dst = append(dst, &CustomEvent{
+			Name:   EventDiscBounce,
+			Fields: map[string]any{"x": pos.X, "y": pos.Y, "z": pos.Z, "speed": r.Speed, "angle": r.Angle},
+		})
+	case r.Deflection:
+		dst = append(dst, &CustomEvent{
+			Name: EventDiscDeflection,
+			Fields: map[string]any{
+				"x": pos.X, "y": pos.Y, "z": pos.Z, "speed": r.Speed, "angle": r.Angle,
+				"nearest_player_slot": nearestPlayerSlot(frame, pos),
+			},
+		})
+	}
+
+	if f := r.Completed; f != nil {
+		if f.EndedBy == FlightEndRest {
+			dst = append(dst, &CustomEvent{
+				Name:   EventDiscRest,
+				Fields: map[string]any{"x": pos.X, "y": pos.Y, "z": pos.Z},
+			})
+		}
+		fields := map[string]any{
+			"thrower_slot": f.ThrowerSlot,
+			"catcher_slot": f.CatcherSlot,
+			"start_frame":  f.StartFrame,
+			"end_frame":    f.EndFrame,
+			"duration":     f.Duration,
+			"distance":     f.Distance,
+			"max_speed":    f.MaxSpeed,
+			"bounces":      f.Bounces,
+			"deflections":  f.Deflections,
+			"ended_by":     f.EndedBy,
+			"segments":     f.Segments,
+			"thrown":       f.Thrown,
+		}
+		if f.Thrown {
+			fields["throw_frame"] = f.ThrowFrame
+			fields["throw_speed"] = f.ThrowSpeed
+		}
+		dst = append(dst, &CustomEvent{Name: EventDiscFlight, Fields: fields})
+	}
+	return dst
+}
+
+// nearestPlayerSlot returns the slot of the player closest to pos, or -1
+func nearestPlayerSlot(frame *telemetry.LobbySessionStateFrame, pos arena.Vec3) int32 {
+	slot, best := int32(-1), 0.0
+	for i, team := range frame.GetSession().GetTeams() {
+		if !isTeamRole(TeamRole(i, team)) {
+			continue
+		}
+		for _, player := range team.GetPlayers() {
+			p, ok := arena.PlayerPosition(player)
+			if !ok {
+				continue
+			}
+			if d := p.Dist(pos); slot == -1 || d < best {
+				slot, best = player.GetSlotNumber(), d
+			}
+		}
+	}
+	return slot
+}
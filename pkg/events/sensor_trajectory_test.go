@@ -0,0 +1,87 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/echotools/nevr-capture/v3/pkg/arena"
+	"github.com/echotools/nevr-common/v4/gen/go/apigame"
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+func TestDiscTrajectorySensor_ReportsEvents(t *testing.T) {
+	sensor := NewDiscTrajectorySensor(NewDiscTracker(DefaultDiscTrackerConfig()))
+
+	frames := []discState{
+		{pos: arena.Vec3{X: -1, Z: -30}, holder: 0},
+		{pos: arena.Vec3{X: -1, Z: -20}, vel: arena.Vec3{Z: 10}, holder: -1},
+		// Deflected next to the orange player standing at (2, 0, -30)
+		{pos: arena.Vec3{X: 1, Z: -29}, vel: arena.Vec3{Z: 10}, holder: -1},
+		{pos: arena.Vec3{X: 1.5, Z: -29.5}, vel: arena.Vec3{X: 10}, holder: -1},
+		{pos: arena.Vec3{X: 3, Z: -29.5}, holder: 1},
+	}
+	var events []*CustomEvent
+	for i, d := range frames {
+		events = sensor.DetectCustomEvents(discFrame(uint32(i), d), events)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected a deflection and a flight, got %v", events)
+	}
+	if events[0].Name != EventDiscDeflection || events[0].Fields["nearest_player_slot"] != int32(1) {
+		t.Errorf("expected deflection by slot 1, got %+v", events[0])
+	}
+	flight := events[1]
+	if flight.Name != EventDiscFlight || flight.Fields["thrower_slot"] != int32(0) || flight.Fields["catcher_slot"] != int32(1) {
+		t.Errorf("unexpected flight %+v", flight)
+	}
+	if segments := flight.Fields["segments"].([]FlightSegment); len(segments) != 2 {
+		t.Errorf("expected 2 segments, got %d", len(segments))
+	}
+}
+
+func TestDiscTrajectorySensor_ReportsRest(t *testing.T) {
+	sensor := NewDiscTrajectorySensor(NewDiscTracker(DefaultDiscTrackerConfig()))
+
+	var events []*CustomEvent
+	events = sensor.DetectCustomEvents(discFrame(0, discState{vel: arena.Vec3{Y: 2}, holder: -1}), events)
+	for i := uint32(1); i < 15; i++ {
+		events = sensor.DetectCustomEvents(discFrame(i, discState{pos: arena.Vec3{Y: 1}, holder: -1}), events)
+	}
+
+	if len(events) != 2 || events[0].Name != EventDiscRest || events[1].Fields["ended_by"] != FlightEndRest {
+		t.Fatalf("expected rest followed by the flight, got %v", events)
+	}
+	if events[0].Fields["y"] != 1.0 {
+		t.Errorf("expected rest position y=1, got %v", events[0].Fields["y"])
+	}
+}
+
+func TestDiscTrajectorySensor_SharesTrackerWithPassSensor(t *testing.T) {
+	tracker := NewDiscTracker(DefaultDiscTrackerConfig())
+	trajectory := NewDiscTrajectorySensor(tracker)
+	pass := NewPassSensor(tracker)
+	throw := &apigame.LastThrowInfo{TotalSpeed: 12}
+
+	frames := []*telemetry.LobbySessionStateFrame{
+		passFrame(0, discState{holder: 0}, nil),
+		passFrame(1, discState{vel: arena.Vec3{Z: 12}, holder: -1}, throw),
+		passFrame(2, discState{vel: arena.Vec3{Z: 12}, holder: -1}, throw),
+		passFrame(3, discState{holder: 2}, throw),
+	}
+	var events []*CustomEvent
+	for _, frame := range frames {
+		events = trajectory.DetectCustomEvents(frame, events)
+		events = pass.DetectCustomEvents(frame, events)
+	}
+
+	if len(events) != 2 || events[0].Name != EventDiscFlight || events[1].Name != EventPass {
+		t.Fatalf("expected a flight and a pass from the shared tracker, got %v", events)
+	}
+	flight, passed := events[0].Fields, events[1].Fields
+	if flight["thrown"] != true || flight["throw_frame"] != uint32(1) || passed["throw_frame"] != uint32(1) {
+		t.Errorf("expected both events to link the throw of frame 1, got %v and %v", flight, passed)
+	}
+	if passed["air_time"] != flight["duration"] {
+		t.Errorf("expected the pass to span the flight, got %v and %v", passed["air_time"], flight["duration"])
+	}
+}
@@ -0,0 +1,102 @@
+package events
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
+)
+
+// DetectorStats reports throughput and backpressure counters of a detector
+type DetectorStats struct {
+	// FramesReceived is the number of frames passed to ProcessFrame
+	FramesReceived uint64
+	// FramesDropped is the number of frames discarded because the input
+	// queue was full
+	FramesDropped uint64
+	// EventBatchesDropped is the number of event batches discarded because
+	// the events channel was full
+	EventBatchesDropped uint64
+	// InputQueueDepth is the number of frames waiting to be processed
+	InputQueueDepth int
+	// EventQueueDepth is the number of event batches waiting to be consumed
+	EventQueueDepth int
+	// Sensors reports the processing time of each registered sensor in
+	// detection order
+	Sensors []SensorStats
+}
+
+// SensorStats reports the cumulative processing time of a sensor
+type SensorStats struct {
+	// Type is the Go type of the sensor
+	Type string
+	// Frames is the number of frames the sensor has processed
+	Frames uint64
+	// ProcessingTime is the total time spent in the sensor
+	ProcessingTime time.Duration
+}
+
+// detectorCounters holds the atomic counters behind DetectorStats
+type detectorCounters struct {
+	framesReceived      atomic.Uint64
+	framesDropped       atomic.Uint64
+	eventBatchesDropped atomic.Uint64
+}
+
+// sensorEntry is a registered sensor and its timing counters
+type sensorEntry struct {
+	sensor EventSensor
+	// custom is set when the sensor also reports custom events
+	custom CustomEventSensor
+	frames atomic.Uint64
+	nanos  atomic.Int64
+
+	// buf collects the sensor's events when sensors run in parallel
+	buf       []*telemetry.LobbySessionEvent
+	customBuf []*CustomEvent
+
+	// usesPlayers is set when the sensor reads the detector's player registry
+	usesPlayers bool
+}
+
+func (e *sensorEntry) usesPlayerRegistry() bool {
+	return e.usesPlayers
+}
+
+func (e *sensorEntry) reportsCustom() bool {
+	return e.custom != nil
+}
+
+// newSensorEntries wraps sensors for registration
+func newSensorEntries(sensors ...EventSensor) []*sensorEntry {
+	entries := make([]*sensorEntry, len(sensors))
+	for i, s := range sensors {
+		entries[i] = &sensorEntry{sensor: s}
+		entries[i].custom, _ = s.(CustomEventSensor)
+	}
+	return entries
+}
+
+// record adds one frame's processing time to the entry
+func (e *sensorEntry) record(d time.Duration) {
+	e.frames.Add(1)
+	e.nanos.Add(int64(d))
+}
+
+// stats returns a snapshot of the entry's counters
+func (e *sensorEntry) stats() SensorStats {
+	return SensorStats{
+		Type:           sensorTypeName(e.sensor),
+		Frames:         e.frames.Load(),
+		ProcessingTime: time.Duration(e.nanos.Load()),
+	}
+}
+
+// sensorsOf returns the sensors of the given entries
+func sensorsOf(entries []*sensorEntry) []EventSensor {
+	sensors := make([]EventSensor, len(entries))
+	for i, e := range entries {
+		sensors[i] = e.sensor
+	}
+	return sensors
+}
@@ -0,0 +1,85 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAsyncDetector_StatsCountsDroppedFrames(t *testing.T) {
+	detector := New(WithInputChannelSize(1))
+	defer detector.Stop()
+
+	release := holdProcessLoop(detector)
+
+	frame := newStatusOnlyFrame(GameStatusPlaying)
+	for range 3 {
+		detector.ProcessFrame(frame)
+	}
+
+	stats := detector.Stats()
+	if stats.FramesReceived != 3 {
+		t.Errorf("expected 3 frames received, got %d", stats.FramesReceived)
+	}
+	if stats.FramesDropped != 2 {
+		t.Errorf("expected 2 frames dropped, got %d", stats.FramesDropped)
+	}
+	if stats.InputQueueDepth != 1 {
+		t.Errorf("expected input queue depth 1, got %d", stats.InputQueueDepth)
+	}
+	release()
+}
+
+func TestAsyncDetector_StatsCountsDroppedEventBatches(t *testing.T) {
+	detector := New(
+		WithSynchronousProcessing(),
+		WithEventsChannelSize(1),
+		WithSensors(&mockSensor{id: "s1"}),
+	)
+	defer detector.Stop()
+
+	frame := newStatusOnlyFrame(GameStatusPlaying)
+	for range 3 {
+		detector.ProcessFrame(frame)
+	}
+
+	stats := detector.Stats()
+	if stats.EventBatchesDropped != 2 {
+		t.Errorf("expected 2 event batches dropped, got %d", stats.EventBatchesDropped)
+	}
+	if stats.EventQueueDepth != 1 {
+		t.Errorf("expected event queue depth 1, got %d", stats.EventQueueDepth)
+	}
+	if stats.FramesDropped != 0 {
+		t.Errorf("expected no dropped frames in synchronous mode, got %d", stats.FramesDropped)
+	}
+}
+
+func TestAsyncDetector_StatsSensorTiming(t *testing.T) {
+	detector := New(
+		WithSynchronousProcessing(),
+		WithSensors(&mockSensor{id: "s1"}),
+		WithEventSensors(NewPlayerJoinSensor()),
+	)
+	defer detector.Stop()
+
+	frame := newStatusOnlyFrame(GameStatusPlaying)
+	for range 5 {
+		detector.ProcessFrame(frame)
+	}
+
+	sensors := detector.Stats().Sensors
+	if len(sensors) != 2 {
+		t.Fatalf("expected stats for 2 sensors, got %d", len(sensors))
+	}
+	if sensors[0].Type != "*events.mockSensor" || sensors[1].Type != "*events.PlayerJoinSensor" {
+		t.Errorf("unexpected sensor types %q, %q", sensors[0].Type, sensors[1].Type)
+	}
+	for _, s := range sensors {
+		if s.Frames != 5 {
+			t.Errorf("%s: expected 5 frames, got %d", s.Type, s.Frames)
+		}
+		if s.ProcessingTime < 0 || s.ProcessingTime > time.Second {
+			t.Errorf("%s: implausible processing time %v", s.Type, s.ProcessingTime)
+		}
+	}
+}
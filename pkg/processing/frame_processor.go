@@ -1,6 +1,7 @@
 package processing
 
 import (
+	"context"
 	"time"
 
 	"github.com/echotools/nevr-capture/v3/pkg/events"
@@ -42,7 +43,19 @@ func NewWithDetector(det events.Detector) *Processor {
 // This is optimized for high-frequency invocation (up to 600 Hz)
 // Note: Events are now processed asynchronously and can be received via EventDetector.EventsChan()
 func (fp *Processor) ProcessAndDetectEvents(sessionResponseData, userBonesData []byte, timestamp time.Time) (*telemetry.LobbySessionStateFrame, error) {
-	// Reset the pre-allocated structs to avoid allocations
+	frame, err := fp.DecodeFrame(sessionResponseData, userBonesData, timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send frame to event detector for async processing
+	fp.eventDetector.ProcessFrame(frame)
+	return frame, nil
+}
+
+// DecodeFrame unmarshals raw session and user bones data into the next frame
+// without running event detection
+func (fp *Processor) DecodeFrame(sessionResponseData, userBonesData []byte, timestamp time.Time) (*telemetry.LobbySessionStateFrame, error) {
 	// Pre-allocated structs to avoid memory allocations
 	sessionResponse := &apigame.SessionResponse{}
 	bonesResponse := &apigame.PlayerBonesResponse{}
@@ -67,8 +80,6 @@ func (fp *Processor) ProcessAndDetectEvents(sessionResponseData, userBonesData [
 		PlayerBones: bonesResponse,
 	}
 
-	// Send frame to event detector for async processing
-	fp.eventDetector.ProcessFrame(frame)
 	fp.frameIndex++
 
 	return frame, nil
@@ -79,11 +90,45 @@ func (p *Processor) DetectEvents(f *telemetry.LobbySessionStateFrame) {
 	p.eventDetector.ProcessFrame(f)
 }
 
+// Detect runs event detection on a frame inline and returns the detected
+// events and custom events without going through EventsChan
+func (p *Processor) Detect(f *telemetry.LobbySessionStateFrame) ([]*telemetry.LobbySessionEvent, []*events.CustomEvent) {
+	return p.eventDetector.Detect(f)
+}
+
 // EventsChan returns the channel for receiving detected events
 func (fp *Processor) EventsChan() <-chan []*telemetry.LobbySessionEvent {
 	return fp.eventDetector.EventsChan()
 }
 
+// EnvelopesChan returns the channel for receiving detected events stamped
+// with their source frame, along with custom events. Receives nothing unless
+// the detector has envelopes enabled.
+func (fp *Processor) EnvelopesChan() <-chan []*events.EventEnvelope {
+	return fp.eventDetector.EnvelopesChan()
+}
+
+// RegisterSensors adds sensors to the event detector while it is running
+func (fp *Processor) RegisterSensors(sensors ...events.EventSensor) {
+	fp.eventDetector.RegisterSensors(sensors...)
+}
+
+// RemoveSensor removes a sensor from the event detector. Returns false if
+// the sensor was not registered.
+func (fp *Processor) RemoveSensor(sensor events.EventSensor) bool {
+	return fp.eventDetector.RemoveSensor(sensor)
+}
+
+// Sensors returns the sensors registered with the event detector
+func (fp *Processor) Sensors() []events.EventSensor {
+	return fp.eventDetector.Sensors()
+}
+
+// Stats returns throughput and backpressure counters of the event detector
+func (fp *Processor) Stats() events.DetectorStats {
+	return fp.eventDetector.Stats()
+}
+
 // Reset clears the processor state
 func (fp *Processor) Reset() {
 	fp.frameIndex = 0
@@ -94,3 +139,9 @@ func (fp *Processor) Reset() {
 func (fp *Processor) Stop() {
 	fp.eventDetector.Stop()
 }
+
+// StopAndFlush processes frames already queued in the event detector and
+// delivers their events before shutting it down
+func (fp *Processor) StopAndFlush(ctx context.Context) error {
+	return fp.eventDetector.StopAndFlush(ctx)
+}
@@ -1,10 +1,12 @@
 package processing
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 	"time"
 
+	"github.com/echotools/nevr-capture/v3/pkg/events"
 	"github.com/echotools/nevr-common/v4/gen/go/apigame"
 	"github.com/echotools/nevr-common/v4/gen/go/telemetry/v1"
 	"google.golang.org/protobuf/types/known/timestamppb"
@@ -146,16 +148,48 @@ func TestFrameProcessor_InvalidJSON(t *testing.T) {
 type mockDetector struct {
 	processedFrames []*telemetry.LobbySessionStateFrame
 	eventsChan      chan []*telemetry.LobbySessionEvent
+	sensors         []events.EventSensor
 }
 
 func (m *mockDetector) ProcessFrame(frame *telemetry.LobbySessionStateFrame) {
 	m.processedFrames = append(m.processedFrames, frame)
 }
 
+func (m *mockDetector) Detect(frame *telemetry.LobbySessionStateFrame) ([]*telemetry.LobbySessionEvent, []*events.CustomEvent) {
+	m.processedFrames = append(m.processedFrames, frame)
+	return nil, nil
+}
+
 func (m *mockDetector) EventsChan() <-chan []*telemetry.LobbySessionEvent {
 	return m.eventsChan
 }
 
+func (m *mockDetector) EnvelopesChan() <-chan []*events.EventEnvelope {
+	return nil
+}
+
+func (m *mockDetector) RegisterSensors(sensors ...events.EventSensor) {
+	m.sensors = append(m.sensors, sensors...)
+}
+
+func (m *mockDetector) RemoveSensor(sensor events.EventSensor) bool {
+	for i, s := range m.sensors {
+		if s == sensor {
+			m.sensors = append(m.sensors[:i], m.sensors[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (m *mockDetector) Sensors() []events.EventSensor {
+	return m.sensors
+}
+
+func (m *mockDetector) Stats() events.DetectorStats {
+	return events.DetectorStats{FramesReceived: uint64(len(m.processedFrames))}
+}
+
 func (m *mockDetector) Reset() {
 	m.processedFrames = nil
 }
@@ -164,6 +198,11 @@ func (m *mockDetector) Stop() {
 	close(m.eventsChan)
 }
 
+func (m *mockDetector) StopAndFlush(ctx context.Context) error {
+	m.Stop()
+	return ctx.Err()
+}
+
 func TestFrameProcessor_Delegation(t *testing.T) {
 	mock := &mockDetector{
 		eventsChan: make(chan []*telemetry.LobbySessionEvent),
@@ -185,6 +224,27 @@ func TestFrameProcessor_Delegation(t *testing.T) {
 		t.Errorf("Expected 1 processed frame, got %d", len(mock.processedFrames))
 	}
 
+	// Verify Detect delegation
+	processor.Detect(&telemetry.LobbySessionStateFrame{})
+	if len(mock.processedFrames) != 2 {
+		t.Errorf("Expected 2 processed frames after Detect, got %d", len(mock.processedFrames))
+	}
+
+	// Verify Stats delegation
+	if got := processor.Stats().FramesReceived; got != 2 {
+		t.Errorf("Expected 2 frames received, got %d", got)
+	}
+
+	// Verify sensor registration delegation
+	sensor := events.NewPlayerJoinSensor()
+	processor.RegisterSensors(sensor)
+	if got := processor.Sensors(); len(got) != 1 || got[0] != sensor {
+		t.Errorf("Expected registered sensor, got %v", got)
+	}
+	if !processor.RemoveSensor(sensor) || len(processor.Sensors()) != 0 {
+		t.Error("Expected sensor to be removed")
+	}
+
 	// Verify Reset delegation
 	processor.Reset()
 	if len(mock.processedFrames) != 0 {